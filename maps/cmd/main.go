@@ -4,22 +4,121 @@ import (
 	"fmt"
 	"log"
 	"maps_service/internal/api"
+	"maps_service/internal/domain"
+	"maps_service/internal/replay"
+	"maps_service/internal/services"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+const defaultMatrixCacheTTL = 7 * 24 * time.Hour
+
+const defaultShopsCatalogueInterval = 24 * time.Hour
+
+const defaultChainsConfig = "configs/chains.json"
+
+var defaultChunkedMatrixSettings = services.ChunkedMatrixSettings{
+	ChunkSize:   10,
+	Concurrency: 4,
+	RateLimit:   5,
+	RateBurst:   5,
+	Retries:     3,
+}
+
+func getIntEnv(name string, defaultValue, minValue int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil || result < minValue {
+		return 0, fmt.Errorf("invalid %s env: %s", name, value)
+	}
+	return result, nil
+}
+
+func getChunkedMatrixSettings() (services.ChunkedMatrixSettings, error) {
+	settings := defaultChunkedMatrixSettings
+	var err error
+
+	if settings.ChunkSize, err = getIntEnv("MATRIX_CHUNK_SIZE", settings.ChunkSize, 1); err != nil {
+		return settings, err
+	}
+	if settings.Concurrency, err = getIntEnv("MATRIX_CONCURRENCY", settings.Concurrency, 1); err != nil {
+		return settings, err
+	}
+	if settings.RateBurst, err = getIntEnv("MATRIX_RATE_BURST", settings.RateBurst, 1); err != nil {
+		return settings, err
+	}
+	if settings.Retries, err = getIntEnv("MATRIX_RETRIES", settings.Retries, 0); err != nil {
+		return settings, err
+	}
+	if value := os.Getenv("MATRIX_RATE_LIMIT"); value != "" {
+		rateLimit, err := strconv.ParseFloat(value, 64)
+		if err != nil || rateLimit <= 0 {
+			return settings, fmt.Errorf("invalid MATRIX_RATE_LIMIT env: %s", value)
+		}
+		settings.RateLimit = rateLimit
+	}
+
+	return settings, nil
+}
+
 type Settings struct {
-	ApiKey2Gis string
-	Port       string
+	ApiKey2Gis      string
+	Port            string
+	MatrixProvider  string
+	OsmPath         string
+	MatrixChunked   services.ChunkedMatrixSettings
+	MatrixCache     string
+	MatrixCacheTTL  time.Duration
+	MatrixCachePath string
+	RedisAddr       string
+	Geocoder        string
+	GeocoderFixture string
+	ChainsConfig    string
+	// Without cities the shops are always looked up live.
+	CatalogueCities   string
+	CataloguePath     string
+	CatalogueInterval time.Duration
+	// Requests to 2GIS are recorded to or replayed from the fixtures in the
+	// record and replay modes.
+	HttpMode     string
+	HttpFixtures string
 }
 
 func GetSettings() (*Settings, error) {
 	settings := Settings{}
 
+	settings.MatrixProvider = "2gis"
+	if provider := os.Getenv("MATRIX_PROVIDER"); provider != "" {
+		settings.MatrixProvider = provider
+	}
+
+	switch settings.MatrixProvider {
+	case "2gis":
+	case "osm":
+		if path := os.Getenv("OSM_PATH"); path != "" {
+			settings.OsmPath = path
+		} else {
+			return nil, fmt.Errorf("can't get OSM_PATH env")
+		}
+	default:
+		return nil, fmt.Errorf("unknown MATRIX_PROVIDER env: %s", settings.MatrixProvider)
+	}
+
+	// The key is still used for shops lookup, but offline routing can run
+	// without it, and replayed requests need no key at all.
 	if apiKey := os.Getenv("API_KEY_2GIS"); apiKey != "" {
 		settings.ApiKey2Gis = apiKey
-	} else {
+	} else if settings.MatrixProvider == "2gis" && os.Getenv("HTTP_MODE") != replay.ModeReplay {
 		return nil, fmt.Errorf("can't get API_KEY_2GIS env")
+	} else {
+		log.Println("API_KEY_2GIS env is not set, shops lookup is unavailable")
 	}
 
 	if port := os.Getenv("PORT"); port != "" {
@@ -28,21 +127,189 @@ func GetSettings() (*Settings, error) {
 		return nil, fmt.Errorf("can't get PORT env")
 	}
 
+	chunkedSettings, err := getChunkedMatrixSettings()
+	if err != nil {
+		return nil, err
+	}
+	settings.MatrixChunked = chunkedSettings
+
+	settings.MatrixCache = os.Getenv("MATRIX_CACHE")
+
+	settings.MatrixCacheTTL = defaultMatrixCacheTTL
+	if ttl := os.Getenv("MATRIX_CACHE_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MATRIX_CACHE_TTL env: %+v", err)
+		}
+		settings.MatrixCacheTTL = duration
+	}
+
+	switch settings.MatrixCache {
+	case "":
+	case "redis":
+		if redisAddr := os.Getenv("REDIS_ADDR"); redisAddr != "" {
+			settings.RedisAddr = redisAddr
+		} else {
+			return nil, fmt.Errorf("can't get REDIS_ADDR env")
+		}
+	case "disk":
+		if path := os.Getenv("MATRIX_CACHE_PATH"); path != "" {
+			settings.MatrixCachePath = path
+		} else {
+			return nil, fmt.Errorf("can't get MATRIX_CACHE_PATH env")
+		}
+	default:
+		return nil, fmt.Errorf("unknown MATRIX_CACHE env: %s", settings.MatrixCache)
+	}
+
+	settings.Geocoder = "2gis"
+	if geocoder := os.Getenv("GEOCODER_PROVIDER"); geocoder != "" {
+		settings.Geocoder = geocoder
+	}
+
+	switch settings.Geocoder {
+	case "2gis":
+	case "fixture":
+		if path := os.Getenv("GEOCODER_FIXTURE"); path != "" {
+			settings.GeocoderFixture = path
+		} else {
+			return nil, fmt.Errorf("can't get GEOCODER_FIXTURE env")
+		}
+	default:
+		return nil, fmt.Errorf("unknown GEOCODER_PROVIDER env: %s", settings.Geocoder)
+	}
+
+	settings.HttpMode = replay.ModePassthrough
+	if mode := os.Getenv("HTTP_MODE"); mode != "" {
+		settings.HttpMode = mode
+	}
+	settings.HttpFixtures = os.Getenv("HTTP_FIXTURES")
+
+	switch settings.HttpMode {
+	case replay.ModePassthrough:
+	case replay.ModeRecord, replay.ModeReplay:
+		if settings.HttpFixtures == "" {
+			return nil, fmt.Errorf("can't get HTTP_FIXTURES env")
+		}
+	default:
+		return nil, fmt.Errorf("unknown HTTP_MODE env: %s", settings.HttpMode)
+	}
+
+	settings.ChainsConfig = defaultChainsConfig
+	if path := os.Getenv("CHAINS_CONFIG"); path != "" {
+		settings.ChainsConfig = path
+	}
+
+	settings.CatalogueCities = os.Getenv("SHOPS_CATALOGUE_CITIES")
+	settings.CataloguePath = os.Getenv("SHOPS_CATALOGUE_PATH")
+
+	settings.CatalogueInterval = defaultShopsCatalogueInterval
+	if interval := os.Getenv("SHOPS_CATALOGUE_INTERVAL"); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid SHOPS_CATALOGUE_INTERVAL env: %s", interval)
+		}
+		settings.CatalogueInterval = duration
+	}
+
 	return &settings, nil
 }
 
+func createMatrixProvider(settings *Settings, client *http.Client) (domain.IMatrixService, error) {
+	if settings.MatrixProvider == "osm" {
+		return services.NewOsmMatrixService(settings.OsmPath)
+	}
+	return services.NewChunkedMatrixService(services.NewMatrix2GisService(settings.ApiKey2Gis, client), settings.MatrixChunked), nil
+}
+
+func createMatrixService(settings *Settings, client *http.Client, metricsService domain.IMetricsService) (domain.IMatrixService, error) {
+	matrixProvider, err := createMatrixProvider(settings, client)
+	if err != nil {
+		return nil, err
+	}
+
+	switch settings.MatrixCache {
+	case "redis":
+		cache := services.NewRedisMatrixCache(settings.RedisAddr, settings.MatrixCacheTTL)
+		return services.NewCachedMatrixService(matrixProvider, cache, metricsService), nil
+	case "disk":
+		cache, err := services.NewDiskMatrixCache(settings.MatrixCachePath, settings.MatrixCacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		return services.NewCachedMatrixService(matrixProvider, cache, metricsService), nil
+	}
+
+	return matrixProvider, nil
+}
+
+func createGeocoderService(settings *Settings, client *http.Client) (domain.IGeocoderService, error) {
+	if settings.Geocoder == "fixture" {
+		return services.LoadFixtureGeocoderService(settings.GeocoderFixture)
+	}
+	return services.NewGeocoder2GisService(settings.ApiKey2Gis, client), nil
+}
+
 func main() {
 	settings, err := GetSettings()
 	if err != nil {
 		panic(err)
 	}
 
+	chainRegistry, err := services.LoadChainRegistry(settings.ChainsConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	client, err := replay.NewClient(nil, settings.HttpMode, settings.HttpFixtures, settings.ApiKey2Gis)
+	if err != nil {
+		panic(err)
+	}
+
+	metricsService := services.NewPrometheusMetricsService()
+	matrixService, err := createMatrixService(settings, client, metricsService)
+	if err != nil {
+		panic(err)
+	}
+
+	var shopInfoService domain.IShopInfoService = services.NewShopInfo2GisService(settings.ApiKey2Gis, client)
+	var shopCatalogue *services.ShopCatalogue
+	if settings.CatalogueCities != "" {
+		cities, err := services.LoadCities(settings.CatalogueCities)
+		if err != nil {
+			panic(err)
+		}
+		shopCatalogue, err = services.NewShopCatalogue(shopInfoService, chainRegistry, cities, settings.CataloguePath)
+		if err != nil {
+			panic(err)
+		}
+		go shopCatalogue.Run(settings.CatalogueInterval)
+		shopInfoService = shopCatalogue
+	}
+	shopsRequester := services.NewShopsRequester(shopInfoService, chainRegistry)
+
+	geocoderService, err := createGeocoderService(settings, client)
+	if err != nil {
+		panic(err)
+	}
+
+	reachableShopsService := services.NewReachableShopsService(shopsRequester, matrixService)
+	routeGeometryService := services.NewRouteGeometryService(services.NewRouteLeg2GisService(settings.ApiKey2Gis, client))
+
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /available-shops", api.CreateAvailableShopsHandler(settings.ApiKey2Gis))
-	mux.HandleFunc("POST /shops", api.CreateShopsHandler(settings.ApiKey2Gis))
-	mux.HandleFunc("POST /optimal-routes", api.CreateOptimalRoutesHandler(settings.ApiKey2Gis))
-	mux.HandleFunc("POST /distance", api.CreateDistanceHandler(settings.ApiKey2Gis))
+	mux.HandleFunc("GET /available-shops", api.CreateAvailableShopsHandler(shopsRequester))
+	mux.HandleFunc("POST /shops", api.CreateShopsHandler(shopsRequester))
+	if shopCatalogue != nil {
+		mux.HandleFunc("GET /shops/catalogue/diff", api.CreateCatalogueDiffHandler(shopCatalogue))
+	}
+	mux.HandleFunc("POST /reachable-shops", api.CreateReachableShopsHandler(reachableShopsService))
+	mux.HandleFunc("POST /optimal-routes", api.CreateOptimalRoutesHandler(matrixService))
+	mux.HandleFunc("POST /distance", api.CreateDistanceHandler(matrixService))
+	mux.HandleFunc("POST /route-geometry", api.CreateRouteGeometryHandler(routeGeometryService))
+	mux.HandleFunc("POST /geocode", api.CreateGeocodeHandler(geocoderService))
+	mux.HandleFunc("POST /reverse-geocode", api.CreateReverseGeocodeHandler(geocoderService))
+	mux.Handle("/metrics", promhttp.Handler())
 
 	cors := api.CorsMiddleware(mux)
 
@@ -2,31 +2,73 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"maps_service/internal/domain"
+	"maps_service/internal/geo"
 	"maps_service/internal/services"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 )
 
 type shopsRequest struct {
 	Point  domain.Point `json:"point"`
 	Radius int64        `json:"radius"`
+	// When set, only the places open at this time are returned.
+	OpenAt *time.Time `json:"open_at"`
+	// Search areas used instead of the point and the radius.
+	Polygon  *domain.Polygon  `json:"polygon"`
+	Corridor *domain.Corridor `json:"corridor"`
+	// Place formats to return, all of them when empty, and to skip.
+	Formats        []string `json:"formats"`
+	ExcludeFormats []string `json:"exclude_formats"`
 }
 
-type shopsResponse struct {
-	Shops []domain.ShopInfo `json:"shops"`
+type reachableShopsRequest struct {
+	Point     domain.Point `json:"point"`
+	Transport string       `json:"transport"`
+	// Travel time budget in seconds.
+	MaxDuration    int      `json:"maxDuration"`
+	Formats        []string `json:"formats"`
+	ExcludeFormats []string `json:"exclude_formats"`
+}
+
+func checkFormats(formats ...[]string) error {
+	for _, list := range formats {
+		for _, format := range list {
+			if !slices.Contains(domain.PLACE_FORMATS, format) {
+				return fmt.Errorf("unknown place format: %s", format)
+			}
+		}
+	}
+	return nil
 }
 
-type tspResp struct {
-	Routes []domain.MinTimeRoute `json:"routes"`
+type geocodeRequest struct {
+	Query string `json:"query"`
+}
+
+type geocodeResponse struct {
+	Addresses []domain.Address `json:"addresses"`
+}
+
+type reverseGeocodeRequest struct {
+	Point domain.Point `json:"point"`
+}
+
+type shopsResponse struct {
+	Shops []domain.ShopInfo `json:"shops"`
 }
 
 type distanceRequest struct {
-	From []domain.Point `json:"from"`
-	To   []domain.Point `json:"to"`
-	Type string         `json:"type"`
+	From          []domain.Point `json:"from"`
+	To            []domain.Point `json:"to"`
+	Type          string         `json:"type"`
+	DepartureTime *time.Time     `json:"departureTime"`
 }
 
 type distanceResponse struct {
@@ -39,8 +81,9 @@ type pointInfo struct {
 }
 
 type route struct {
-	To   int `json:"to"`
-	Time int `json:"time"`
+	To       int `json:"to"`
+	Time     int `json:"time"`
+	Distance int `json:"distance"`
 }
 
 func logHttpError(w http.ResponseWriter, message string, code int) {
@@ -48,10 +91,21 @@ func logHttpError(w http.ResponseWriter, message string, code int) {
 	http.Error(w, message, code)
 }
 
-func CreateShopsHandler(apiKey string) func(w http.ResponseWriter, r *http.Request) {
-	shopInfo2Gis := services.NewShopInfo2GisService(apiKey)
-	shopsRequester := services.NewShopsRequester(shopInfo2Gis)
+// createSearchArea returns nil when the request has neither a polygon nor a
+// corridor.
+func createSearchArea(request shopsRequest) (domain.IArea, error) {
+	switch {
+	case request.Polygon != nil && request.Corridor != nil:
+		return nil, fmt.Errorf("polygon and corridor can not be used together")
+	case request.Polygon != nil:
+		return geo.NewPolygonArea(request.Polygon)
+	case request.Corridor != nil:
+		return geo.NewCorridorArea(request.Corridor)
+	}
+	return nil, nil
+}
 
+func CreateShopsHandler(shopsService domain.IShopsService) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Println("POST /shops")
 
@@ -77,16 +131,100 @@ func CreateShopsHandler(apiKey string) func(w http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		json.NewEncoder(w).Encode(shopsResponse{
-			Shops: shopsRequester.GetNearbyShops(request.Point, request.Radius),
-		})
+		area, err := createSearchArea(request)
+		if err != nil {
+			logHttpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := checkFormats(request.Formats, request.ExcludeFormats); err != nil {
+			logHttpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var shops []domain.ShopInfo
+		if area != nil {
+			if shops, err = shopsService.GetShopsInArea(area); err != nil {
+				logHttpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			shops = shopsService.GetNearbyShops(request.Point, request.Radius)
+		}
+		if request.OpenAt != nil {
+			shops = services.FilterOpenShops(shops, *request.OpenAt)
+		}
+		shops = services.FilterShopFormats(shops, request.Formats, request.ExcludeFormats)
+
+		json.NewEncoder(w).Encode(shopsResponse{Shops: shops})
 	}
 }
 
-func CreateAvailableShopsHandler(apiKey string) func(w http.ResponseWriter, r *http.Request) {
-	shopInfo2Gis := services.NewShopInfo2GisService(apiKey)
-	shopsRequester := services.NewShopsRequester(shopInfo2Gis)
+func CreateCatalogueDiffHandler(catalogue domain.IShopCatalogue) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		diff := catalogue.GetLastDiff()
+		if diff == nil {
+			http.Error(w, "shops catalogue is not synced yet", http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(diff)
+	}
+}
 
+func CreateReachableShopsHandler(reachableShopsService domain.IReachableShopsService) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("POST /reachable-shops")
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			logHttpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logHttpError(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		log.Printf("Body: %s", string(body))
+
+		var request reachableShopsRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			logHttpError(w, "Failed to parse JSON", http.StatusBadRequest)
+			return
+		}
+
+		if err := checkFormats(request.Formats, request.ExcludeFormats); err != nil {
+			logHttpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		shops, err := reachableShopsService.Get(request.Point, request.Transport, request.MaxDuration)
+		if err != nil {
+			var upstreamErr *domain.UpstreamError
+			if errors.As(err, &upstreamErr) {
+				logHttpError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			logHttpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(shopsResponse{Shops: services.FilterShopFormats(shops, request.Formats, request.ExcludeFormats)})
+	}
+}
+
+func CreateAvailableShopsHandler(shopsService domain.IShopsService) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Println("GET /available-shops")
 
@@ -97,17 +235,59 @@ func CreateAvailableShopsHandler(apiKey string) func(w http.ResponseWriter, r *h
 			return
 		}
 
-		json.NewEncoder(w).Encode(shopsRequester.GetAvailableShops())
+		json.NewEncoder(w).Encode(shopsService.GetAvailableShops())
+	}
+}
+
+// routingFailureStatuses are ordered by priority, when no transport succeeds
+// the response gets the code of the first status met among the transports.
+var routingFailureStatuses = []struct {
+	status string
+	code   int
+}{
+	{domain.RouteStatusInvalidInput, http.StatusBadRequest},
+	{domain.RouteStatusQuotaExceeded, http.StatusTooManyRequests},
+	{domain.RouteStatusUpstreamError, http.StatusBadGateway},
+	{domain.RouteStatusError, http.StatusInternalServerError},
+	{domain.RouteStatusNoPath, http.StatusUnprocessableEntity},
+}
+
+// writeRoutingResult writes the result with the transport statuses, the code
+// is 200 if at least one transport has a route.
+func writeRoutingResult(w http.ResponseWriter, result domain.RoutingResult) {
+	code := http.StatusOK
+	if len(result.Routes) == 0 {
+		code = http.StatusInternalServerError
+	failures:
+		for _, failure := range routingFailureStatuses {
+			for _, status := range result.Statuses {
+				if status.Status == failure.status {
+					code = failure.code
+					break failures
+				}
+			}
+		}
+		log.Printf("no routes: %+v", result.Statuses)
 	}
+
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(result)
 }
 
-func CreateOptimalRoutesHandler(apiKey string) func(w http.ResponseWriter, r *http.Request) {
-	matrixService2Gis := services.NewMatrix2GisService(apiKey)
+func CreateOptimalRoutesHandler(matrixService domain.IMatrixService) func(w http.ResponseWriter, r *http.Request) {
 	tspBruteforce := services.NewTSPBruteforce()
 	tspDynProgramming := services.NewTSPDynProgramming()
-
-	routingServiceBruteforce := services.NewRoutingService(matrixService2Gis, tspBruteforce)
-	routingServiceDynProgramming := services.NewRoutingService(matrixService2Gis, tspDynProgramming)
+	tspLocalSearch := services.NewTSPLocalSearch()
+	tspSimulatedAnnealing := services.NewTSPSimulatedAnnealing()
+
+	routingServices := map[string]domain.IRoutingService{
+		"bruteforce":   services.NewRoutingService(matrixService, tspBruteforce),
+		"dp":           services.NewRoutingService(matrixService, tspDynProgramming),
+		"local-search": services.NewRoutingService(matrixService, tspLocalSearch),
+		"annealing":    services.NewRoutingService(matrixService, tspSimulatedAnnealing),
+		"auto":         services.NewRoutingService(matrixService, services.NewTSPAuto(tspDynProgramming, tspLocalSearch)),
+	}
+	timeWindowsRoutingService := services.NewTimeWindowsRoutingService(matrixService, services.NewTSPTimeWindows())
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -117,6 +297,17 @@ func CreateOptimalRoutesHandler(apiKey string) func(w http.ResponseWriter, r *ht
 			StartPoint int            `json:"startPoint"`
 			ByDistance bool           `json:"byDistance"`
 			Algorithm  string         `json:"algorithm"`
+
+			// Without both fields the route returns to the start point.
+			EndPoint *int `json:"endPoint"`
+			OpenPath bool `json:"openPath"`
+
+			// Durations of driving and taxi depend on traffic at the departure
+			// time. With schedules the route respects opening hours of the
+			// points, service time is spent at each point in seconds.
+			DepartureTime *time.Time         `json:"departureTime"`
+			ServiceTime   int                `json:"serviceTime"`
+			Schedules     []*domain.Schedule `json:"schedules"`
 		}
 
 		if r.Method != http.MethodPost {
@@ -139,25 +330,52 @@ func CreateOptimalRoutesHandler(apiKey string) func(w http.ResponseWriter, r *ht
 
 		log.Printf("POST /optimal-routes: %s", string(body))
 
-		if !(request.Algorithm == "bruteforce" || request.Algorithm == "dp") {
-			http.Error(w, "invalid algorithm for tsp", http.StatusBadRequest)
+		endPoint := request.StartPoint
+		if request.EndPoint != nil && request.OpenPath {
+			http.Error(w, "end point and open path can not be used together", http.StatusBadRequest)
 			return
+		} else if request.EndPoint != nil {
+			if *request.EndPoint < 0 || len(request.Points) <= *request.EndPoint {
+				http.Error(w, "invalid end point", http.StatusBadRequest)
+				return
+			}
+			endPoint = *request.EndPoint
+		} else if request.OpenPath {
+			endPoint = domain.OpenEndPoint
 		}
 
-		routes := func() domain.IRoutingService {
-			if request.Algorithm == "bruteforce" {
-				return routingServiceBruteforce
+		// The departure time alone gives the arrival times at the stops too.
+		if request.DepartureTime != nil || len(request.Schedules) > 0 || request.ServiceTime != 0 {
+			if request.DepartureTime == nil {
+				http.Error(w, "departure time is required for schedules and service time", http.StatusBadRequest)
+				return
+			}
+			if request.ServiceTime < 0 {
+				http.Error(w, "invalid service time", http.StatusBadRequest)
+				return
+			}
+
+			result, err := timeWindowsRoutingService.Get(request.Points, request.StartPoint, endPoint, request.Schedules, *request.DepartureTime, request.ServiceTime)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			}
-			return routingServiceDynProgramming
-		}().Get(request.Points, request.StartPoint, request.ByDistance)
 
-		json.NewEncoder(w).Encode(tspResp{Routes: routes})
+			writeRoutingResult(w, *result)
+			return
+		}
+
+		routingService, ok := routingServices[request.Algorithm]
+		if !ok {
+			http.Error(w, "invalid algorithm for tsp", http.StatusBadRequest)
+			return
+		}
+
+		writeRoutingResult(w, routingService.Get(request.Points, request.StartPoint, endPoint, request.ByDistance, request.DepartureTime))
 	}
 }
 
-func CreateDistanceHandler(apiKey string) func(http.ResponseWriter, *http.Request) {
-	matrixService2Gis := services.NewMatrix2GisService(apiKey)
-
+func CreateDistanceHandler(matrixService domain.IMatrixService) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Println("POST /distance")
 		w.Header().Set("Content-Type", "application/json")
@@ -194,7 +412,7 @@ func CreateDistanceHandler(apiKey string) func(http.ResponseWriter, *http.Reques
 		points = append(points, request.From...)
 		points = append(points, request.To...)
 
-		_, dur, err := matrixService2Gis.Get(points, sources, targets, request.Type)
+		dist, dur, err := matrixService.Get(points, sources, targets, request.Type, request.DepartureTime)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -207,7 +425,7 @@ func CreateDistanceHandler(apiKey string) func(http.ResponseWriter, *http.Reques
 			for j := 0; j < len(request.To); j += 1 {
 				val := dur[i][len(request.From)+j]
 				if val != -1 {
-					point.Routes = append(point.Routes, route{To: j, Time: val})
+					point.Routes = append(point.Routes, route{To: j, Time: val, Distance: dist[i][len(request.From)+j]})
 				}
 			}
 
@@ -220,6 +438,128 @@ func CreateDistanceHandler(apiKey string) func(http.ResponseWriter, *http.Reques
 	}
 }
 
+func CreateRouteGeometryHandler(routeGeometryService domain.IRouteGeometryService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("POST /route-geometry")
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		var request struct {
+			Points    []domain.Point `json:"points"`
+			Route     []int          `json:"route"`
+			Transport string         `json:"transport"`
+		}
+
+		if err := json.Unmarshal(body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		geometry, err := routeGeometryService.Get(request.Points, request.Route, request.Transport)
+		if err != nil {
+			var upstreamErr *domain.UpstreamError
+			if errors.As(err, &upstreamErr) {
+				logHttpError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			logHttpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(geometry); err != nil {
+			http.Error(w, fmt.Sprintf("encode error: %s", err), http.StatusInternalServerError)
+		}
+	}
+}
+
+func CreateGeocodeHandler(geocoderService domain.IGeocoderService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("POST /geocode")
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		var request geocodeRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(request.Query) == "" {
+			http.Error(w, "empty query", http.StatusBadRequest)
+			return
+		}
+
+		addresses, err := geocoderService.Geocode(request.Query)
+		if err != nil {
+			logHttpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(geocodeResponse{Addresses: addresses}); err != nil {
+			http.Error(w, fmt.Sprintf("encode error: %s", err), http.StatusInternalServerError)
+		}
+	}
+}
+
+func CreateReverseGeocodeHandler(geocoderService domain.IGeocoderService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("POST /reverse-geocode")
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		var request reverseGeocodeRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		address, err := geocoderService.ReverseGeocode(request.Point)
+		if errors.Is(err, domain.ErrAddressNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			logHttpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(address); err != nil {
+			http.Error(w, fmt.Sprintf("encode error: %s", err), http.StatusInternalServerError)
+		}
+	}
+}
+
 func CorsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost")
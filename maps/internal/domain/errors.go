@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAddressNotFound is returned by the reverse geocoding when there is no
+// address near the point.
+var ErrAddressNotFound = errors.New("address not found")
+
+// ErrNoPath is returned by the tsp services when the points can't be
+// connected with the known routes.
+var ErrNoPath = errors.New("no path")
+
+// ErrInvalidInput is wrapped by the errors of the requests which can't succeed
+// whatever the upstream answers.
+var ErrInvalidInput = errors.New("invalid input")
+
+// UpstreamError is returned when the request to an external api fails.
+// StatusCode is 0 when the response was not received at all.
+type UpstreamError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *UpstreamError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("error sending request: %s", e.Message)
+	}
+	return fmt.Sprintf("api returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// QuotaExceeded reports whether the api rejected the request because of the
+// key limits.
+func (e *UpstreamError) QuotaExceeded() bool {
+	return e.StatusCode == 429
+}
+
+// Temporary reports whether the same request can succeed if it is repeated.
+func (e *UpstreamError) Temporary() bool {
+	return e.StatusCode == 0 || e.StatusCode == 429 || e.StatusCode >= 500
+}
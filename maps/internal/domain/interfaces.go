@@ -1,17 +1,82 @@
 package domain
 
+import "time"
+
 type IShopInfoService interface {
-	Get(shop string, point Point, radius int64) (*ShopInfo, error)
+	Get(chain Chain, point Point, radius int64) (*ShopInfo, error)
+}
+
+type IChainRegistry interface {
+	GetEnabled() []Chain
+}
+
+type IShopCatalogue interface {
+	Sync() (*CatalogueDiff, error)
+	// GetLastDiff returns nil before the first sync.
+	GetLastDiff() *CatalogueDiff
+}
+
+// IArea is a search area of any shape, Distance is 0 inside it.
+type IArea interface {
+	Contains(point Point) bool
+	Distance(point Point) float64
+	// Bounds returns the south-west and the north-east corners.
+	Bounds() (Point, Point)
+}
+
+type IShopsService interface {
+	GetAvailableShops() []string
+	GetNearbyShops(point Point, radius int64) []ShopInfo
+	GetShopsInArea(area IArea) ([]ShopInfo, error)
+}
+
+type IReachableShopsService interface {
+	Get(point Point, transport string, maxDuration int) ([]ShopInfo, error)
+}
+
+type IGeocoderService interface {
+	Geocode(query string) ([]Address, error)
+	ReverseGeocode(point Point) (*Address, error)
 }
 
 type IMatrixService interface {
-	Get(points []Point, sources, targets []int, transport string) ([][]int, [][]int, error)
+	// Without the departure time the durations do not depend on traffic.
+	Get(points []Point, sources, targets []int, transport string, departure *time.Time) ([][]int, [][]int, error)
+}
+
+type IMatrixCache interface {
+	Get(keys []string) (map[string]CachedRoute, error)
+	Set(routes map[string]CachedRoute) error
+}
+
+type IMetricsService interface {
+	AddMatrixCacheHits(transport string, count int)
+	AddMatrixCacheMisses(transport string, count int)
 }
 
 type ITSPService interface {
-	Get(matrix [][]int, startPoint int) (int, []int, error)
+	Get(matrix [][]int, startPoint, endPoint int) (int, []int, error)
+	IsExact(pointsCount int) bool
+}
+
+type ITSPTimeWindowsService interface {
+	Get(matrix [][]int, startPoint, endPoint int, windows [][]TimeWindow, serviceTime int) (*TimeWindowsTour, error)
 }
 
 type IRoutingService interface {
-	Get(points []Point, startPoint int, byDistance bool) []MinTimeRoute
+	Get(points []Point, startPoint, endPoint int, byDistance bool, departure *time.Time) RoutingResult
+}
+
+type ITimeWindowsRoutingService interface {
+	Get(points []Point, startPoint, endPoint int, schedules []*Schedule, departure time.Time, serviceTime int) (*RoutingResult, error)
+}
+
+// IRouteLegService builds the path between two points, From and To of the
+// result are left to the caller.
+type IRouteLegService interface {
+	Get(from, to Point, transport string) (*RouteLeg, error)
+}
+
+type IRouteGeometryService interface {
+	Get(points []Point, route []int, transport string) (*RouteGeometry, error)
 }
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type Point struct {
 	Lon float64 `json:"lon"`
 	Lat float64 `json:"lat"`
@@ -26,9 +28,61 @@ type Schedule struct {
 
 type Place struct {
 	Name     string   `json:"name"`
+	Address  string   `json:"address"`
 	Point    Point    `json:"point"`
 	Id       string   `json:"id"`
 	Schedule Schedule `json:"schedule"`
+	// IANA name of the time zone the schedule is given in.
+	TimeZone string `json:"timezone,omitempty"`
+	Format   string `json:"format"`
+}
+
+// Formats of the places.
+const (
+	FormatHypermarket = "hypermarket"
+	FormatSupermarket = "supermarket"
+	FormatExpress     = "express"
+	FormatCosmetics   = "cosmetics"
+)
+
+var PLACE_FORMATS = []string{FormatHypermarket, FormatSupermarket, FormatExpress, FormatCosmetics}
+
+type ChainMatch struct {
+	Names   []string `json:"names"`
+	Exclude []string `json:"exclude"`
+}
+
+type Chain struct {
+	Name    string     `json:"name"`
+	Query   string     `json:"query"`
+	Match   ChainMatch `json:"match"`
+	Rubrics []string   `json:"rubrics"`
+	Enabled bool       `json:"enabled"`
+	// Sub-brand names of each format, they are checked before the common
+	// format keywords.
+	Formats map[string][]string `json:"formats"`
+}
+
+// City is an area of the shops catalogue, a circle of Radius metres.
+type City struct {
+	Name   string  `json:"name"`
+	Point  Point   `json:"point"`
+	Radius float64 `json:"radius"`
+}
+
+// CatalogueChange is a place of the chain opened or closed between syncs.
+type CatalogueChange struct {
+	Shop  string `json:"shop"`
+	Place Place  `json:"place"`
+}
+
+// CatalogueDiff compares the places of a sync with the previous one, the
+// chains synced for the first time are not compared.
+type CatalogueDiff struct {
+	SyncedAt         time.Time         `json:"synced_at"`
+	PreviousSyncedAt time.Time         `json:"previous_synced_at"`
+	Opened           []CatalogueChange `json:"opened"`
+	Closed           []CatalogueChange `json:"closed"`
 }
 
 type ShopInfo struct {
@@ -36,8 +90,116 @@ type ShopInfo struct {
 	Shop string  `json:"shop"`
 }
 
+// OpenEndPoint as the end point lets a route finish at any point. The end point
+// equal to the start point gives a closed tour.
+const OpenEndPoint = -1
+
 type MinTimeRoute struct {
 	Points    []int  `json:"points"`
 	Duration  int    `json:"duration"`
 	Transport string `json:"transport"`
+	Optimal   bool   `json:"optimal"`
+
+	// Filled only for routes built with opening hours.
+	Stops      []Stop `json:"stops,omitempty"`
+	Infeasible []int  `json:"infeasible,omitempty"`
+}
+
+// Statuses of routing with one transport.
+const (
+	RouteStatusOk            = "ok"
+	RouteStatusNoPath        = "no_path"
+	RouteStatusQuotaExceeded = "quota_exceeded"
+	RouteStatusUpstreamError = "upstream_error"
+	RouteStatusInvalidInput  = "invalid_input"
+	RouteStatusError         = "error"
+)
+
+type TransportStatus struct {
+	Transport string `json:"transport"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RoutingResult has a status for each transport and the routes of the
+// successful ones.
+type RoutingResult struct {
+	Routes   []MinTimeRoute    `json:"routes"`
+	Statuses []TransportStatus `json:"statuses"`
+}
+
+type Stop struct {
+	Point     int       `json:"point"`
+	Arrival   time.Time `json:"arrival"`
+	Departure time.Time `json:"departure"`
+}
+
+// TimeWindow is an interval in seconds since the departure.
+type TimeWindow struct {
+	From int
+	To   int
+}
+
+type TimeWindowsTour struct {
+	Points     []int
+	Duration   int
+	Arrivals   []int
+	Departures []int
+	Infeasible []int
+}
+
+// LineString is a GeoJSON geometry with [lon, lat] coordinates.
+type LineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+// Polygon is a GeoJSON geometry, the first ring is the outer border and the
+// others are holes.
+type Polygon struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// Corridor is the area within Buffer metres from the line.
+type Corridor struct {
+	Line   LineString `json:"line"`
+	Buffer float64    `json:"buffer"`
+}
+
+type Maneuver struct {
+	Type      string `json:"type"`
+	Direction string `json:"direction,omitempty"`
+	Comment   string `json:"comment"`
+	// Distance and duration of the path after the maneuver.
+	Distance int   `json:"distance"`
+	Duration int   `json:"duration"`
+	Location Point `json:"location"`
+}
+
+type RouteLeg struct {
+	// Indices of the leg ends in the requested points.
+	From      int        `json:"from"`
+	To        int        `json:"to"`
+	Distance  int        `json:"distance"`
+	Duration  int        `json:"duration"`
+	Geometry  LineString `json:"geometry"`
+	Maneuvers []Maneuver `json:"maneuvers"`
+}
+
+type RouteGeometry struct {
+	Transport string     `json:"transport"`
+	Distance  int        `json:"distance"`
+	Duration  int        `json:"duration"`
+	Legs      []RouteLeg `json:"legs"`
+}
+
+type Address struct {
+	Name  string `json:"name"`
+	Point Point  `json:"point"`
+}
+
+type CachedRoute struct {
+	Distance int `json:"distance"`
+	Duration int `json:"duration"`
 }
@@ -0,0 +1,223 @@
+package geo
+
+import (
+	"fmt"
+	"maps_service/internal/domain"
+	"math"
+)
+
+// Distances to the borders are computed in a local flat projection, which is
+// precise enough for the areas of a city size.
+const metresPerDegree = EarthRadius * math.Pi / 180
+
+type vector struct {
+	x float64
+	y float64
+}
+
+// project returns the offset of the coordinate from the origin in metres.
+func project(origin domain.Point, coordinate [2]float64) vector {
+	return vector{
+		x: (coordinate[0] - origin.Lon) * math.Cos(toRadians(origin.Lat)) * metresPerDegree,
+		y: (coordinate[1] - origin.Lat) * metresPerDegree,
+	}
+}
+
+// segmentDistance returns the distance from the origin to the segment.
+func segmentDistance(a, b vector) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	t := 0.
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(a.x*dx+a.y*dy)/length))
+	}
+	return math.Hypot(a.x+t*dx, a.y+t*dy)
+}
+
+func lineDistance(point domain.Point, line [][2]float64) float64 {
+	result := math.Inf(1)
+	for i := 0; i+1 < len(line); i++ {
+		result = math.Min(result, segmentDistance(project(point, line[i]), project(point, line[i+1])))
+	}
+	return result
+}
+
+func bounds(coordinates [][2]float64) (domain.Point, domain.Point) {
+	southWest := domain.Point{Lon: math.Inf(1), Lat: math.Inf(1)}
+	northEast := domain.Point{Lon: math.Inf(-1), Lat: math.Inf(-1)}
+	for _, coordinate := range coordinates {
+		southWest.Lon, southWest.Lat = math.Min(southWest.Lon, coordinate[0]), math.Min(southWest.Lat, coordinate[1])
+		northEast.Lon, northEast.Lat = math.Max(northEast.Lon, coordinate[0]), math.Max(northEast.Lat, coordinate[1])
+	}
+	return southWest, northEast
+}
+
+// closeRing repeats the first coordinate at the end, GeoJSON requires closed
+// rings but clients often omit the last coordinate.
+func closeRing(ring [][2]float64) [][2]float64 {
+	if ring[0] == ring[len(ring)-1] {
+		return ring
+	}
+	return append(append([][2]float64(nil), ring...), ring[0])
+}
+
+type PolygonArea struct {
+	rings [][][2]float64
+}
+
+func NewPolygonArea(polygon *domain.Polygon) (*PolygonArea, error) {
+	if polygon.Type != "Polygon" {
+		return nil, fmt.Errorf("unsupported geometry type: %s", polygon.Type)
+	}
+	if len(polygon.Coordinates) == 0 {
+		return nil, fmt.Errorf("polygon without rings")
+	}
+
+	area := &PolygonArea{}
+	for _, ring := range polygon.Coordinates {
+		if len(ring) < 3 {
+			return nil, fmt.Errorf("polygon ring with %d coordinates", len(ring))
+		}
+		area.rings = append(area.rings, closeRing(ring))
+	}
+	return area, nil
+}
+
+// ringContains casts a ray from the point to the east and counts the crossed
+// edges.
+func ringContains(ring [][2]float64, point domain.Point) bool {
+	inside := false
+	for i := 0; i+1 < len(ring); i++ {
+		a, b := ring[i], ring[i+1]
+		if (a[1] > point.Lat) != (b[1] > point.Lat) {
+			lon := a[0] + (point.Lat-a[1])/(b[1]-a[1])*(b[0]-a[0])
+			if point.Lon < lon {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+func (area *PolygonArea) Contains(point domain.Point) bool {
+	if !ringContains(area.rings[0], point) {
+		return false
+	}
+	for _, hole := range area.rings[1:] {
+		if ringContains(hole, point) {
+			return false
+		}
+	}
+	return true
+}
+
+func (area *PolygonArea) Distance(point domain.Point) float64 {
+	if area.Contains(point) {
+		return 0
+	}
+	result := math.Inf(1)
+	for _, ring := range area.rings {
+		result = math.Min(result, lineDistance(point, ring))
+	}
+	return result
+}
+
+func (area *PolygonArea) Bounds() (domain.Point, domain.Point) {
+	return bounds(area.rings[0])
+}
+
+type CorridorArea struct {
+	line   [][2]float64
+	buffer float64
+}
+
+func NewCorridorArea(corridor *domain.Corridor) (*CorridorArea, error) {
+	if corridor.Line.Type != "LineString" {
+		return nil, fmt.Errorf("unsupported geometry type: %s", corridor.Line.Type)
+	}
+	if len(corridor.Line.Coordinates) < 2 {
+		return nil, fmt.Errorf("corridor line with %d coordinates", len(corridor.Line.Coordinates))
+	}
+	if corridor.Buffer <= 0 {
+		return nil, fmt.Errorf("invalid corridor buffer: %f", corridor.Buffer)
+	}
+	return &CorridorArea{line: corridor.Line.Coordinates, buffer: corridor.Buffer}, nil
+}
+
+func (area *CorridorArea) Contains(point domain.Point) bool {
+	return area.Distance(point) == 0
+}
+
+func (area *CorridorArea) Distance(point domain.Point) float64 {
+	return math.Max(0, lineDistance(point, area.line)-area.buffer)
+}
+
+func (area *CorridorArea) Bounds() (domain.Point, domain.Point) {
+	southWest, northEast := bounds(area.line)
+
+	dLat := area.buffer / metresPerDegree
+	dLon := dLat / math.Cos(toRadians(math.Max(math.Abs(southWest.Lat), math.Abs(northEast.Lat))))
+	return domain.Point{Lon: southWest.Lon - dLon, Lat: southWest.Lat - dLat},
+		domain.Point{Lon: northEast.Lon + dLon, Lat: northEast.Lat + dLat}
+}
+
+type CircleArea struct {
+	center domain.Point
+	radius float64
+}
+
+func NewCircleArea(center domain.Point, radius float64) *CircleArea {
+	return &CircleArea{center: center, radius: radius}
+}
+
+func (area *CircleArea) Contains(point domain.Point) bool {
+	return Distance(area.center, point) <= area.radius
+}
+
+func (area *CircleArea) Distance(point domain.Point) float64 {
+	return math.Max(0, Distance(area.center, point)-area.radius)
+}
+
+func (area *CircleArea) Bounds() (domain.Point, domain.Point) {
+	dLat := area.radius / metresPerDegree
+	dLon := dLat / math.Cos(toRadians(math.Abs(area.center.Lat)+dLat))
+	return domain.Point{Lon: area.center.Lon - dLon, Lat: area.center.Lat - dLat},
+		domain.Point{Lon: area.center.Lon + dLon, Lat: area.center.Lat + dLat}
+}
+
+type Tile struct {
+	Center domain.Point
+	Radius float64
+}
+
+// Tiles covers the area with circles of at most maxRadius metres. The bounds
+// are split into squares inscribed into the circles, and the squares not
+// touching the area are skipped.
+func Tiles(area domain.IArea, maxRadius float64) []Tile {
+	southWest, northEast := area.Bounds()
+	center := domain.Point{Lon: (southWest.Lon + northEast.Lon) / 2, Lat: (southWest.Lat + northEast.Lat) / 2}
+
+	if radius := Distance(center, northEast); radius <= maxRadius {
+		return []Tile{{Center: center, Radius: math.Max(radius, 1)}}
+	}
+
+	side := maxRadius * math.Sqrt2
+	dLat := side / metresPerDegree
+	// The latitude nearest to the equator gives the widest squares in metres.
+	dLon := dLat / math.Cos(toRadians(math.Min(math.Abs(southWest.Lat), math.Abs(northEast.Lat))))
+	rows := int(math.Ceil((northEast.Lat - southWest.Lat) / dLat))
+	columns := int(math.Ceil((northEast.Lon - southWest.Lon) / dLon))
+
+	tiles := []Tile{}
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			tileCenter := domain.Point{
+				Lon: southWest.Lon + (float64(column)+0.5)*dLon,
+				Lat: southWest.Lat + (float64(row)+0.5)*dLat,
+			}
+			if area.Distance(tileCenter) <= maxRadius {
+				tiles = append(tiles, Tile{Center: tileCenter, Radius: maxRadius})
+			}
+		}
+	}
+	return tiles
+}
@@ -0,0 +1,52 @@
+package geo
+
+import (
+	"fmt"
+	"maps_service/internal/domain"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const EarthRadius = 6371000.
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Distance returns the great-circle distance between two points in metres.
+func Distance(from, to domain.Point) float64 {
+	lat1, lat2 := toRadians(from.Lat), toRadians(to.Lat)
+	dLat := lat2 - lat1
+	dLon := toRadians(to.Lon - from.Lon)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ParseLineString reads [lon, lat] coordinates of a WKT LINESTRING.
+func ParseLineString(wkt string) ([][2]float64, error) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(wkt), "LINESTRING")
+	body = strings.TrimSpace(body)
+	if !ok || !strings.HasPrefix(body, "(") || !strings.HasSuffix(body, ")") {
+		return nil, fmt.Errorf("invalid linestring %q", wkt)
+	}
+
+	coordinates := [][2]float64{}
+	for _, pair := range strings.Split(body[1:len(body)-1], ",") {
+		fields := strings.Fields(pair)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid linestring %q", wkt)
+		}
+		lon, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid linestring %q: %+v", wkt, err)
+		}
+		lat, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid linestring %q: %+v", wkt, err)
+		}
+		coordinates = append(coordinates, [2]float64{lon, lat})
+	}
+	return coordinates, nil
+}
@@ -0,0 +1,69 @@
+package geo
+
+import (
+	"maps_service/internal/domain"
+	"math"
+)
+
+type cell struct {
+	x int
+	y int
+}
+
+type indexItem[T any] struct {
+	point domain.Point
+	value T
+}
+
+// GridIndex buckets points into cells of a fixed size in degrees, the same way
+// a geohash of a fixed precision does. Querying an area reads only the cells
+// overlapping its bounds.
+type GridIndex[T any] struct {
+	cellSize float64
+	cells    map[cell][]indexItem[T]
+}
+
+// NewGridIndex creates an index with cells of about cellSize metres along a
+// meridian.
+func NewGridIndex[T any](cellSize float64) *GridIndex[T] {
+	return &GridIndex[T]{cellSize: cellSize / metresPerDegree, cells: make(map[cell][]indexItem[T])}
+}
+
+func (index *GridIndex[T]) getCell(point domain.Point) cell {
+	return cell{x: int(math.Floor(point.Lon / index.cellSize)), y: int(math.Floor(point.Lat / index.cellSize))}
+}
+
+func (index *GridIndex[T]) Insert(point domain.Point, value T) {
+	key := index.getCell(point)
+	index.cells[key] = append(index.cells[key], indexItem[T]{point: point, value: value})
+}
+
+// Query returns the values of the points inside the area.
+func (index *GridIndex[T]) Query(area domain.IArea) []T {
+	southWest, northEast := area.Bounds()
+	from, to := index.getCell(southWest), index.getCell(northEast)
+
+	result := []T{}
+	if (to.x-from.x+1)*(to.y-from.y+1) > len(index.cells) {
+		for _, items := range index.cells {
+			result = appendContained(result, items, area)
+		}
+		return result
+	}
+
+	for x := from.x; x <= to.x; x++ {
+		for y := from.y; y <= to.y; y++ {
+			result = appendContained(result, index.cells[cell{x: x, y: y}], area)
+		}
+	}
+	return result
+}
+
+func appendContained[T any](result []T, items []indexItem[T], area domain.IArea) []T {
+	for _, item := range items {
+		if area.Contains(item.point) {
+			result = append(result, item.value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+package mock
+
+import "maps_service/internal/domain"
+
+type MockMatrixCache struct {
+	Routes map[string]domain.CachedRoute
+}
+
+func NewMockMatrixCache() *MockMatrixCache {
+	return &MockMatrixCache{Routes: make(map[string]domain.CachedRoute)}
+}
+
+func (mock *MockMatrixCache) Get(keys []string) (map[string]domain.CachedRoute, error) {
+	result := make(map[string]domain.CachedRoute)
+	for _, key := range keys {
+		if route, ok := mock.Routes[key]; ok {
+			result[key] = route
+		}
+	}
+	return result, nil
+}
+
+func (mock *MockMatrixCache) Set(routes map[string]domain.CachedRoute) error {
+	for key, route := range routes {
+		mock.Routes[key] = route
+	}
+	return nil
+}
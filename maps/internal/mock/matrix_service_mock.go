@@ -3,6 +3,8 @@ package mock
 import (
 	"fmt"
 	"maps_service/internal/domain"
+	"sync"
+	"time"
 )
 
 type ReturnData struct {
@@ -19,10 +21,66 @@ func NewMockMatrix(data map[string]ReturnData) *MockMatrix {
 	return &MockMatrix{data: data}
 }
 
-func (mock *MockMatrix) Get(_ []domain.Point, _, _ []int, transport string) ([][]int, [][]int, error) {
+func (mock *MockMatrix) Get(_ []domain.Point, _, _ []int, transport string, _ *time.Time) ([][]int, [][]int, error) {
 	val, ok := mock.data[transport]
 	if ok {
 		return val.Distance, val.Duration, val.E
 	}
 	return nil, nil, fmt.Errorf("no matrix for transport: %s", transport)
 }
+
+type MockPointsMatrix struct {
+	Calls      [][]domain.Point
+	Departures []*time.Time
+	route      func(from, to domain.Point) (int, int)
+	mu         sync.Mutex
+}
+
+func NewMockPointsMatrix(route func(from, to domain.Point) (int, int)) *MockPointsMatrix {
+	return &MockPointsMatrix{route: route}
+}
+
+func (mock *MockPointsMatrix) Get(points []domain.Point, sources, targets []int, _ string, departure *time.Time) ([][]int, [][]int, error) {
+	mock.mu.Lock()
+	mock.Calls = append(mock.Calls, points)
+	mock.Departures = append(mock.Departures, departure)
+	mock.mu.Unlock()
+
+	distance := make([][]int, len(points))
+	duration := make([][]int, len(points))
+	for i := range points {
+		distance[i] = make([]int, len(points))
+		duration[i] = make([]int, len(points))
+		for j := range points {
+			distance[i][j], duration[i][j] = -1, -1
+		}
+	}
+
+	for _, source := range sources {
+		for _, target := range targets {
+			distance[source][target], duration[source][target] = mock.route(points[source], points[target])
+		}
+	}
+
+	return distance, duration, nil
+}
+
+// MockFlakyMatrix fails with the given error the first Failures calls.
+type MockFlakyMatrix struct {
+	Calls    int
+	Failures int
+	E        error
+	matrix   domain.IMatrixService
+}
+
+func NewMockFlakyMatrix(matrix domain.IMatrixService, failures int, e error) *MockFlakyMatrix {
+	return &MockFlakyMatrix{Failures: failures, E: e, matrix: matrix}
+}
+
+func (mock *MockFlakyMatrix) Get(points []domain.Point, sources, targets []int, transport string, departure *time.Time) ([][]int, [][]int, error) {
+	mock.Calls += 1
+	if mock.Calls <= mock.Failures {
+		return nil, nil, mock.E
+	}
+	return mock.matrix.Get(points, sources, targets, transport, departure)
+}
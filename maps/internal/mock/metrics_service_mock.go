@@ -0,0 +1,18 @@
+package mock
+
+type MockMetrics struct {
+	Hits   int
+	Misses int
+}
+
+func NewMockMetrics() *MockMetrics {
+	return &MockMetrics{}
+}
+
+func (mock *MockMetrics) AddMatrixCacheHits(_ string, count int) {
+	mock.Hits += count
+}
+
+func (mock *MockMetrics) AddMatrixCacheMisses(_ string, count int) {
+	mock.Misses += count
+}
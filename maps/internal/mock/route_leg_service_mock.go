@@ -0,0 +1,40 @@
+package mock
+
+import (
+	"fmt"
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+)
+
+// MockRouteLeg goes along the straight line with the given speed in metres
+// per second for each transport.
+type MockRouteLeg struct {
+	Calls  int
+	speeds map[string]float64
+}
+
+func NewMockRouteLeg(speeds map[string]float64) *MockRouteLeg {
+	return &MockRouteLeg{speeds: speeds}
+}
+
+func (mock *MockRouteLeg) Get(from, to domain.Point, transport string) (*domain.RouteLeg, error) {
+	mock.Calls += 1
+
+	speed, ok := mock.speeds[transport]
+	if !ok {
+		return nil, fmt.Errorf("no route for transport: %s", transport)
+	}
+
+	distance := geo.Distance(from, to)
+	duration := int(distance / speed)
+
+	return &domain.RouteLeg{
+		Distance: int(distance),
+		Duration: duration,
+		Geometry: domain.LineString{Type: "LineString", Coordinates: [][2]float64{{from.Lon, from.Lat}, {to.Lon, to.Lat}}},
+		Maneuvers: []domain.Maneuver{
+			{Type: "begin", Distance: int(distance), Duration: duration, Location: from},
+			{Type: "end", Location: to},
+		},
+	}, nil
+}
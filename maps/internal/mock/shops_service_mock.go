@@ -3,20 +3,34 @@ package mock
 import (
 	"fmt"
 	"maps_service/internal/domain"
+	"sync"
 )
 
 type MockShopInfo struct {
+	Calls     int
 	shopsInfo map[string]domain.ShopInfo
+	mu        sync.Mutex
 }
 
 func NewMockShopInfo(shopsInfo map[string]domain.ShopInfo) *MockShopInfo {
 	return &MockShopInfo{shopsInfo: shopsInfo}
 }
 
-func (mock *MockShopInfo) Get(shop string, _ domain.Point, _ int64) (*domain.ShopInfo, error) {
-	val, ok := mock.shopsInfo[shop]
+// SetShopsInfo replaces the places returned by the next calls.
+func (mock *MockShopInfo) SetShopsInfo(shopsInfo map[string]domain.ShopInfo) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.shopsInfo = shopsInfo
+}
+
+func (mock *MockShopInfo) Get(chain domain.Chain, _ domain.Point, _ int64) (*domain.ShopInfo, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	mock.Calls += 1
+	val, ok := mock.shopsInfo[chain.Name]
 	if ok {
 		return &val, nil
 	}
-	return nil, fmt.Errorf("no such shop: %s", shop)
+	return nil, fmt.Errorf("no such shop: %s", chain.Name)
 }
@@ -0,0 +1,212 @@
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	ModePassthrough = "passthrough"
+	ModeRecord      = "record"
+	ModeReplay      = "replay"
+)
+
+// Secrets are replaced with this value in the fixtures, before the request is
+// matched, so a fixture recorded with one key is replayed with any other.
+const scrubbedSecret = "SCRUBBED"
+
+// The query parameters scrubbed even if the value is not given as a secret.
+var secretParams = []string{"key"}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9.]+`)
+
+type fixtureRequest struct {
+	Method string `json:"method"`
+	Url    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type fixtureResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+type fixture struct {
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+// Transport records the request and response pairs to the fixture files or
+// serves the requests from them. In the passthrough mode it only calls the
+// next transport.
+type Transport struct {
+	next    http.RoundTripper
+	mode    string
+	dir     string
+	secrets []string
+}
+
+// NewTransport creates the transport, nil next means http.DefaultTransport.
+func NewTransport(next http.RoundTripper, mode, dir string, secrets ...string) (*Transport, error) {
+	switch mode {
+	case ModePassthrough:
+	case ModeRecord, ModeReplay:
+		if dir == "" {
+			return nil, fmt.Errorf("fixtures dir is required in %s mode", mode)
+		}
+	default:
+		return nil, fmt.Errorf("unknown http transport mode: %s", mode)
+	}
+
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	nonEmpty := []string{}
+	for _, secret := range secrets {
+		if secret != "" {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+	return &Transport{next: next, mode: mode, dir: dir, secrets: nonEmpty}, nil
+}
+
+// NewClient returns a client sending the requests through the transport.
+func NewClient(next http.RoundTripper, mode, dir string, secrets ...string) (*http.Client, error) {
+	transport, err := NewTransport(next, mode, dir, secrets...)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+func (transport *Transport) scrub(value string) string {
+	for _, secret := range transport.secrets {
+		value = strings.ReplaceAll(value, secret, scrubbedSecret)
+	}
+	return value
+}
+
+func (transport *Transport) scrubUrl(req *http.Request) string {
+	u := *req.URL
+	query := u.Query()
+	for _, param := range secretParams {
+		if query.Has(param) {
+			query.Set(param, scrubbedSecret)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return transport.scrub(u.String())
+}
+
+// fixturePath names the file by the endpoint and the hash of the scrubbed
+// request, so the same request is always read from the same file.
+func (transport *Transport) fixturePath(request fixtureRequest, req *http.Request) string {
+	hash := sha256.Sum256([]byte(request.Method + " " + request.Url + "\n" + request.Body))
+	name := unsafeFileChars.ReplaceAllString(req.URL.Host+req.URL.Path, "_")
+	return filepath.Join(transport.dir, fmt.Sprintf("%s_%s_%s.json", request.Method, name, hex.EncodeToString(hash[:])[:12]))
+}
+
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport.mode == ModePassthrough {
+		return transport.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("can't read request body: %+v", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	request := fixtureRequest{Method: req.Method, Url: transport.scrubUrl(req), Body: transport.scrub(string(body))}
+	path := transport.fixturePath(request, req)
+
+	if transport.mode == ModeReplay {
+		return transport.replay(req, request, path)
+	}
+	return transport.record(req, request, path)
+}
+
+func (transport *Transport) replay(req *http.Request, request fixtureRequest, path string) (*http.Response, error) {
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no fixture for %s %s", request.Method, request.Url)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read fixture: %+v", err)
+	}
+
+	var recorded fixture
+	if err := json.Unmarshal(bytes, &recorded); err != nil {
+		return nil, fmt.Errorf("can't unmarshal fixture %s: %+v", path, err)
+	}
+
+	return createResponse(req, recorded.Response), nil
+}
+
+// record saves only the successful responses, an error of the API, e.g. of a
+// wrong key or of the rate limit, is passed to the caller and is not replayed
+// later as if the API had answered it.
+func (transport *Transport) record(req *http.Request, request fixtureRequest, path string) (*http.Response, error) {
+	resp, err := transport.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("can't read response body: %+v", err)
+	}
+
+	response := fixtureResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(body)}
+	if resp.StatusCode < 200 || 300 <= resp.StatusCode {
+		log.Printf("response %d of %s %s is not recorded", resp.StatusCode, request.Method, request.Url)
+		return createResponse(req, response), nil
+	}
+
+	response.Body = transport.scrub(response.Body)
+	bytes, err := json.MarshalIndent(fixture{Request: request, Response: response}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal fixture: %+v", err)
+	}
+	if err := os.MkdirAll(transport.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("can't create fixtures dir: %+v", err)
+	}
+	if err := os.WriteFile(path, bytes, 0o644); err != nil {
+		return nil, fmt.Errorf("can't write fixture: %+v", err)
+	}
+
+	// The caller gets the response as it was received, not the scrubbed one.
+	response.Body = string(body)
+	return createResponse(req, response), nil
+}
+
+func createResponse(req *http.Request, response fixtureResponse) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        response.Header,
+		Body:          io.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"fmt"
+	"maps_service/internal/domain"
+	"slices"
+	"time"
+)
+
+// NextOpening looks for the opening this far ahead, a weekly schedule repeats
+// after it.
+const nextOpeningHorizon = 8 * 24 * time.Hour
+
+// Range is a part of a day in offsets from its midnight. A range going over
+// midnight ends after 24 hours.
+type Range struct {
+	From time.Duration
+	To   time.Duration
+}
+
+type Interval struct {
+	From time.Time
+	To   time.Time
+}
+
+// Schedule is the parsed domain.Schedule. A schedule without ranges is
+// considered always open, 2GIS omits the schedule for such places.
+type Schedule struct {
+	days     [7][]Range
+	location *time.Location
+}
+
+func parseClock(value string) (time.Duration, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hours, &minutes); err != nil {
+		return 0, fmt.Errorf("invalid time %q: %+v", value, err)
+	}
+	if hours < 0 || hours > 24 || minutes < 0 || minutes >= 60 || hours == 24 && minutes != 0 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func getDaySchedule(schedule *domain.Schedule, weekday time.Weekday) domain.DaySchedule {
+	return map[time.Weekday]domain.DaySchedule{
+		time.Monday:    schedule.Monday,
+		time.Tuesday:   schedule.Tuesday,
+		time.Wednesday: schedule.Wednesday,
+		time.Thursday:  schedule.Thursday,
+		time.Friday:    schedule.Friday,
+		time.Saturday:  schedule.Saturday,
+		time.Sunday:    schedule.Sunday,
+	}[weekday]
+}
+
+// Parse reads the hours in the given location. With nil location the hours
+// are read in the location of the time passed to each query.
+func Parse(schedule *domain.Schedule, location *time.Location) (*Schedule, error) {
+	result := &Schedule{location: location}
+	if schedule == nil {
+		return result, nil
+	}
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		for _, hours := range getDaySchedule(schedule, weekday).WorkingHours {
+			from, err := parseClock(hours.From)
+			if err != nil {
+				return nil, err
+			}
+			to, err := parseClock(hours.To)
+			if err != nil {
+				return nil, err
+			}
+			if to <= from {
+				to += 24 * time.Hour
+			}
+			result.days[weekday] = append(result.days[weekday], Range{From: from, To: to})
+		}
+	}
+
+	return result, nil
+}
+
+func (schedule *Schedule) IsAlwaysOpen() bool {
+	for _, ranges := range schedule.days {
+		if len(ranges) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (schedule *Schedule) in(t time.Time) time.Time {
+	if schedule.location == nil {
+		return t
+	}
+	return t.In(schedule.location)
+}
+
+// Intervals returns the merged opening intervals of the days from the one of
+// from through the one of to. An interval is skipped when it is over by from,
+// so the one started on the previous day is returned only if it is still open.
+func (schedule *Schedule) Intervals(from, to time.Time) []Interval {
+	from, to = schedule.in(from), schedule.in(to)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, to.Location())
+
+	intervals := []Interval{}
+	for day := -1; ; day++ {
+		midnight := time.Date(from.Year(), from.Month(), from.Day()+day, 0, 0, 0, 0, from.Location())
+		if midnight.After(last) {
+			break
+		}
+
+		for _, dayRange := range schedule.days[midnight.Weekday()] {
+			interval := Interval{From: midnight.Add(dayRange.From), To: midnight.Add(dayRange.To)}
+			if interval.To.After(from) {
+				intervals = append(intervals, interval)
+			}
+		}
+	}
+
+	slices.SortFunc(intervals, func(a, b Interval) int { return a.From.Compare(b.From) })
+
+	merged := []Interval{}
+	for _, interval := range intervals {
+		if len(merged) > 0 && !interval.From.After(merged[len(merged)-1].To) {
+			if interval.To.After(merged[len(merged)-1].To) {
+				merged[len(merged)-1].To = interval.To
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+func (schedule *Schedule) IsOpenAt(t time.Time) bool {
+	if schedule.IsAlwaysOpen() {
+		return true
+	}
+	for _, interval := range schedule.Intervals(t, t) {
+		if !interval.From.After(t) && interval.To.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpening returns t itself for an open place and the nearest opening
+// otherwise, false means the place is never open.
+func (schedule *Schedule) NextOpening(t time.Time) (time.Time, bool) {
+	if schedule.IsAlwaysOpen() {
+		return t, true
+	}
+	intervals := schedule.Intervals(t, t.Add(nextOpeningHorizon))
+	if len(intervals) == 0 {
+		return time.Time{}, false
+	}
+	if intervals[0].From.After(t) {
+		return intervals[0].From, true
+	}
+	return t, true
+}
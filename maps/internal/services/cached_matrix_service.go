@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"maps_service/internal/domain"
+	"slices"
+	"time"
+)
+
+// Points are rounded to 4 decimal places (about 10 metres) before they are
+// used as a part of the cache key, so that the same shop requested with a
+// slightly different precision still hits the cache.
+const cachePointPrecision = 4
+
+// Traffic dependent routes are cached separately for each time-of-day bucket
+// of the departure.
+const cacheDepartureBucket = time.Hour
+
+type CachedMatrixService struct {
+	matrixService  domain.IMatrixService
+	cache          domain.IMatrixCache
+	metricsService domain.IMetricsService
+}
+
+func NewCachedMatrixService(matrixService domain.IMatrixService, cache domain.IMatrixCache, metricsService domain.IMetricsService) *CachedMatrixService {
+	return &CachedMatrixService{matrixService: matrixService, cache: cache, metricsService: metricsService}
+}
+
+func formatCachePoint(point domain.Point) string {
+	return fmt.Sprintf("%.*f,%.*f", cachePointPrecision, point.Lon, cachePointPrecision, point.Lat)
+}
+
+// getCacheTransport returns the transport part of the key, it includes the
+// bucket only for the routes depending on traffic.
+func getCacheTransport(transport string, departure *time.Time) string {
+	if departure == nil || !slices.Contains(TRAFFIC_TRANSPORT_TYPES, transport) {
+		return transport
+	}
+	timeOfDay := time.Duration(departure.Hour())*time.Hour + time.Duration(departure.Minute())*time.Minute
+	return fmt.Sprintf("%s@%d", transport, timeOfDay/cacheDepartureBucket)
+}
+
+func getCacheKey(from, to domain.Point, transport string) string {
+	return fmt.Sprintf("%s:%s:%s", transport, formatCachePoint(from), formatCachePoint(to))
+}
+
+func (service *CachedMatrixService) Get(points []domain.Point, sources, targets []int, transport string, departure *time.Time) ([][]int, [][]int, error) {
+	if len(points) < 2 {
+		return service.matrixService.Get(points, sources, targets, transport, departure)
+	}
+
+	cacheTransport := getCacheTransport(transport, departure)
+
+	keys := []string{}
+	for _, source := range sources {
+		for _, target := range targets {
+			if source != target {
+				keys = append(keys, getCacheKey(points[source], points[target], cacheTransport))
+			}
+		}
+	}
+
+	cached, err := service.cache.Get(keys)
+	if err != nil {
+		log.Printf("matrix cache is unavailable: %+v", err)
+		cached = map[string]domain.CachedRoute{}
+	}
+
+	n := len(points)
+	distanceMatrix := createMatrix(n, n)
+	durationMatrix := createMatrix(n, n)
+
+	hits, misses := 0, 0
+	missingSources := []int{}
+	missingTargets := []int{}
+	isMissingSource := make(map[int]struct{})
+	isMissingTarget := make(map[int]struct{})
+
+	for _, source := range sources {
+		for _, target := range targets {
+			if source == target {
+				distanceMatrix[source][target] = 0
+				durationMatrix[source][target] = 0
+				continue
+			}
+
+			if route, ok := cached[getCacheKey(points[source], points[target], cacheTransport)]; ok {
+				distanceMatrix[source][target] = route.Distance
+				durationMatrix[source][target] = route.Duration
+				hits += 1
+				continue
+			}
+
+			misses += 1
+			if _, ok := isMissingSource[source]; !ok {
+				isMissingSource[source] = struct{}{}
+				missingSources = append(missingSources, source)
+			}
+			if _, ok := isMissingTarget[target]; !ok {
+				isMissingTarget[target] = struct{}{}
+				missingTargets = append(missingTargets, target)
+			}
+		}
+	}
+
+	service.metricsService.AddMatrixCacheHits(transport, hits)
+	service.metricsService.AddMatrixCacheMisses(transport, misses)
+
+	if len(missingSources) == 0 {
+		return distanceMatrix, durationMatrix, nil
+	}
+
+	// Only the points taking part in the missing pairs are sent upstream,
+	// so the request is reindexed into a smaller set of points.
+	subPoints := []domain.Point{}
+	subIndex := make(map[int]int)
+	toSubIndex := func(indices []int) []int {
+		result := []int{}
+		for _, index := range indices {
+			if _, ok := subIndex[index]; !ok {
+				subIndex[index] = len(subPoints)
+				subPoints = append(subPoints, points[index])
+			}
+			result = append(result, subIndex[index])
+		}
+		return result
+	}
+	subSources := toSubIndex(missingSources)
+	subTargets := toSubIndex(missingTargets)
+
+	subDistance, subDuration, err := service.matrixService.Get(subPoints, subSources, subTargets, transport, departure)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	routes := make(map[string]domain.CachedRoute)
+	for _, source := range missingSources {
+		for _, target := range missingTargets {
+			if source == target {
+				continue
+			}
+
+			distance := subDistance[subIndex[source]][subIndex[target]]
+			duration := subDuration[subIndex[source]][subIndex[target]]
+			distanceMatrix[source][target] = distance
+			durationMatrix[source][target] = duration
+
+			if distance != -1 && duration != -1 {
+				routes[getCacheKey(points[source], points[target], cacheTransport)] = domain.CachedRoute{Distance: distance, Duration: duration}
+			}
+		}
+	}
+
+	if len(routes) > 0 {
+		if err := service.cache.Set(routes); err != nil {
+			log.Printf("can't save routes to matrix cache: %+v", err)
+		}
+	}
+
+	return distanceMatrix, durationMatrix, nil
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps_service/internal/domain"
+	"os"
+)
+
+type ChainRegistry struct {
+	chains []domain.Chain
+}
+
+func NewChainRegistry(chains []domain.Chain) *ChainRegistry {
+	return &ChainRegistry{chains: chains}
+}
+
+func LoadChainRegistry(path string) (*ChainRegistry, error) {
+	jsonFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't load chains config file: %+v", err)
+	}
+	defer jsonFile.Close()
+
+	bytes, err := io.ReadAll(jsonFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read chains config file: %+v", err)
+	}
+
+	var chains []domain.Chain
+	if err := json.Unmarshal(bytes, &chains); err != nil {
+		return nil, fmt.Errorf("can't unmarshal chains config: %+v", err)
+	}
+
+	for _, chain := range chains {
+		if chain.Name == "" || chain.Query == "" {
+			return nil, fmt.Errorf("chain without name or query in config: %+v", chain)
+		}
+	}
+
+	return NewChainRegistry(chains), nil
+}
+
+func (registry *ChainRegistry) GetEnabled() []domain.Chain {
+	result := []domain.Chain{}
+	for _, chain := range registry.chains {
+		if chain.Enabled {
+			result = append(result, chain)
+		}
+	}
+	return result
+}
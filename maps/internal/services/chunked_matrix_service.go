@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps_service/internal/domain"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const chunkedMatrixRetryDelay = 200 * time.Millisecond
+
+type ChunkedMatrixSettings struct {
+	// Max count of sources and of targets in one upstream request.
+	ChunkSize   int
+	Concurrency int
+	// Requests per second and burst of the token bucket.
+	RateLimit float64
+	RateBurst int
+	Retries   int
+}
+
+// ChunkedMatrixService splits a matrix of any size into requests fitting the
+// upstream limits and sends them concurrently under a rate limit.
+type ChunkedMatrixService struct {
+	matrixService domain.IMatrixService
+	settings      ChunkedMatrixSettings
+	limiter       *rate.Limiter
+}
+
+func NewChunkedMatrixService(matrixService domain.IMatrixService, settings ChunkedMatrixSettings) *ChunkedMatrixService {
+	return &ChunkedMatrixService{
+		matrixService: matrixService,
+		settings:      settings,
+		limiter:       rate.NewLimiter(rate.Limit(settings.RateLimit), settings.RateBurst),
+	}
+}
+
+type matrixChunk struct {
+	sources []int
+	targets []int
+}
+
+func splitIntoChunks(indices []int, size int) [][]int {
+	result := [][]int{}
+	for i := 0; i < len(indices); i += size {
+		result = append(result, indices[i:min(len(indices), i+size)])
+	}
+	return result
+}
+
+func (service *ChunkedMatrixService) Get(points []domain.Point, sources, targets []int, transport string, departure *time.Time) ([][]int, [][]int, error) {
+	if len(points) < 2 {
+		return service.matrixService.Get(points, sources, targets, transport, departure)
+	}
+
+	chunks := []matrixChunk{}
+	for _, sourcesChunk := range splitIntoChunks(sources, service.settings.ChunkSize) {
+		for _, targetsChunk := range splitIntoChunks(targets, service.settings.ChunkSize) {
+			chunks = append(chunks, matrixChunk{sources: sourcesChunk, targets: targetsChunk})
+		}
+	}
+
+	n := len(points)
+	distanceMatrix := createMatrix(n, n)
+	durationMatrix := createMatrix(n, n)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var returnErr error
+	semaphore := make(chan struct{}, service.settings.Concurrency)
+
+	for _, chunk := range chunks {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(chunk matrixChunk) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			// Every chunk writes only its own cells, so the matrices are
+			// filled without locking.
+			if err := service.getChunk(points, chunk, transport, departure, distanceMatrix, durationMatrix); err != nil {
+				once.Do(func() { returnErr = err })
+			}
+		}(chunk)
+	}
+
+	wg.Wait()
+
+	if returnErr != nil {
+		return nil, nil, returnErr
+	}
+	return distanceMatrix, durationMatrix, nil
+}
+
+func (service *ChunkedMatrixService) getChunk(points []domain.Point, chunk matrixChunk, transport string, departure *time.Time, distanceMatrix, durationMatrix [][]int) error {
+	subPoints := []domain.Point{}
+	subIndex := make(map[int]int)
+	toSubIndex := func(indices []int) []int {
+		result := []int{}
+		for _, index := range indices {
+			if _, ok := subIndex[index]; !ok {
+				subIndex[index] = len(subPoints)
+				subPoints = append(subPoints, points[index])
+			}
+			result = append(result, subIndex[index])
+		}
+		return result
+	}
+	subSources := toSubIndex(chunk.sources)
+	subTargets := toSubIndex(chunk.targets)
+
+	// Upstream rejects a single point, the only pair of it is the point itself.
+	if len(subPoints) < 2 {
+		distanceMatrix[chunk.sources[0]][chunk.targets[0]] = 0
+		durationMatrix[chunk.sources[0]][chunk.targets[0]] = 0
+		return nil
+	}
+
+	subDistance, subDuration, err := service.getWithRetries(subPoints, subSources, subTargets, transport, departure)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range chunk.sources {
+		for _, target := range chunk.targets {
+			distanceMatrix[source][target] = subDistance[subIndex[source]][subIndex[target]]
+			durationMatrix[source][target] = subDuration[subIndex[source]][subIndex[target]]
+		}
+	}
+	return nil
+}
+
+func (service *ChunkedMatrixService) getWithRetries(points []domain.Point, sources, targets []int, transport string, departure *time.Time) ([][]int, [][]int, error) {
+	delay := chunkedMatrixRetryDelay
+
+	for attempt := 0; ; attempt++ {
+		if err := service.limiter.Wait(context.Background()); err != nil {
+			return nil, nil, err
+		}
+
+		distanceMatrix, durationMatrix, err := service.matrixService.Get(points, sources, targets, transport, departure)
+		if err == nil {
+			return distanceMatrix, durationMatrix, nil
+		}
+
+		var upstreamErr *domain.UpstreamError
+		if attempt >= service.settings.Retries || !errors.As(err, &upstreamErr) || !upstreamErr.Temporary() {
+			return nil, nil, err
+		}
+
+		log.Printf("matrix request failed, retry in %s: %+v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps_service/internal/domain"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type diskCacheEntry struct {
+	Route     domain.CachedRoute `json:"route"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
+
+// DiskMatrixCache keeps the whole cache in memory and rewrites the json file
+// on every update, which is enough for the few thousand shop pairs of a city.
+type DiskMatrixCache struct {
+	path    string
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]diskCacheEntry
+}
+
+func NewDiskMatrixCache(path string, ttl time.Duration) (*DiskMatrixCache, error) {
+	cache := &DiskMatrixCache{path: path, ttl: ttl, entries: make(map[string]diskCacheEntry)}
+
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read matrix cache file: %+v", err)
+	}
+
+	if err := json.Unmarshal(bytes, &cache.entries); err != nil {
+		return nil, fmt.Errorf("can't unmarshal matrix cache file: %+v", err)
+	}
+
+	return cache, nil
+}
+
+func (cache *DiskMatrixCache) Get(keys []string) (map[string]domain.CachedRoute, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	result := make(map[string]domain.CachedRoute)
+	for _, key := range keys {
+		if entry, ok := cache.entries[key]; ok && now.Before(entry.ExpiresAt) {
+			result[key] = entry.Route
+		}
+	}
+
+	return result, nil
+}
+
+func (cache *DiskMatrixCache) Set(routes map[string]domain.CachedRoute) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range cache.entries {
+		if !now.Before(entry.ExpiresAt) {
+			delete(cache.entries, key)
+		}
+	}
+	for key, route := range routes {
+		cache.entries[key] = diskCacheEntry{Route: route, ExpiresAt: now.Add(cache.ttl)}
+	}
+
+	bytes, err := json.Marshal(cache.entries)
+	if err != nil {
+		return fmt.Errorf("can't marshal matrix cache: %+v", err)
+	}
+
+	if err := writeFileAtomic(cache.path, bytes); err != nil {
+		return fmt.Errorf("can't write matrix cache file: %+v", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes a temporary file next to the path and renames it, so
+// the file is never left half written.
+func writeFileAtomic(path string, bytes []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
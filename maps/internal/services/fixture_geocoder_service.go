@@ -0,0 +1,82 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"os"
+	"strings"
+)
+
+// An address farther than this from the point is not returned by the reverse
+// geocoding.
+const fixtureReverseGeocodeDistance = 300
+
+// FixtureGeocoderService answers from a fixed list of addresses, it is used
+// in tests and to run the service without 2GIS.
+type FixtureGeocoderService struct {
+	addresses []domain.Address
+}
+
+func NewFixtureGeocoderService(addresses []domain.Address) *FixtureGeocoderService {
+	return &FixtureGeocoderService{addresses: addresses}
+}
+
+func LoadFixtureGeocoderService(path string) (*FixtureGeocoderService, error) {
+	jsonFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't load geocoder fixture file: %+v", err)
+	}
+	defer jsonFile.Close()
+
+	bytes, err := io.ReadAll(jsonFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read geocoder fixture file: %+v", err)
+	}
+
+	var addresses []domain.Address
+	if err := json.Unmarshal(bytes, &addresses); err != nil {
+		return nil, fmt.Errorf("can't unmarshal geocoder fixture: %+v", err)
+	}
+
+	return NewFixtureGeocoderService(addresses), nil
+}
+
+// Geocode returns the addresses containing every word of the query.
+func (geocoder *FixtureGeocoderService) Geocode(query string) ([]domain.Address, error) {
+	words := strings.FieldsFunc(normalizeName(query), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	result := []domain.Address{}
+	for _, address := range geocoder.addresses {
+		name := normalizeName(address.Name)
+		found := len(words) > 0
+		for _, word := range words {
+			if !strings.Contains(name, word) {
+				found = false
+				break
+			}
+		}
+		if found {
+			result = append(result, address)
+		}
+	}
+	return result, nil
+}
+
+func (geocoder *FixtureGeocoderService) ReverseGeocode(point domain.Point) (*domain.Address, error) {
+	var result *domain.Address
+	for i, address := range geocoder.addresses {
+		distance := geo.Distance(point, address.Point)
+		if distance <= fixtureReverseGeocodeDistance && (result == nil || distance < geo.Distance(point, result.Point)) {
+			result = &geocoder.addresses[i]
+		}
+	}
+	if result == nil {
+		return nil, domain.ErrAddressNotFound
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps_service/internal/domain"
+	"net/http"
+	"net/url"
+)
+
+const api_2gis_geocode = "https://catalog.api.2gis.com/3.0/items/geocode?q=%s&fields=items.point,items.full_address_name&key=%s"
+
+const api_2gis_reverse_geocode = "https://catalog.api.2gis.com/3.0/items/geocode?lon=%f&lat=%f&fields=items.point,items.full_address_name&key=%s"
+
+type geocodeItem struct {
+	FullName        string        `json:"full_name"`
+	FullAddressName string        `json:"full_address_name"`
+	Point           *domain.Point `json:"point"`
+}
+
+type geocodeMeta struct {
+	Code int `json:"code"`
+}
+
+type geocodeResp struct {
+	Meta   geocodeMeta `json:"meta"`
+	Result struct {
+		Items []geocodeItem `json:"items"`
+	} `json:"result"`
+}
+
+type Geocoder2GisService struct {
+	apiKey string
+	client *http.Client
+}
+
+func NewGeocoder2GisService(apiKey string, client *http.Client) *Geocoder2GisService {
+	return &Geocoder2GisService{apiKey: apiKey, client: client}
+}
+
+func (geocoder *Geocoder2GisService) Geocode(query string) ([]domain.Address, error) {
+	return geocoder.get(fmt.Sprintf(api_2gis_geocode, url.QueryEscape(query), geocoder.apiKey))
+}
+
+func (geocoder *Geocoder2GisService) ReverseGeocode(point domain.Point) (*domain.Address, error) {
+	addresses, err := geocoder.get(fmt.Sprintf(api_2gis_reverse_geocode, point.Lon, point.Lat, geocoder.apiKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, domain.ErrAddressNotFound
+	}
+	return &addresses[0], nil
+}
+
+func (geocoder *Geocoder2GisService) get(requestUrl string) ([]domain.Address, error) {
+	resp, err := geocoder.client.Get(requestUrl)
+	if err != nil {
+		return nil, &domain.UpstreamError{Message: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %+v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &domain.UpstreamError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	var response geocodeResp
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %+v", err)
+	}
+
+	// 2GIS reports errors in the meta of a successful response, an empty
+	// result is reported as 404.
+	switch response.Meta.Code {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return []domain.Address{}, nil
+	default:
+		return nil, &domain.UpstreamError{StatusCode: response.Meta.Code, Message: string(body)}
+	}
+
+	addresses := []domain.Address{}
+	for _, item := range response.Result.Items {
+		if item.Point == nil {
+			continue
+		}
+		name := item.FullAddressName
+		if name == "" {
+			name = item.FullName
+		}
+		addresses = append(addresses, domain.Address{Name: name, Point: *item.Point})
+	}
+	return addresses, nil
+}
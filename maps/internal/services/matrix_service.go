@@ -7,6 +7,8 @@ import (
 	"io"
 	"maps_service/internal/domain"
 	"net/http"
+	"slices"
+	"time"
 )
 
 const api_2gis_routing = "https://routing.api.2gis.com/get_dist_matrix?key=%s&version=6.0.0"
@@ -16,6 +18,9 @@ type matrixReq struct {
 	Sources   []int          `json:"sources"`
 	Targets   []int          `json:"targets"`
 	Transport string         `json:"transport"`
+	// Traffic statistics for the start time, the time is in UTC.
+	Type      string `json:"type,omitempty"`
+	StartTime string `json:"start_time,omitempty"`
 }
 
 type route struct {
@@ -32,10 +37,11 @@ type matrixResp struct {
 
 type Matrix2GisService struct {
 	apiKey string
+	client *http.Client
 }
 
-func NewMatrix2GisService(apiKey string) *Matrix2GisService {
-	return &Matrix2GisService{apiKey: apiKey}
+func NewMatrix2GisService(apiKey string, client *http.Client) *Matrix2GisService {
+	return &Matrix2GisService{apiKey: apiKey, client: client}
 }
 
 func createMatrix(n, m int) [][]int {
@@ -49,11 +55,11 @@ func createMatrix(n, m int) [][]int {
 	return matrix
 }
 
-func (matrixService *Matrix2GisService) Get(points []domain.Point, sources, targets []int, transport string) (distanceMatrix [][]int, durationMatrix [][]int, returnErr error) {
+func (matrixService *Matrix2GisService) Get(points []domain.Point, sources, targets []int, transport string, departure *time.Time) (distanceMatrix [][]int, durationMatrix [][]int, returnErr error) {
 	distanceMatrix, durationMatrix = nil, nil
 
 	if len(points) < 2 {
-		returnErr = fmt.Errorf("invalid points count")
+		returnErr = fmt.Errorf("%w: invalid points count", domain.ErrInvalidInput)
 		return
 	}
 
@@ -63,6 +69,10 @@ func (matrixService *Matrix2GisService) Get(points []domain.Point, sources, targ
 		Targets:   targets,
 		Transport: transport,
 	}
+	if departure != nil && slices.Contains(TRAFFIC_TRANSPORT_TYPES, transport) {
+		request.Type = "statistics"
+		request.StartTime = departure.UTC().Format(time.RFC3339)
+	}
 
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -70,9 +80,9 @@ func (matrixService *Matrix2GisService) Get(points []domain.Point, sources, targ
 		return
 	}
 
-	resp, err := http.Post(fmt.Sprintf(api_2gis_routing, matrixService.apiKey), "application/json", bytes.NewBuffer(requestBody))
+	resp, err := matrixService.client.Post(fmt.Sprintf(api_2gis_routing, matrixService.apiKey), "application/json", bytes.NewBuffer(requestBody))
 	if err != nil {
-		returnErr = fmt.Errorf("error sending request: %+v", err)
+		returnErr = &domain.UpstreamError{Message: err.Error()}
 		return
 	}
 	defer resp.Body.Close()
@@ -85,7 +95,7 @@ func (matrixService *Matrix2GisService) Get(points []domain.Point, sources, targ
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		returnErr = fmt.Errorf("api returned status %d: %s", resp.StatusCode, string(body))
+		returnErr = &domain.UpstreamError{StatusCode: resp.StatusCode, Message: string(body)}
 		return
 	}
 
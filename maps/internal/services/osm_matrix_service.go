@@ -0,0 +1,454 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"log"
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paulmach/osm"
+	"github.com/paulmach/osm/osmpbf"
+	"github.com/paulmach/osm/osmxml"
+)
+
+const (
+	// Points are snapped to the nearest road node, the way to the road is
+	// counted as walking for every transport.
+	osmSnapSpeed = 5.
+	// Size of the snapping grid cell in degrees, about 500 metres.
+	osmGridCellSize = 0.005
+	osmMaxSnapRings = 4
+)
+
+type osmProfile struct {
+	speeds        map[string]float64
+	accessTags    []string
+	respectOneway bool
+}
+
+var osmProfiles = map[string]osmProfile{
+	"walking": {
+		speeds: map[string]float64{
+			"footway":        5,
+			"path":           5,
+			"pedestrian":     5,
+			"steps":          2,
+			"corridor":       5,
+			"track":          5,
+			"cycleway":       5,
+			"living_street":  5,
+			"residential":    5,
+			"service":        5,
+			"unclassified":   5,
+			"tertiary":       5,
+			"tertiary_link":  5,
+			"secondary":      5,
+			"secondary_link": 5,
+			"primary":        5,
+			"primary_link":   5,
+		},
+		accessTags: []string{"foot", "access"},
+	},
+	"driving": {
+		speeds: map[string]float64{
+			"motorway":       90,
+			"motorway_link":  60,
+			"trunk":          70,
+			"trunk_link":     50,
+			"primary":        60,
+			"primary_link":   40,
+			"secondary":      50,
+			"secondary_link": 35,
+			"tertiary":       40,
+			"tertiary_link":  30,
+			"unclassified":   30,
+			"residential":    25,
+			"living_street":  10,
+			"service":        15,
+		},
+		accessTags:    []string{"motorcar", "motor_vehicle", "access"},
+		respectOneway: true,
+	},
+}
+
+// Taxi has no separate road graph and is routed as driving.
+var osmTransportProfiles = map[string]string{
+	"walking": "walking",
+	"driving": "driving",
+	"taxi":    "driving",
+}
+
+type osmWay struct {
+	nodes []osm.NodeID
+	tags  osm.Tags
+}
+
+type roadEdge struct {
+	to       int
+	distance float64
+	duration float64
+}
+
+type gridCell struct {
+	x, y int
+}
+
+type roadGraph struct {
+	points []domain.Point
+	edges  [][]roadEdge
+	grid   map[gridCell][]int
+}
+
+type OsmMatrixService struct {
+	graphs map[string]*roadGraph
+}
+
+func NewOsmMatrixService(path string) (*OsmMatrixService, error) {
+	nodes, ways, err := readOsm(path)
+	if err != nil {
+		return nil, err
+	}
+
+	service := &OsmMatrixService{graphs: make(map[string]*roadGraph)}
+	for name, profile := range osmProfiles {
+		graph := buildRoadGraph(nodes, ways, profile)
+		log.Printf("Built %s road graph: %d nodes", name, len(graph.points))
+		service.graphs[name] = graph
+	}
+
+	return service, nil
+}
+
+func readOsm(path string) (map[osm.NodeID]domain.Point, []osmWay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't open osm file: %+v", err)
+	}
+	defer file.Close()
+
+	var scanner osm.Scanner
+	if strings.HasSuffix(filepath.Base(path), ".pbf") {
+		pbfScanner := osmpbf.New(context.Background(), file, runtime.GOMAXPROCS(-1))
+		pbfScanner.SkipRelations = true
+		scanner = pbfScanner
+	} else {
+		scanner = osmxml.New(context.Background(), file)
+	}
+	defer scanner.Close()
+
+	nodes := make(map[osm.NodeID]domain.Point)
+	ways := []osmWay{}
+
+	for scanner.Scan() {
+		switch object := scanner.Object().(type) {
+		case *osm.Node:
+			nodes[object.ID] = domain.Point{Lon: object.Lon, Lat: object.Lat}
+		case *osm.Way:
+			if object.Tags.Find("highway") == "" {
+				continue
+			}
+			way := osmWay{tags: object.Tags}
+			for _, node := range object.Nodes {
+				way.nodes = append(way.nodes, node.ID)
+			}
+			ways = append(ways, way)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("can't read osm file: %+v", err)
+	}
+
+	return nodes, ways, nil
+}
+
+func isAccessible(tags osm.Tags, profile osmProfile) bool {
+	for _, key := range profile.accessTags {
+		if value := tags.Find(key); value != "" {
+			return value != "no" && value != "private"
+		}
+	}
+	return true
+}
+
+func getWaySpeed(tags osm.Tags, profile osmProfile) (float64, bool) {
+	speed, ok := profile.speeds[tags.Find("highway")]
+	if !ok || !isAccessible(tags, profile) {
+		return 0, false
+	}
+
+	if fields := strings.Fields(tags.Find("maxspeed")); len(fields) > 0 {
+		if maxSpeed, err := strconv.ParseFloat(fields[0], 64); err == nil && maxSpeed > 0 {
+			speed = math.Min(speed, maxSpeed)
+		}
+	}
+
+	return speed, true
+}
+
+// getWayDirections returns whether the way can be passed along and against
+// the order of its nodes.
+func getWayDirections(tags osm.Tags, profile osmProfile) (bool, bool) {
+	if !profile.respectOneway {
+		return true, true
+	}
+
+	switch tags.Find("oneway") {
+	case "yes", "1", "true":
+		return true, false
+	case "-1", "reverse":
+		return false, true
+	case "no":
+		return true, true
+	}
+
+	if tags.Find("junction") == "roundabout" || tags.Find("highway") == "motorway" {
+		return true, false
+	}
+	return true, true
+}
+
+func toGridCell(point domain.Point) gridCell {
+	return gridCell{x: int(math.Floor(point.Lon / osmGridCellSize)), y: int(math.Floor(point.Lat / osmGridCellSize))}
+}
+
+func buildRoadGraph(nodes map[osm.NodeID]domain.Point, ways []osmWay, profile osmProfile) *roadGraph {
+	graph := &roadGraph{grid: make(map[gridCell][]int)}
+	index := make(map[osm.NodeID]int)
+
+	getIndex := func(id osm.NodeID) int {
+		if i, ok := index[id]; ok {
+			return i
+		}
+		index[id] = len(graph.points)
+		graph.points = append(graph.points, nodes[id])
+		graph.edges = append(graph.edges, nil)
+		return index[id]
+	}
+
+	for _, way := range ways {
+		speed, ok := getWaySpeed(way.tags, profile)
+		if !ok {
+			continue
+		}
+		forward, backward := getWayDirections(way.tags, profile)
+
+		for i := 0; i+1 < len(way.nodes); i++ {
+			_, fromOk := nodes[way.nodes[i]]
+			_, toOk := nodes[way.nodes[i+1]]
+			if !fromOk || !toOk {
+				continue
+			}
+
+			from, to := getIndex(way.nodes[i]), getIndex(way.nodes[i+1])
+			distance := geo.Distance(graph.points[from], graph.points[to])
+			duration := distance / (speed / 3.6)
+
+			if forward {
+				graph.edges[from] = append(graph.edges[from], roadEdge{to: to, distance: distance, duration: duration})
+			}
+			if backward {
+				graph.edges[to] = append(graph.edges[to], roadEdge{to: from, distance: distance, duration: duration})
+			}
+		}
+	}
+
+	// Only the largest connected component is used for snapping, otherwise
+	// a point could be snapped to an isolated piece of road.
+	for _, node := range graph.largestComponent() {
+		cell := toGridCell(graph.points[node])
+		graph.grid[cell] = append(graph.grid[cell], node)
+	}
+
+	return graph
+}
+
+func (graph *roadGraph) largestComponent() []int {
+	parent := genSimpleSequence(len(graph.points))
+	find := func(node int) int {
+		for parent[node] != node {
+			parent[node] = parent[parent[node]]
+			node = parent[node]
+		}
+		return node
+	}
+
+	for from, edges := range graph.edges {
+		for _, edge := range edges {
+			parent[find(from)] = find(edge.to)
+		}
+	}
+
+	components := make(map[int][]int)
+	for node := range graph.points {
+		root := find(node)
+		components[root] = append(components[root], node)
+	}
+
+	var result []int
+	for _, component := range components {
+		if len(component) > len(result) {
+			result = component
+		}
+	}
+	return result
+}
+
+// snap returns the nearest road node and the distance to it, or -1 if there
+// is no road near the point.
+func (graph *roadGraph) snap(point domain.Point) (int, float64) {
+	center := toGridCell(point)
+	result, resultDistance := -1, math.Inf(1)
+	foundRing := -1
+
+	for ring := 0; ring <= osmMaxSnapRings; ring++ {
+		for x := center.x - ring; x <= center.x+ring; x++ {
+			for y := center.y - ring; y <= center.y+ring; y++ {
+				if max(abs(x-center.x), abs(y-center.y)) != ring {
+					continue
+				}
+				for _, node := range graph.grid[gridCell{x: x, y: y}] {
+					if distance := geo.Distance(point, graph.points[node]); distance < resultDistance {
+						result, resultDistance = node, distance
+					}
+				}
+			}
+		}
+
+		// A node of the next ring can still be closer than the one found
+		// in the corner of the current ring, so one more ring is checked.
+		if result != -1 && foundRing == -1 {
+			foundRing = ring
+		}
+		if foundRing != -1 && ring > foundRing {
+			break
+		}
+	}
+
+	return result, resultDistance
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+type roadQueueItem struct {
+	node     int
+	duration float64
+}
+
+type roadQueue []roadQueueItem
+
+func (queue roadQueue) Len() int           { return len(queue) }
+func (queue roadQueue) Less(i, j int) bool { return queue[i].duration < queue[j].duration }
+func (queue roadQueue) Swap(i, j int)      { queue[i], queue[j] = queue[j], queue[i] }
+func (queue *roadQueue) Push(item any)     { *queue = append(*queue, item.(roadQueueItem)) }
+func (queue *roadQueue) Pop() any {
+	old := *queue
+	item := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return item
+}
+
+// shortestPaths runs Dijkstra by duration from the source until every target
+// is settled and returns distance and duration for each reached node.
+func (graph *roadGraph) shortestPaths(source int, targets map[int]struct{}) (map[int]float64, map[int]float64) {
+	distances := map[int]float64{source: 0}
+	durations := map[int]float64{source: 0}
+	settled := make(map[int]struct{})
+	left := len(targets)
+
+	queue := &roadQueue{{node: source, duration: 0}}
+	for queue.Len() > 0 && left > 0 {
+		top := heap.Pop(queue).(roadQueueItem)
+		if _, ok := settled[top.node]; ok {
+			continue
+		}
+		settled[top.node] = struct{}{}
+		if _, ok := targets[top.node]; ok {
+			left -= 1
+		}
+
+		for _, edge := range graph.edges[top.node] {
+			duration := top.duration + edge.duration
+			if old, ok := durations[edge.to]; ok && old <= duration {
+				continue
+			}
+			durations[edge.to] = duration
+			distances[edge.to] = distances[top.node] + edge.distance
+			heap.Push(queue, roadQueueItem{node: edge.to, duration: duration})
+		}
+	}
+
+	return distances, durations
+}
+
+// Get ignores the departure time, the graph has no traffic data.
+func (service *OsmMatrixService) Get(points []domain.Point, sources, targets []int, transport string, _ *time.Time) ([][]int, [][]int, error) {
+	if len(points) < 2 {
+		return nil, nil, fmt.Errorf("%w: invalid points count", domain.ErrInvalidInput)
+	}
+
+	graph, ok := service.graphs[osmTransportProfiles[transport]]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unsupported transport for offline routing: %s", domain.ErrInvalidInput, transport)
+	}
+
+	n := len(points)
+	distanceMatrix := createMatrix(n, n)
+	durationMatrix := createMatrix(n, n)
+
+	snapNodes := make([]int, n)
+	snapDistances := make([]float64, n)
+	for i, point := range points {
+		snapNodes[i], snapDistances[i] = graph.snap(point)
+	}
+
+	targetNodes := make(map[int]struct{})
+	for _, target := range targets {
+		if snapNodes[target] != -1 {
+			targetNodes[snapNodes[target]] = struct{}{}
+		}
+	}
+
+	for _, source := range sources {
+		if snapNodes[source] == -1 {
+			continue
+		}
+
+		distances, durations := graph.shortestPaths(snapNodes[source], targetNodes)
+
+		for _, target := range targets {
+			if source == target {
+				distanceMatrix[source][target] = 0
+				durationMatrix[source][target] = 0
+				continue
+			}
+			if snapNodes[target] == -1 {
+				continue
+			}
+
+			duration, ok := durations[snapNodes[target]]
+			if !ok {
+				continue
+			}
+			snapDistance := snapDistances[source] + snapDistances[target]
+			distanceMatrix[source][target] = int(math.Round(distances[snapNodes[target]] + snapDistance))
+			durationMatrix[source][target] = int(math.Round(duration + snapDistance/(osmSnapSpeed/3.6)))
+		}
+	}
+
+	return distanceMatrix, durationMatrix, nil
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type PrometheusMetricsService struct {
+	matrixCache *prometheus.CounterVec
+}
+
+func NewPrometheusMetricsService() *PrometheusMetricsService {
+	matrixCache := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "maps_matrix_cache_lookups_total",
+			Help: "Total number of distance matrix cache lookups, labeled by transport and result.",
+		},
+		[]string{"transport", "result"},
+	)
+	prometheus.MustRegister(matrixCache)
+
+	return &PrometheusMetricsService{matrixCache: matrixCache}
+}
+
+func (service *PrometheusMetricsService) AddMatrixCacheHits(transport string, count int) {
+	service.matrixCache.WithLabelValues(transport, "hit").Add(float64(count))
+}
+
+func (service *PrometheusMetricsService) AddMatrixCacheMisses(transport string, count int) {
+	service.matrixCache.WithLabelValues(transport, "miss").Add(float64(count))
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	"maps_service/internal/domain"
+)
+
+// Upper bounds of the speeds in metres per second. Shops are first searched in
+// the radius reachable with such a speed and then checked by the travel time.
+var maxTransportSpeeds = map[string]float64{
+	"walking": 2,
+	"taxi":    25,
+	"driving": 25,
+}
+
+// The search radius is limited by the 2GIS catalog.
+const maxShopsRadius = 40000
+
+type ReachableShopsService struct {
+	shopsService  domain.IShopsService
+	matrixService domain.IMatrixService
+}
+
+func NewReachableShopsService(shopsService domain.IShopsService, matrixService domain.IMatrixService) *ReachableShopsService {
+	return &ReachableShopsService{shopsService: shopsService, matrixService: matrixService}
+}
+
+// Get returns the shops reachable from the point within maxDuration seconds.
+func (service *ReachableShopsService) Get(point domain.Point, transport string, maxDuration int) ([]domain.ShopInfo, error) {
+	speed, ok := maxTransportSpeeds[transport]
+	if !ok {
+		return nil, fmt.Errorf("unsupported transport: %s", transport)
+	}
+	if maxDuration <= 0 {
+		return nil, fmt.Errorf("invalid max duration: %d", maxDuration)
+	}
+
+	radius := min(int64(speed*float64(maxDuration)), maxShopsRadius)
+	shops := service.shopsService.GetNearbyShops(point, radius)
+
+	points := []domain.Point{point}
+	targets := []int{}
+	for _, shop := range shops {
+		for _, place := range shop.Info {
+			targets = append(targets, len(points))
+			points = append(points, place.Point)
+		}
+	}
+
+	result := []domain.ShopInfo{}
+	if len(targets) == 0 {
+		return append(result, shops...), nil
+	}
+
+	_, durMatrix, err := service.matrixService.Get(points, []int{0}, targets, transport, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	target := 1
+	for _, shop := range shops {
+		reachable := domain.ShopInfo{Info: []domain.Place{}, Shop: shop.Shop}
+		for _, place := range shop.Info {
+			if duration := durMatrix[0][target]; duration != -1 && duration <= maxDuration {
+				reachable.Info = append(reachable.Info, place)
+			}
+			target += 1
+		}
+		result = append(result, reachable)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps_service/internal/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisMatrixCachePrefix = "matrix:"
+
+type RedisMatrixCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisMatrixCache(addr string, ttl time.Duration) *RedisMatrixCache {
+	options := redis.Options{
+		Addr: addr,
+		DB:   0,
+	}
+
+	return &RedisMatrixCache{client: redis.NewClient(&options), ttl: ttl}
+}
+
+func (cache *RedisMatrixCache) Get(keys []string) (map[string]domain.CachedRoute, error) {
+	result := make(map[string]domain.CachedRoute)
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = redisMatrixCachePrefix + key
+	}
+
+	vals, err := cache.client.MGet(context.Background(), redisKeys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("can't get routes from redis: %+v", err)
+	}
+
+	for i, raw := range vals {
+		str, ok := raw.(string)
+		if !ok {
+			continue
+		}
+
+		var route domain.CachedRoute
+		if err := json.Unmarshal([]byte(str), &route); err != nil {
+			log.Printf("can't unmarshal route for key %q: %+v", redisKeys[i], err)
+			continue
+		}
+
+		result[keys[i]] = route
+	}
+
+	return result, nil
+}
+
+func (cache *RedisMatrixCache) Set(routes map[string]domain.CachedRoute) error {
+	pipe := cache.client.Pipeline()
+	for key, route := range routes {
+		bytes, err := json.Marshal(route)
+		if err != nil {
+			return fmt.Errorf("can't marshal route: %+v", err)
+		}
+		pipe.Set(context.Background(), redisMatrixCachePrefix+key, bytes, cache.ttl)
+	}
+
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		return fmt.Errorf("can't save routes to redis: %+v", err)
+	}
+	return nil
+}
+
+func (cache *RedisMatrixCache) Close() error {
+	return cache.client.Close()
+}
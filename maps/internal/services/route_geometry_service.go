@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"maps_service/internal/domain"
+	"slices"
+)
+
+type RouteGeometryService struct {
+	legService domain.IRouteLegService
+}
+
+func NewRouteGeometryService(legService domain.IRouteLegService) *RouteGeometryService {
+	return &RouteGeometryService{legService: legService}
+}
+
+// Get builds the legs between consecutive points of the route, the route is
+// a sequence of indices in the points the same as in MinTimeRoute.
+func (service *RouteGeometryService) Get(points []domain.Point, route []int, transport string) (*domain.RouteGeometry, error) {
+	if !slices.Contains(TRANSPORT_TYPES, transport) {
+		return nil, fmt.Errorf("unsupported transport: %s", transport)
+	}
+	if len(route) < 2 {
+		return nil, fmt.Errorf("incorrect route length")
+	}
+	for _, point := range route {
+		if point < 0 || len(points) <= point {
+			return nil, fmt.Errorf("incorrect point in route: %d", point)
+		}
+	}
+
+	result := &domain.RouteGeometry{Transport: transport, Legs: []domain.RouteLeg{}}
+
+	for i := 0; i+1 < len(route); i++ {
+		from, to := points[route[i]], points[route[i+1]]
+
+		// A LineString needs two positions, so the leg between the same
+		// points repeats the point to keep the legs along the route.
+		leg := &domain.RouteLeg{
+			Geometry:  domain.LineString{Type: "LineString", Coordinates: [][2]float64{{from.Lon, from.Lat}, {from.Lon, from.Lat}}},
+			Maneuvers: []domain.Maneuver{},
+		}
+		if from != to {
+			var err error
+			if leg, err = service.legService.Get(from, to, transport); err != nil {
+				return nil, err
+			}
+		}
+
+		leg.From, leg.To = route[i], route[i+1]
+		result.Distance += leg.Distance
+		result.Duration += leg.Duration
+		result.Legs = append(result.Legs, *leg)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"net/http"
+)
+
+const api_2gis_route = "https://routing.api.2gis.com/routing/7.0.0/global?key=%s"
+
+type routeReqPoint struct {
+	Type string  `json:"type"`
+	Lon  float64 `json:"lon"`
+	Lat  float64 `json:"lat"`
+}
+
+type routeReq struct {
+	Points    []routeReqPoint `json:"points"`
+	Transport string          `json:"transport"`
+	Output    string          `json:"output"`
+}
+
+type routeGeometry struct {
+	Selection string `json:"selection"`
+}
+
+type routePath struct {
+	Distance int             `json:"distance"`
+	Duration int             `json:"duration"`
+	Geometry []routeGeometry `json:"geometry"`
+}
+
+type routeManeuver struct {
+	Type          string     `json:"type"`
+	TurnDirection string     `json:"turn_direction"`
+	Comment       string     `json:"comment"`
+	OutcomingPath *routePath `json:"outcoming_path"`
+}
+
+type routeResult struct {
+	TotalDistance int             `json:"total_distance"`
+	TotalDuration int             `json:"total_duration"`
+	Maneuvers     []routeManeuver `json:"maneuvers"`
+}
+
+type routeResp struct {
+	Status string        `json:"status"`
+	Result []routeResult `json:"result"`
+}
+
+type RouteLeg2GisService struct {
+	apiKey string
+	client *http.Client
+}
+
+func NewRouteLeg2GisService(apiKey string, client *http.Client) *RouteLeg2GisService {
+	return &RouteLeg2GisService{apiKey: apiKey, client: client}
+}
+
+func (legService *RouteLeg2GisService) Get(from, to domain.Point, transport string) (*domain.RouteLeg, error) {
+	request := routeReq{
+		Points: []routeReqPoint{
+			{Type: "stop", Lon: from.Lon, Lat: from.Lat},
+			{Type: "stop", Lon: to.Lon, Lat: to.Lat},
+		},
+		Transport: transport,
+		Output:    "detailed",
+	}
+
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request body: %+v", err)
+	}
+
+	resp, err := legService.client.Post(fmt.Sprintf(api_2gis_route, legService.apiKey), "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, &domain.UpstreamError{Message: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %+v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &domain.UpstreamError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	var response routeResp
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %+v", err)
+	}
+
+	if response.Status != "OK" || len(response.Result) == 0 {
+		return nil, fmt.Errorf("no route for transport %s: %s", transport, response.Status)
+	}
+
+	return convertRouteResult(response.Result[0], from, to)
+}
+
+func convertRouteResult(result routeResult, from, to domain.Point) (*domain.RouteLeg, error) {
+	leg := &domain.RouteLeg{
+		Distance:  result.TotalDistance,
+		Duration:  result.TotalDuration,
+		Geometry:  domain.LineString{Type: "LineString", Coordinates: [][2]float64{{from.Lon, from.Lat}}},
+		Maneuvers: []domain.Maneuver{},
+	}
+
+	for _, routeManeuver := range result.Maneuvers {
+		last := leg.Geometry.Coordinates[len(leg.Geometry.Coordinates)-1]
+		maneuver := domain.Maneuver{
+			Type:      routeManeuver.Type,
+			Direction: routeManeuver.TurnDirection,
+			Comment:   routeManeuver.Comment,
+			Location:  domain.Point{Lon: last[0], Lat: last[1]},
+		}
+
+		if path := routeManeuver.OutcomingPath; path != nil {
+			maneuver.Distance, maneuver.Duration = path.Distance, path.Duration
+
+			for i, geometry := range path.Geometry {
+				coordinates, err := geo.ParseLineString(geometry.Selection)
+				if err != nil {
+					return nil, err
+				}
+				if i == 0 && len(coordinates) > 0 {
+					maneuver.Location = domain.Point{Lon: coordinates[0][0], Lat: coordinates[0][1]}
+				}
+				leg.Geometry.Coordinates = appendCoordinates(leg.Geometry.Coordinates, coordinates)
+			}
+		}
+
+		leg.Maneuvers = append(leg.Maneuvers, maneuver)
+	}
+
+	leg.Geometry.Coordinates = appendCoordinates(leg.Geometry.Coordinates, [][2]float64{{to.Lon, to.Lat}})
+	return leg, nil
+}
+
+// appendCoordinates skips the coordinates repeating the previous one, the
+// adjacent geometry parts share their ends.
+func appendCoordinates(line, coordinates [][2]float64) [][2]float64 {
+	for _, coordinate := range coordinates {
+		if len(line) == 0 || line[len(line)-1] != coordinate {
+			line = append(line, coordinate)
+		}
+	}
+	return line
+}
@@ -1,12 +1,17 @@
 package services
 
 import (
+	"errors"
 	"log"
 	"maps_service/internal/domain"
+	"time"
 )
 
 var TRANSPORT_TYPES = []string{"walking", "taxi", "driving"}
 
+// Durations of these transports depend on the departure time.
+var TRAFFIC_TRANSPORT_TYPES = []string{"taxi", "driving"}
+
 func genSimpleSequence(n int) []int {
 	if n < 1 {
 		return nil
@@ -18,6 +23,29 @@ func genSimpleSequence(n int) []int {
 	return result
 }
 
+// getTransportStatus classifies the error of routing with the transport.
+func getTransportStatus(transport string, err error) domain.TransportStatus {
+	if err == nil {
+		return domain.TransportStatus{Transport: transport, Status: domain.RouteStatusOk}
+	}
+
+	log.Println(err, transport)
+
+	status := domain.RouteStatusError
+	var upstreamErr *domain.UpstreamError
+	switch {
+	case errors.Is(err, domain.ErrNoPath):
+		status = domain.RouteStatusNoPath
+	case errors.Is(err, domain.ErrInvalidInput):
+		status = domain.RouteStatusInvalidInput
+	case errors.As(err, &upstreamErr) && upstreamErr.QuotaExceeded():
+		status = domain.RouteStatusQuotaExceeded
+	case errors.As(err, &upstreamErr):
+		status = domain.RouteStatusUpstreamError
+	}
+	return domain.TransportStatus{Transport: transport, Status: status, Error: err.Error()}
+}
+
 type RoutingService struct {
 	matrixService domain.IMatrixService
 	tspService    domain.ITSPService
@@ -27,15 +55,15 @@ func NewRoutingService(matrixService domain.IMatrixService, tspService domain.IT
 	return &RoutingService{matrixService: matrixService, tspService: tspService}
 }
 
-func (routing *RoutingService) Get(points []domain.Point, startPoint int, byDistance bool) []domain.MinTimeRoute {
-	result := []domain.MinTimeRoute{}
+func (routing *RoutingService) Get(points []domain.Point, startPoint, endPoint int, byDistance bool, departure *time.Time) domain.RoutingResult {
+	result := domain.RoutingResult{Routes: []domain.MinTimeRoute{}, Statuses: []domain.TransportStatus{}}
 
 	for _, transport := range TRANSPORT_TYPES {
 		sequence := genSimpleSequence(len(points))
-		distMatrix, durMatrix, err := routing.matrixService.Get(points, sequence, sequence, transport)
+		distMatrix, durMatrix, err := routing.matrixService.Get(points, sequence, sequence, transport, departure)
 
 		if err != nil {
-			log.Println(err, transport)
+			result.Statuses = append(result.Statuses, getTransportStatus(transport, err))
 			continue
 		}
 
@@ -47,14 +75,15 @@ func (routing *RoutingService) Get(points []domain.Point, startPoint int, byDist
 				return durMatrix
 			}(),
 			startPoint,
+			endPoint,
 		)
 
+		result.Statuses = append(result.Statuses, getTransportStatus(transport, err))
 		if err != nil {
-			log.Println(err, transport)
 			continue
 		}
 
-		result = append(result, domain.MinTimeRoute{Points: path, Duration: dur, Transport: transport})
+		result.Routes = append(result.Routes, domain.MinTimeRoute{Points: path, Duration: dur, Transport: transport, Optimal: routing.tspService.IsExact(len(points))})
 	}
 
 	return result
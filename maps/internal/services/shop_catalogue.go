@@ -0,0 +1,282 @@
+package services
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Cell size of the places index in metres, about the radius of a usual
+// lookup.
+const catalogueIndexCell = 1000
+
+// A failed sync is repeated after this delay instead of the sync interval.
+const catalogueRetryDelay = 10 * time.Minute
+
+type catalogueSnapshot struct {
+	SyncedAt time.Time                 `json:"synced_at"`
+	Shops    map[string][]domain.Place `json:"shops"`
+}
+
+// ShopCatalogue keeps the places of the enabled chains in the configured
+// cities and serves the lookups inside the cities from memory. The lookups
+// outside them and for the chains not synced yet go to the live service.
+type ShopCatalogue struct {
+	shopInfoService domain.IShopInfoService
+	chainRegistry   domain.IChainRegistry
+	cities          []domain.City
+	path            string
+
+	mu       sync.RWMutex
+	snapshot catalogueSnapshot
+	indexes  map[string]*geo.GridIndex[domain.Place]
+	lastDiff *domain.CatalogueDiff
+}
+
+// NewShopCatalogue loads the places saved by the previous sync when the path
+// is set, with an empty path the catalogue is kept in memory only.
+func NewShopCatalogue(shopInfoService domain.IShopInfoService, chainRegistry domain.IChainRegistry, cities []domain.City, path string) (*ShopCatalogue, error) {
+	catalogue := &ShopCatalogue{
+		shopInfoService: shopInfoService,
+		chainRegistry:   chainRegistry,
+		cities:          cities,
+		path:            path,
+		snapshot:        catalogueSnapshot{Shops: map[string][]domain.Place{}},
+	}
+
+	if path != "" {
+		bytes, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("can't read shops catalogue file: %+v", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(bytes, &catalogue.snapshot); err != nil {
+				return nil, fmt.Errorf("can't unmarshal shops catalogue file: %+v", err)
+			}
+		}
+	}
+
+	catalogue.indexes = createCatalogueIndexes(catalogue.snapshot.Shops)
+	return catalogue, nil
+}
+
+func LoadCities(path string) ([]domain.City, error) {
+	jsonFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't load cities config file: %+v", err)
+	}
+	defer jsonFile.Close()
+
+	bytes, err := io.ReadAll(jsonFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read cities config file: %+v", err)
+	}
+
+	var cities []domain.City
+	if err := json.Unmarshal(bytes, &cities); err != nil {
+		return nil, fmt.Errorf("can't unmarshal cities config: %+v", err)
+	}
+
+	for _, city := range cities {
+		if city.Name == "" || city.Radius <= 0 {
+			return nil, fmt.Errorf("city without name or radius in config: %+v", city)
+		}
+	}
+
+	return cities, nil
+}
+
+func createCatalogueIndexes(shops map[string][]domain.Place) map[string]*geo.GridIndex[domain.Place] {
+	indexes := make(map[string]*geo.GridIndex[domain.Place])
+	for shop, places := range shops {
+		index := geo.NewGridIndex[domain.Place](catalogueIndexCell)
+		for _, place := range places {
+			index.Insert(place.Point, place)
+		}
+		indexes[shop] = index
+	}
+	return indexes
+}
+
+// covers reports whether the circle lies inside one of the cities.
+func (catalogue *ShopCatalogue) covers(point domain.Point, radius int64) bool {
+	for _, city := range catalogue.cities {
+		if geo.Distance(city.Point, point)+float64(radius) <= city.Radius {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the places sorted by the distance from the point.
+func (catalogue *ShopCatalogue) Get(chain domain.Chain, point domain.Point, radius int64) (*domain.ShopInfo, error) {
+	catalogue.mu.RLock()
+	index, ok := catalogue.indexes[chain.Name]
+	catalogue.mu.RUnlock()
+
+	if !ok || !catalogue.covers(point, radius) {
+		return catalogue.shopInfoService.Get(chain, point, radius)
+	}
+
+	places := index.Query(geo.NewCircleArea(point, float64(radius)))
+	slices.SortStableFunc(places, func(a, b domain.Place) int {
+		return cmp.Compare(geo.Distance(point, a.Point), geo.Distance(point, b.Point))
+	})
+	return &domain.ShopInfo{Info: places, Shop: chain.Name}, nil
+}
+
+// fetchChain queries all the tiles of the cities, a chain is synced only if
+// every query succeeds, so that a failure is not reported as closed shops.
+func (catalogue *ShopCatalogue) fetchChain(chain domain.Chain) ([]domain.Place, error) {
+	places := []domain.Place{}
+	found := make(map[string]struct{})
+
+	for _, city := range catalogue.cities {
+		area := geo.NewCircleArea(city.Point, city.Radius)
+		for _, tile := range geo.Tiles(area, areaTileRadius) {
+			shopInfo, err := catalogue.shopInfoService.Get(chain, tile.Center, int64(tile.Radius))
+			if err != nil {
+				return nil, fmt.Errorf("can't sync %s in %s: %+v", chain.Name, city.Name, err)
+			}
+
+			for _, place := range shopInfo.Info {
+				if _, ok := found[place.Id]; ok || !area.Contains(place.Point) {
+					continue
+				}
+				found[place.Id] = struct{}{}
+				places = append(places, place)
+			}
+		}
+	}
+
+	return places, nil
+}
+
+func diffPlaces(shop string, previous, current []domain.Place) ([]domain.CatalogueChange, []domain.CatalogueChange) {
+	previousIds := make(map[string]struct{})
+	for _, place := range previous {
+		previousIds[place.Id] = struct{}{}
+	}
+	currentIds := make(map[string]struct{})
+	for _, place := range current {
+		currentIds[place.Id] = struct{}{}
+	}
+
+	opened, closed := []domain.CatalogueChange{}, []domain.CatalogueChange{}
+	for _, place := range current {
+		if _, ok := previousIds[place.Id]; !ok {
+			opened = append(opened, domain.CatalogueChange{Shop: shop, Place: place})
+		}
+	}
+	for _, place := range previous {
+		if _, ok := currentIds[place.Id]; !ok {
+			closed = append(closed, domain.CatalogueChange{Shop: shop, Place: place})
+		}
+	}
+	return opened, closed
+}
+
+// Sync replaces the places of the enabled chains, a chain failed to sync keeps
+// its previous places. Sync fails only when no chain is synced.
+func (catalogue *ShopCatalogue) Sync() (*domain.CatalogueDiff, error) {
+	catalogue.mu.RLock()
+	previous := catalogue.snapshot
+	catalogue.mu.RUnlock()
+
+	snapshot := catalogueSnapshot{SyncedAt: time.Now(), Shops: map[string][]domain.Place{}}
+	diff := &domain.CatalogueDiff{
+		SyncedAt:         snapshot.SyncedAt,
+		PreviousSyncedAt: previous.SyncedAt,
+		Opened:           []domain.CatalogueChange{},
+		Closed:           []domain.CatalogueChange{},
+	}
+
+	chains := catalogue.chainRegistry.GetEnabled()
+	var lastErr error
+	syncedCount := 0
+	for _, chain := range chains {
+		places, err := catalogue.fetchChain(chain)
+		previousPlaces, synced := previous.Shops[chain.Name]
+		if err != nil {
+			log.Println(err)
+			lastErr = err
+			if synced {
+				snapshot.Shops[chain.Name] = previousPlaces
+			}
+			continue
+		}
+
+		snapshot.Shops[chain.Name] = places
+		syncedCount += 1
+		if synced {
+			opened, closed := diffPlaces(chain.Name, previousPlaces, places)
+			diff.Opened = append(diff.Opened, opened...)
+			diff.Closed = append(diff.Closed, closed...)
+		}
+	}
+
+	if syncedCount == 0 && lastErr != nil {
+		return nil, fmt.Errorf("can't sync shops catalogue: %+v", lastErr)
+	}
+
+	log.Printf("shops catalogue synced: %d opened, %d closed", len(diff.Opened), len(diff.Closed))
+
+	catalogue.mu.Lock()
+	catalogue.snapshot = snapshot
+	catalogue.indexes = createCatalogueIndexes(snapshot.Shops)
+	catalogue.lastDiff = diff
+	catalogue.mu.Unlock()
+
+	if catalogue.path != "" {
+		if err := catalogue.save(snapshot); err != nil {
+			log.Printf("can't save shops catalogue: %+v", err)
+		}
+	}
+
+	return diff, nil
+}
+
+func (catalogue *ShopCatalogue) save(snapshot catalogueSnapshot) error {
+	bytes, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("can't marshal shops catalogue: %+v", err)
+	}
+	if err := writeFileAtomic(catalogue.path, bytes); err != nil {
+		return fmt.Errorf("can't write shops catalogue file: %+v", err)
+	}
+	return nil
+}
+
+func (catalogue *ShopCatalogue) GetLastDiff() *domain.CatalogueDiff {
+	catalogue.mu.RLock()
+	defer catalogue.mu.RUnlock()
+	return catalogue.lastDiff
+}
+
+// Run syncs the catalogue every interval, the first sync is done at once
+// unless the saved places are still fresh.
+func (catalogue *ShopCatalogue) Run(interval time.Duration) {
+	catalogue.mu.RLock()
+	next := catalogue.snapshot.SyncedAt.Add(interval)
+	catalogue.mu.RUnlock()
+
+	for {
+		time.Sleep(time.Until(next))
+
+		if _, err := catalogue.Sync(); err != nil {
+			log.Println(err)
+			next = time.Now().Add(catalogueRetryDelay)
+			continue
+		}
+		next = time.Now().Add(interval)
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"log"
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Places closer than this in metres and with similar names are the same shop
+// returned twice, for another entrance or for another chain of the owner.
+const duplicatePlaceDistance = 50
+
+// Share of the name words two duplicate places have in common.
+const duplicateNameSimilarity = 0.5
+
+// formatKeywords are checked in order, so that "Перекрёсток Экспресс,
+// супермаркет" is an express and not a supermarket.
+var formatKeywords = []struct {
+	format   string
+	keywords []string
+}{
+	{domain.FormatCosmetics, []string{"косметик", "парфюм"}},
+	{domain.FormatExpress, []string{"экспресс", "у дома", "минимаркет"}},
+	{domain.FormatHypermarket, []string{"гипер", "впрок"}},
+	{domain.FormatSupermarket, []string{"супермаркет"}},
+}
+
+// ClassifyFormat returns the format of the place by its names and rubrics, a
+// place of no known format is a supermarket.
+func ClassifyFormat(chain domain.Chain, name string, rubrics []string) string {
+	name = normalizeName(name)
+
+	for _, format := range domain.PLACE_FORMATS {
+		for _, subBrand := range chain.Formats[format] {
+			if strings.Contains(name, normalizeName(subBrand)) {
+				return format
+			}
+		}
+	}
+
+	for _, formatKeyword := range formatKeywords {
+		for _, keyword := range formatKeyword.keywords {
+			if strings.Contains(name, keyword) {
+				return formatKeyword.format
+			}
+		}
+	}
+
+	for _, rubric := range rubrics {
+		rubric = normalizeName(rubric)
+		for _, formatKeyword := range formatKeywords {
+			for _, keyword := range formatKeyword.keywords {
+				if strings.Contains(rubric, keyword) {
+					return formatKeyword.format
+				}
+			}
+		}
+	}
+
+	return domain.FormatSupermarket
+}
+
+// FilterShopFormats keeps the places of the included formats, all of them
+// with empty include, and drops the excluded ones.
+func FilterShopFormats(shops []domain.ShopInfo, include, exclude []string) []domain.ShopInfo {
+	result := []domain.ShopInfo{}
+	for _, shop := range shops {
+		filtered := domain.ShopInfo{Info: []domain.Place{}, Shop: shop.Shop}
+		for _, place := range shop.Info {
+			if (len(include) == 0 || slices.Contains(include, place.Format)) && !slices.Contains(exclude, place.Format) {
+				filtered.Info = append(filtered.Info, place)
+			}
+		}
+		result = append(result, filtered)
+	}
+	return result
+}
+
+func nameWords(name string) []string {
+	return strings.FieldsFunc(normalizeName(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameSimilarity is the share of the common words among all words of both
+// names.
+func nameSimilarity(a, b string) float64 {
+	wordsA, wordsB := nameWords(a), nameWords(b)
+	common, all := 0, len(wordsB)
+	for _, word := range wordsA {
+		if slices.Contains(wordsB, word) {
+			common += 1
+		} else {
+			all += 1
+		}
+	}
+	if all == 0 {
+		return 0
+	}
+	return float64(common) / float64(all)
+}
+
+// isDuplicatePlace tells the same place listed twice. Near places of different
+// formats, e.g. a supermarket and a cosmetics shop of the chain in one mall,
+// are different shops even with similar names.
+func isDuplicatePlace(a, b domain.Place) bool {
+	if a.Id != "" && a.Id == b.Id {
+		return true
+	}
+	return a.Format == b.Format && geo.Distance(a.Point, b.Point) <= duplicatePlaceDistance && nameSimilarity(a.Name, b.Name) >= duplicateNameSimilarity
+}
+
+// mergeDuplicatePlaces keeps the first of the duplicate places, the chains
+// earlier in the config take precedence.
+func mergeDuplicatePlaces(shops []domain.ShopInfo) []domain.ShopInfo {
+	var result []domain.ShopInfo
+	kept := []domain.Place{}
+
+	for _, shop := range shops {
+		merged := domain.ShopInfo{Info: []domain.Place{}, Shop: shop.Shop}
+		for _, place := range shop.Info {
+			duplicate := slices.IndexFunc(kept, func(keptPlace domain.Place) bool { return isDuplicatePlace(keptPlace, place) })
+			if duplicate != -1 {
+				log.Printf("place %s of %s is a duplicate of %s", place.Id, shop.Shop, kept[duplicate].Id)
+				continue
+			}
+			kept = append(kept, place)
+			merged.Info = append(merged.Info, place)
+		}
+		result = append(result, merged)
+	}
+
+	return result
+}
@@ -6,30 +6,41 @@ import (
 	"io"
 	"log"
 	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"maps_service/internal/schedule"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
+	_ "time/tzdata"
 )
 
-const api_2gis_shops string = "https://catalog.api.2gis.com/3.0/items?q=%s&point=%f,%f&radius=%d&fields=items.point,items.org,items.rubrics,items.schedule&key=%s"
+const api_2gis_shops string = "https://catalog.api.2gis.com/3.0/items?q=%s&point=%f,%f&radius=%d&fields=items.point,items.org,items.rubrics,items.schedule,items.full_address_name,items.timezone&page=%d&page_size=%d&key=%s"
 
-var shops = []string{
-	"Лента",
-	"Перекрёсток",
-	"Дикси",
-	"Магнит",
-}
+// The places are requested by pages, 2GIS gives no more than the page size
+// for one request and no more than the max pages for one query.
+const (
+	shopsPageSize = 50
+	maxShopsPages = 20
+)
 
 type result struct {
 	Items []placeWithDebug `json:"items"`
+	Total int              `json:"total"`
 }
 
 type placeWithDebug struct {
-	Name     string          `json:"name"`
-	Point    domain.Point    `json:"point"`
-	Id       string          `json:"id"`
-	Rubrics  []rubric        `json:"rubrics"`
-	Org      org             `json:"org"`
-	Schedule domain.Schedule `json:"schedule"`
+	Name            string          `json:"name"`
+	AddressName     string          `json:"address_name"`
+	FullAddressName string          `json:"full_address_name"`
+	Point           domain.Point    `json:"point"`
+	Id              string          `json:"id"`
+	Rubrics         []rubric        `json:"rubrics"`
+	Org             org             `json:"org"`
+	Schedule        domain.Schedule `json:"schedule"`
+	Timezone        string          `json:"timezone"`
 }
 
 type rubric struct {
@@ -46,21 +57,53 @@ type response2Gis struct {
 
 type ShopInfo2GisService struct {
 	apiKey string
+	client *http.Client
+}
+
+func NewShopInfo2GisService(apiKey string, client *http.Client) *ShopInfo2GisService {
+	return &ShopInfo2GisService{apiKey: apiKey, client: client}
 }
 
-func NewShopInfo2GisService(apiKey string) *ShopInfo2GisService {
-	return &ShopInfo2GisService{apiKey: apiKey}
+// Get pages through the places of the query, a place repeated on the next
+// page when 2GIS reorders them is returned once.
+func (shopInfo *ShopInfo2GisService) Get(chain domain.Chain, point domain.Point, radius int64) (*domain.ShopInfo, error) {
+	places := []placeWithDebug{}
+	found := make(map[string]struct{})
+	for page := 1; page <= maxShopsPages; page += 1 {
+		response, err := shopInfo.getPage(chain, point, radius, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, place := range response.Result.Items {
+			if _, ok := found[place.Id]; !ok {
+				found[place.Id] = struct{}{}
+				places = append(places, place)
+			}
+		}
+
+		if len(response.Result.Items) < shopsPageSize || page*shopsPageSize >= response.Result.Total {
+			break
+		}
+		if page == maxShopsPages {
+			log.Printf("%s places are cut to %d pages of %d", chain.Name, maxShopsPages, response.Result.Total)
+		}
+	}
+
+	return &domain.ShopInfo{
+		Info: filterAndTransform(places, chain),
+		Shop: chain.Name,
+	}, nil
 }
 
-func (shopInfo *ShopInfo2GisService) Get(shop string, point domain.Point, radius int64) (*domain.ShopInfo, error) {
-	url := fmt.Sprintf(api_2gis_shops, shop, point.Lon, point.Lat, radius, shopInfo.apiKey)
-	req, err := http.NewRequest("GET", url, nil)
+func (shopInfo *ShopInfo2GisService) getPage(chain domain.Chain, point domain.Point, radius int64, page int) (*response2Gis, error) {
+	requestUrl := fmt.Sprintf(api_2gis_shops, url.QueryEscape(chain.Query), point.Lon, point.Lat, radius, page, shopsPageSize, shopInfo.apiKey)
+	req, err := http.NewRequest("GET", requestUrl, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %+v", err)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := shopInfo.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error sending request: %+v", err)
 	}
@@ -76,31 +119,39 @@ func (shopInfo *ShopInfo2GisService) Get(shop string, point domain.Point, radius
 		return nil, fmt.Errorf("error unmarshal 2Gis response")
 	}
 
-	return &domain.ShopInfo{
-		Info: filterAndTransform(response.Result.Items, shop),
-		Shop: shop,
-	}, nil
+	return &response, nil
 }
 
+// Large search areas are split into tiles of this radius in metres, 2GIS
+// returns a limited number of places for one query.
+const areaTileRadius = 2000
+
+const maxAreaTiles = 50
+
 type ShopsRequester struct {
 	shopInfoService domain.IShopInfoService
+	chainRegistry   domain.IChainRegistry
 }
 
-func NewShopsRequester(shopInfoService domain.IShopInfoService) *ShopsRequester {
-	return &ShopsRequester{shopInfoService: shopInfoService}
+func NewShopsRequester(shopInfoService domain.IShopInfoService, chainRegistry domain.IChainRegistry) *ShopsRequester {
+	return &ShopsRequester{shopInfoService: shopInfoService, chainRegistry: chainRegistry}
 }
 
 func (sr *ShopsRequester) GetAvailableShops() []string {
-	return shops[:]
+	result := []string{}
+	for _, chain := range sr.chainRegistry.GetEnabled() {
+		result = append(result, chain.Name)
+	}
+	return result
 }
 
 func (sr *ShopsRequester) GetNearbyShops(point domain.Point, radius int64) []domain.ShopInfo {
 	var shops []domain.ShopInfo
 
-	for _, shop := range sr.GetAvailableShops() {
-		log.Printf("Process shop: %s, for point = (%f, %f), radius = %d", shop, point.Lon, point.Lat, radius)
+	for _, chain := range sr.chainRegistry.GetEnabled() {
+		log.Printf("Process shop: %s, for point = (%f, %f), radius = %d", chain.Name, point.Lon, point.Lat, radius)
 
-		shopInfo, err := sr.shopInfoService.Get(shop, point, radius)
+		shopInfo, err := sr.shopInfoService.Get(chain, point, radius)
 
 		log.Printf("ShopInfo: %+v, err: %+v", shopInfo, err)
 		if err == nil {
@@ -110,30 +161,142 @@ func (sr *ShopsRequester) GetNearbyShops(point domain.Point, radius int64) []dom
 		}
 	}
 
-	return shops
+	return mergeDuplicatePlaces(shops)
+}
+
+// GetShopsInArea queries the shops of each tile covering the area and keeps
+// the places inside it. A place found in several tiles is returned once.
+func (sr *ShopsRequester) GetShopsInArea(area domain.IArea) ([]domain.ShopInfo, error) {
+	tiles := geo.Tiles(area, areaTileRadius)
+	if len(tiles) > maxAreaTiles {
+		return nil, fmt.Errorf("area is too large: %d tiles, max %d", len(tiles), maxAreaTiles)
+	}
+
+	var shops []domain.ShopInfo
+
+	for _, chain := range sr.chainRegistry.GetEnabled() {
+		log.Printf("Process shop: %s, for area of %d tiles", chain.Name, len(tiles))
+
+		shop := domain.ShopInfo{Info: []domain.Place{}, Shop: chain.Name}
+		found := make(map[string]struct{})
+		failed := false
+
+		for _, tile := range tiles {
+			shopInfo, err := sr.shopInfoService.Get(chain, tile.Center, int64(math.Ceil(tile.Radius)))
+			if err != nil {
+				log.Println(err)
+				failed = true
+				break
+			}
+
+			for _, place := range shopInfo.Info {
+				if _, ok := found[place.Id]; ok || !area.Contains(place.Point) {
+					continue
+				}
+				found[place.Id] = struct{}{}
+				shop.Info = append(shop.Info, place)
+			}
+		}
+
+		if !failed {
+			shops = append(shops, shop)
+		}
+	}
+
+	return mergeDuplicatePlaces(shops), nil
+}
+
+// FilterOpenShops keeps the places open at the given time. A place with an
+// unreadable schedule is kept, it is better to show a closed shop than to hide
+// an open one.
+func FilterOpenShops(shops []domain.ShopInfo, at time.Time) []domain.ShopInfo {
+	result := []domain.ShopInfo{}
+	for _, shop := range shops {
+		open := domain.ShopInfo{Info: []domain.Place{}, Shop: shop.Shop}
+		for _, place := range shop.Info {
+			placeSchedule, err := schedule.Parse(&place.Schedule, loadLocation(place.TimeZone))
+			if err != nil {
+				log.Printf("invalid schedule of place %s: %+v", place.Id, err)
+			}
+			if err != nil || placeSchedule.IsOpenAt(at) {
+				open.Info = append(open.Info, place)
+			}
+		}
+		result = append(result, open)
+	}
+	return result
+}
+
+// loadLocation returns nil for an unknown time zone, so the schedule is read
+// in the time zone of the query.
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("unknown time zone %s: %+v", name, err)
+		return nil
+	}
+	return location
 }
 
-func filterAndTransform(places []placeWithDebug, shop string) []domain.Place {
+func filterAndTransform(places []placeWithDebug, chain domain.Chain) []domain.Place {
 	result := []domain.Place{}
 
 	for _, place := range places {
-		if checkOrg(place.Org, shop) && checkRubrics(place.Rubrics) {
-			result = append(result, domain.Place{Name: place.Name, Point: place.Point, Id: place.Id, Schedule: place.Schedule})
+		if checkOrg(place.Org, chain.Match) && checkRubrics(place.Rubrics, chain.Rubrics) {
+			rubrics := []string{}
+			for _, rubric := range place.Rubrics {
+				rubrics = append(rubrics, rubric.Name)
+			}
+			format := ClassifyFormat(chain, place.Name+" "+place.Org.Name, rubrics)
+			result = append(result, domain.Place{Name: place.Name, Address: formatAddress(place), Point: place.Point, Id: place.Id, Schedule: place.Schedule, TimeZone: place.Timezone, Format: format})
 		}
 	}
 
 	return result
 }
 
-func checkRubrics(rubrics []rubric) bool {
+// formatAddress prefers the address with the city, which 2GIS returns only
+// when it is requested in fields.
+func formatAddress(place placeWithDebug) string {
+	if place.FullAddressName != "" {
+		return place.FullAddressName
+	}
+	return place.AddressName
+}
+
+// Chain without rubrics in config accepts places of any rubric.
+func checkRubrics(rubrics []rubric, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
 	for _, rubric := range rubrics {
-		if rubric.Name == "Супермаркеты" || rubric.Name == "Гипермаркеты" {
+		if slices.Contains(allowed, rubric.Name) {
 			return true
 		}
 	}
 	return false
 }
 
-func checkOrg(organization org, shop string) bool {
-	return strings.Contains(organization.Name, shop)
+// normalizeName makes names comparable regardless of case and of the "ё"
+// spelling, 2GIS uses both "Перекресток" and "Перекрёсток".
+func normalizeName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "ё", "е")
+}
+
+func checkOrg(organization org, match domain.ChainMatch) bool {
+	name := normalizeName(organization.Name)
+	for _, exclude := range match.Exclude {
+		if strings.Contains(name, normalizeName(exclude)) {
+			return false
+		}
+	}
+	for _, include := range match.Names {
+		if strings.Contains(name, normalizeName(include)) {
+			return true
+		}
+	}
+	return false
 }
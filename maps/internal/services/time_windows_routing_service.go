@@ -0,0 +1,62 @@
+package services
+
+import (
+	"maps_service/internal/domain"
+	"time"
+)
+
+type TimeWindowsRoutingService struct {
+	matrixService domain.IMatrixService
+	tspService    domain.ITSPTimeWindowsService
+}
+
+func NewTimeWindowsRoutingService(matrixService domain.IMatrixService, tspService domain.ITSPTimeWindowsService) *TimeWindowsRoutingService {
+	return &TimeWindowsRoutingService{matrixService: matrixService, tspService: tspService}
+}
+
+// Get returns an error only for invalid schedules, failures of the matrix or
+// tsp service are reported in the transport statuses as in RoutingService.
+func (routing *TimeWindowsRoutingService) Get(points []domain.Point, startPoint, endPoint int, schedules []*domain.Schedule, departure time.Time, serviceTime int) (*domain.RoutingResult, error) {
+	windows := make([][]domain.TimeWindow, len(points))
+	for i := 0; i < len(points) && i < len(schedules); i++ {
+		if i == startPoint || i == endPoint {
+			continue
+		}
+		pointWindows, err := GetTimeWindows(schedules[i], departure)
+		if err != nil {
+			return nil, err
+		}
+		windows[i] = pointWindows
+	}
+
+	result := &domain.RoutingResult{Routes: []domain.MinTimeRoute{}, Statuses: []domain.TransportStatus{}}
+
+	for _, transport := range TRANSPORT_TYPES {
+		sequence := genSimpleSequence(len(points))
+		_, durMatrix, err := routing.matrixService.Get(points, sequence, sequence, transport, &departure)
+
+		if err != nil {
+			result.Statuses = append(result.Statuses, getTransportStatus(transport, err))
+			continue
+		}
+
+		tour, err := routing.tspService.Get(durMatrix, startPoint, endPoint, windows, serviceTime)
+		result.Statuses = append(result.Statuses, getTransportStatus(transport, err))
+		if err != nil {
+			continue
+		}
+
+		route := domain.MinTimeRoute{Points: tour.Points, Duration: tour.Duration, Transport: transport, Optimal: true, Infeasible: tour.Infeasible}
+		for i, point := range tour.Points {
+			route.Stops = append(route.Stops, domain.Stop{
+				Point:     point,
+				Arrival:   departure.Add(time.Duration(tour.Arrivals[i]) * time.Second),
+				Departure: departure.Add(time.Duration(tour.Departures[i]) * time.Second),
+			})
+		}
+
+		result.Routes = append(result.Routes, route)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"fmt"
+	"maps_service/internal/domain"
+	"math"
+	"math/rand"
+)
+
+// Missing edges are replaced with a huge cost, so the heuristics can move
+// through them and drop them later, and a tour using one is rejected at the end.
+const tspInfinity = 1 << 40
+
+const (
+	annealingSeed            = 42
+	annealingIterationsPerN2 = 100
+	annealingMaxIterations   = 200000
+	annealingFinalTemp       = 0.5
+)
+
+// Exact solvers are used up to this points count in the "auto" mode.
+const tspAutoExactLimit = 15
+
+func edgeCost(matrix [][]int, from, to int) int {
+	if matrix[from][to] == -1 {
+		return tspInfinity
+	}
+	return matrix[from][to]
+}
+
+func tourCost(matrix [][]int, tour []int) int {
+	cost := 0
+	for i := range tour {
+		cost += edgeCost(matrix, tour[i], tour[(i+1)%len(tour)])
+	}
+	return cost
+}
+
+func checkTSPInput(matrix [][]int, startPoint, endPoint int) error {
+	if len(matrix) <= 1 {
+		return fmt.Errorf("%w: incorrect points count", domain.ErrInvalidInput)
+	}
+	if startPoint < 0 || len(matrix) <= startPoint {
+		return fmt.Errorf("%w: incorrect start point", domain.ErrInvalidInput)
+	}
+	if endPoint != domain.OpenEndPoint && (endPoint < 0 || len(matrix) <= endPoint) {
+		return fmt.Errorf("%w: incorrect end point", domain.ErrInvalidInput)
+	}
+	return nil
+}
+
+// withEndPoint reduces a route with an open or fixed end to a closed tour. An
+// extra point is added, it can be entered only from the allowed end points and
+// leads back to the start for free, so the tour is start -> ... -> end ->
+// extra -> start.
+func withEndPoint(matrix [][]int, startPoint, endPoint int, solve func([][]int, int) (int, []int, error)) (int, []int, error) {
+	if endPoint == startPoint {
+		return solve(matrix, startPoint)
+	}
+
+	n := len(matrix)
+	extended := createMatrix(n+1, n+1)
+	for i := range matrix {
+		copy(extended[i], matrix[i])
+		if i != startPoint && (endPoint == domain.OpenEndPoint || i == endPoint) {
+			extended[i][n] = 0
+		}
+	}
+	extended[n][startPoint] = 0
+	extended[n][n] = 0
+
+	cost, path, err := solve(extended, startPoint)
+	if err != nil {
+		return -1, nil, err
+	}
+	return cost, path[:len(path)-2], nil
+}
+
+// closeTour converts a tour starting at the start point into the path format
+// of the exact solvers.
+func closeTour(matrix [][]int, tour []int) (int, []int, error) {
+	cost := tourCost(matrix, tour)
+	if cost >= tspInfinity {
+		return -1, nil, domain.ErrNoPath
+	}
+	return cost, append(append([]int(nil), tour...), tour[0]), nil
+}
+
+func averageEdgeCost(matrix [][]int) float64 {
+	sum, count := 0, 0
+	for i := range matrix {
+		for j := range matrix[i] {
+			if i != j && matrix[i][j] != -1 {
+				sum += matrix[i][j]
+				count += 1
+			}
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return float64(sum) / float64(count)
+}
+
+func nearestNeighbourTour(matrix [][]int, startPoint int) []int {
+	n := len(matrix)
+	visited := make([]bool, n)
+	tour := []int{startPoint}
+	visited[startPoint] = true
+
+	for len(tour) < n {
+		last := tour[len(tour)-1]
+		next := -1
+		for candidate := 0; candidate < n; candidate++ {
+			if visited[candidate] {
+				continue
+			}
+			if next == -1 || edgeCost(matrix, last, candidate) < edgeCost(matrix, last, next) {
+				next = candidate
+			}
+		}
+		visited[next] = true
+		tour = append(tour, next)
+	}
+
+	return tour
+}
+
+// twoOpt reverses tour segments while it shortens the tour. The matrix can be
+// asymmetric, so the cost of the reversed segment is taken from prefix sums
+// of the backward edges.
+func twoOpt(matrix [][]int, tour []int) bool {
+	n := len(tour)
+	forward := make([]int, n)
+	backward := make([]int, n)
+	for k := 1; k < n; k++ {
+		forward[k] = forward[k-1] + edgeCost(matrix, tour[k-1], tour[k])
+		backward[k] = backward[k-1] + edgeCost(matrix, tour[k], tour[k-1])
+	}
+
+	for i := 1; i < n-1; i++ {
+		for j := i + 1; j < n; j++ {
+			prev, next := tour[i-1], tour[(j+1)%n]
+			oldCost := edgeCost(matrix, prev, tour[i]) + forward[j] - forward[i] + edgeCost(matrix, tour[j], next)
+			newCost := edgeCost(matrix, prev, tour[j]) + backward[j] - backward[i] + edgeCost(matrix, tour[i], next)
+			if newCost < oldCost {
+				reverseSegment(tour, i, j)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func reverseSegment(tour []int, i, j int) {
+	for ; i < j; i, j = i+1, j-1 {
+		tour[i], tour[j] = tour[j], tour[i]
+	}
+}
+
+// orOpt moves segments of up to three points to another place of the tour.
+func orOpt(matrix [][]int, tour []int) bool {
+	n := len(tour)
+	for length := 1; length <= 3; length++ {
+		for i := 1; i+length <= n; i++ {
+			first, last := tour[i], tour[i+length-1]
+			prev, next := tour[i-1], tour[(i+length)%n]
+			removeGain := edgeCost(matrix, prev, first) + edgeCost(matrix, last, next) - edgeCost(matrix, prev, next)
+
+			for k := 0; k < n; k++ {
+				if k >= i-1 && k < i+length {
+					continue
+				}
+				a, b := tour[k], tour[(k+1)%n]
+				insertCost := edgeCost(matrix, a, first) + edgeCost(matrix, last, b) - edgeCost(matrix, a, b)
+				if insertCost < removeGain {
+					moveSegment(tour, i, length, k)
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// moveSegment moves tour[i:i+length] right after the position k.
+func moveSegment(tour []int, i, length, k int) {
+	segment := append([]int(nil), tour[i:i+length]...)
+	rest := append(append([]int(nil), tour[:i]...), tour[i+length:]...)
+	if k > i {
+		k -= length
+	}
+
+	result := append(append(append([]int(nil), rest[:k+1]...), segment...), rest[k+1:]...)
+	copy(tour, result)
+}
+
+func localSearch(matrix [][]int, tour []int) {
+	for twoOpt(matrix, tour) || orOpt(matrix, tour) {
+	}
+}
+
+type TSPLocalSearch struct{}
+
+func NewTSPLocalSearch() *TSPLocalSearch {
+	return &TSPLocalSearch{}
+}
+
+func (tsp *TSPLocalSearch) Get(matrix [][]int, startPoint, endPoint int) (int, []int, error) {
+	if err := checkTSPInput(matrix, startPoint, endPoint); err != nil {
+		return -1, nil, err
+	}
+	return withEndPoint(matrix, startPoint, endPoint, tsp.getTour)
+}
+
+func (tsp *TSPLocalSearch) getTour(matrix [][]int, startPoint int) (int, []int, error) {
+	tour := nearestNeighbourTour(matrix, startPoint)
+	localSearch(matrix, tour)
+
+	return closeTour(matrix, tour)
+}
+
+func (tsp *TSPLocalSearch) IsExact(_ int) bool {
+	return false
+}
+
+type TSPSimulatedAnnealing struct{}
+
+func NewTSPSimulatedAnnealing() *TSPSimulatedAnnealing {
+	return &TSPSimulatedAnnealing{}
+}
+
+func (tsp *TSPSimulatedAnnealing) Get(matrix [][]int, startPoint, endPoint int) (int, []int, error) {
+	if err := checkTSPInput(matrix, startPoint, endPoint); err != nil {
+		return -1, nil, err
+	}
+	return withEndPoint(matrix, startPoint, endPoint, tsp.getTour)
+}
+
+func (tsp *TSPSimulatedAnnealing) getTour(matrix [][]int, startPoint int) (int, []int, error) {
+	n := len(matrix)
+	tour := nearestNeighbourTour(matrix, startPoint)
+	if n <= 3 {
+		localSearch(matrix, tour)
+		return closeTour(matrix, tour)
+	}
+
+	// The generator is seeded with a constant, so the same request always
+	// gets the same route.
+	random := rand.New(rand.NewSource(annealingSeed))
+
+	cost := tourCost(matrix, tour)
+	best := append([]int(nil), tour...)
+	bestCost := cost
+
+	iterations := min(annealingIterationsPerN2*n*n, annealingMaxIterations)
+	temp := math.Max(averageEdgeCost(matrix), 1)
+	cooling := math.Pow(annealingFinalTemp/temp, 1/float64(iterations))
+
+	candidate := make([]int, n)
+	for iteration := 0; iteration < iterations; iteration++ {
+		copy(candidate, tour)
+
+		i := 1 + random.Intn(n-1)
+		j := 1 + random.Intn(n-1)
+		if i > j {
+			i, j = j, i
+		}
+		if random.Intn(2) == 0 {
+			reverseSegment(candidate, i, j)
+		} else if i != j {
+			moveSegment(candidate, i, 1, j)
+		}
+
+		candidateCost := tourCost(matrix, candidate)
+		delta := candidateCost - cost
+		if delta <= 0 || random.Float64() < math.Exp(-float64(delta)/temp) {
+			copy(tour, candidate)
+			cost = candidateCost
+			if cost < bestCost {
+				copy(best, tour)
+				bestCost = cost
+			}
+		}
+
+		temp *= cooling
+	}
+
+	localSearch(matrix, best)
+	return closeTour(matrix, best)
+}
+
+func (tsp *TSPSimulatedAnnealing) IsExact(_ int) bool {
+	return false
+}
+
+// TSPAuto uses the exact solver for small inputs and the heuristic otherwise.
+type TSPAuto struct {
+	exact     domain.ITSPService
+	heuristic domain.ITSPService
+}
+
+func NewTSPAuto(exact, heuristic domain.ITSPService) *TSPAuto {
+	return &TSPAuto{exact: exact, heuristic: heuristic}
+}
+
+func (tsp *TSPAuto) Get(matrix [][]int, startPoint, endPoint int) (int, []int, error) {
+	if len(matrix) <= tspAutoExactLimit {
+		return tsp.exact.Get(matrix, startPoint, endPoint)
+	}
+	return tsp.heuristic.Get(matrix, startPoint, endPoint)
+}
+
+func (tsp *TSPAuto) IsExact(pointsCount int) bool {
+	if pointsCount <= tspAutoExactLimit {
+		return tsp.exact.IsExact(pointsCount)
+	}
+	return tsp.heuristic.IsExact(pointsCount)
+}
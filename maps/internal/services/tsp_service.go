@@ -1,7 +1,7 @@
 package services
 
 import (
-	"fmt"
+	"maps_service/internal/domain"
 	"slices"
 )
 
@@ -29,10 +29,10 @@ func nextPermutation(nums []int) bool {
 	return true
 }
 
-func genSequenceExcludeValue(count, exclude int) []int {
+func genSequenceExcludeValue(count int, exclude ...int) []int {
 	var permutation []int
 	for i := 0; i < count; i++ {
-		if i == exclude {
+		if slices.Contains(exclude, i) {
 			continue
 		}
 		permutation = append(permutation, i)
@@ -41,6 +41,18 @@ func genSequenceExcludeValue(count, exclude int) []int {
 	return permutation
 }
 
+// routeTail returns the points fixed at the end of a route: the start point for
+// a closed tour, the end point for a fixed one and nothing for an open path.
+func routeTail(startPoint, endPoint int) []int {
+	switch endPoint {
+	case startPoint:
+		return []int{startPoint}
+	case domain.OpenEndPoint:
+		return nil
+	}
+	return []int{endPoint}
+}
+
 func updatePathCost(matrix [][]int, previousCost int, previousValid bool, from, to int) (int, bool) {
 	if matrix[from][to] == -1 {
 		return -1, false
@@ -57,36 +69,29 @@ func NewTSPBruteforce() *TSPBruteforce {
 	return &TSPBruteforce{}
 }
 
-func (tsp *TSPBruteforce) Get(matrix [][]int, startPoint int) (int, []int, error) {
-	if len(matrix) <= 1 {
-		return -1, nil, fmt.Errorf("incorrect points count")
+func (tsp *TSPBruteforce) Get(matrix [][]int, startPoint, endPoint int) (int, []int, error) {
+	if err := checkTSPInput(matrix, startPoint, endPoint); err != nil {
+		return -1, nil, err
 	}
 
-	if startPoint < 0 || len(matrix) <= startPoint {
-		return -1, nil, fmt.Errorf("incorrect start point")
-	}
-
-	permutation := genSequenceExcludeValue(len(matrix), startPoint)
+	permutation := genSequenceExcludeValue(len(matrix), startPoint, endPoint)
+	tail := routeTail(startPoint, endPoint)
 
 	result := -1
 	var path []int = nil
 
 	for {
+		sequence := append(append([]int{startPoint}, permutation...), tail...)
 		cost := 0
 		valid := true
 
-		firstPoint, lastPoint := permutation[0], permutation[len(permutation)-1]
-
-		cost, valid = updatePathCost(matrix, cost, valid, startPoint, firstPoint)
-		cost, valid = updatePathCost(matrix, cost, valid, lastPoint, startPoint)
-
-		for i := 0; i+1 < len(permutation); i++ {
-			cost, valid = updatePathCost(matrix, cost, valid, permutation[i], permutation[i+1])
+		for i := 0; i+1 < len(sequence); i++ {
+			cost, valid = updatePathCost(matrix, cost, valid, sequence[i], sequence[i+1])
 		}
 
 		if valid && (result == -1 || result > cost) {
 			result = cost
-			path = append([]int(nil), permutation...)
+			path = sequence
 		}
 
 		if !nextPermutation(permutation) {
@@ -95,23 +100,23 @@ func (tsp *TSPBruteforce) Get(matrix [][]int, startPoint int) (int, []int, error
 	}
 
 	if path == nil {
-		return -1, nil, fmt.Errorf("no path")
+		return -1, nil, domain.ErrNoPath
 	}
 
-	return result, append(append([]int{startPoint}, path...), startPoint), nil
+	return result, path, nil
+}
+
+func (tsp *TSPBruteforce) IsExact(_ int) bool {
+	return true
 }
 
 func NewTSPDynProgramming() *TSPDynProgramming {
 	return &TSPDynProgramming{}
 }
 
-func (tsp *TSPDynProgramming) Get(matrix [][]int, startPoint int) (int, []int, error) {
-	if len(matrix) <= 1 {
-		return -1, nil, fmt.Errorf("incorrect points conunt")
-	}
-
-	if startPoint < 0 || len(matrix) <= startPoint {
-		return -1, nil, fmt.Errorf("incorrect start point")
+func (tsp *TSPDynProgramming) Get(matrix [][]int, startPoint, endPoint int) (int, []int, error) {
+	if err := checkTSPInput(matrix, startPoint, endPoint); err != nil {
+		return -1, nil, err
 	}
 
 	n := len(matrix)
@@ -148,23 +153,47 @@ func (tsp *TSPDynProgramming) Get(matrix [][]int, startPoint int) (int, []int, e
 		}
 	}
 
+	// The cost of finishing the route at the given last point, -1 if the
+	// route can not finish there.
+	finishCost := func(last int) int {
+		full := (1 << n) - 1
+		if dp[last][full] == -1 {
+			return -1
+		}
+		switch endPoint {
+		case domain.OpenEndPoint:
+			return dp[last][full]
+		case startPoint:
+			if matrix[last][startPoint] == -1 {
+				return -1
+			}
+			return dp[last][full] + matrix[last][startPoint]
+		case last:
+			return dp[last][full]
+		}
+		return -1
+	}
+
 	result := -1
 
 	for i := 0; i < n; i++ {
-		if dp[i][(1<<n)-1] == -1 {
-			continue
-		}
-		if matrix[i][startPoint] == -1 {
+		if finishCost(i) == -1 {
 			continue
 		}
-		totalCost := dp[i][(1<<n)-1] + matrix[i][startPoint]
-		if result == -1 || totalCost < dp[result][(1<<n)-1]+matrix[result][startPoint] {
+		if result == -1 || finishCost(i) < finishCost(result) {
 			result = i
 		}
 	}
 
-	resultCost := dp[result][(1<<n)-1] + matrix[result][startPoint]
-	resultPath := []int{startPoint}
+	if result == -1 {
+		return -1, nil, domain.ErrNoPath
+	}
+
+	resultCost := finishCost(result)
+	resultPath := []int{}
+	if endPoint == startPoint {
+		resultPath = append(resultPath, startPoint)
+	}
 
 	mask := (1 << n) - 1
 
@@ -179,3 +208,7 @@ func (tsp *TSPDynProgramming) Get(matrix [][]int, startPoint int) (int, []int, e
 
 	return resultCost, resultPath, nil
 }
+
+func (tsp *TSPDynProgramming) IsExact(_ int) bool {
+	return true
+}
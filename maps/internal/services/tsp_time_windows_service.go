@@ -0,0 +1,164 @@
+package services
+
+import (
+	"fmt"
+	"maps_service/internal/domain"
+	"maps_service/internal/schedule"
+	"math/bits"
+	"slices"
+	"time"
+)
+
+// The solver is exact and keeps n * 2^n states, so the points count is
+// limited the same way as for the "auto" mode.
+const tspTimeWindowsLimit = 16
+
+// Opening hours are expanded into time windows for this period after the
+// departure time.
+const timeWindowsHorizon = 2 * 24 * time.Hour
+
+// GetTimeWindows converts opening hours into windows in seconds since the
+// departure. The hours are read in the time zone of the departure time. A
+// place without schedule is considered always open and gets nil windows.
+func GetTimeWindows(placeSchedule *domain.Schedule, departure time.Time) ([]domain.TimeWindow, error) {
+	parsed, err := schedule.Parse(placeSchedule, nil)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.IsAlwaysOpen() {
+		return nil, nil
+	}
+
+	windows := []domain.TimeWindow{}
+	for _, interval := range parsed.Intervals(departure, departure.Add(timeWindowsHorizon)) {
+		windows = append(windows, domain.TimeWindow{
+			From: int(interval.From.Sub(departure).Seconds()),
+			To:   int(interval.To.Sub(departure).Seconds()),
+		})
+	}
+
+	return windows, nil
+}
+
+// earliestStart returns the first moment after the arrival when the whole
+// service fits into one of the windows.
+func earliestStart(windows []domain.TimeWindow, arrival, serviceTime int) (int, bool) {
+	if windows == nil {
+		return arrival, true
+	}
+	for _, window := range windows {
+		start := max(arrival, window.From)
+		if start+serviceTime <= window.To {
+			return start, true
+		}
+	}
+	return -1, false
+}
+
+type TSPTimeWindows struct{}
+
+func NewTSPTimeWindows() *TSPTimeWindows {
+	return &TSPTimeWindows{}
+}
+
+// Get finds the route visiting as many points as possible while they are open
+// and, among such routes, the one finished earliest. Waiting for opening is
+// allowed, so the earliest departure from the last point is enough to describe
+// a partial route. The end point, as well as the start one, has no window and
+// no service, an open path finishes at the departure from its last point.
+func (tsp *TSPTimeWindows) Get(matrix [][]int, startPoint, endPoint int, windows [][]domain.TimeWindow, serviceTime int) (*domain.TimeWindowsTour, error) {
+	if err := checkTSPInput(matrix, startPoint, endPoint); err != nil {
+		return nil, err
+	}
+	if len(matrix) > tspTimeWindowsLimit {
+		return nil, fmt.Errorf("%w: too many points for time windows: %d, max %d", domain.ErrInvalidInput, len(matrix), tspTimeWindowsLimit)
+	}
+	if len(windows) != len(matrix) {
+		return nil, fmt.Errorf("%w: incorrect time windows count", domain.ErrInvalidInput)
+	}
+
+	n := len(matrix)
+	times := createMatrix(1<<n, n)
+	parents := createMatrix(1<<n, n)
+	times[1<<startPoint][startPoint] = 0
+
+	for mask := 0; mask < 1<<n; mask++ {
+		for last := 0; last < n; last++ {
+			if times[mask][last] == -1 {
+				continue
+			}
+
+			for next := 0; next < n; next++ {
+				if mask&(1<<next) != 0 || next == endPoint || matrix[last][next] == -1 {
+					continue
+				}
+
+				start, ok := earliestStart(windows[next], times[mask][last]+matrix[last][next], serviceTime)
+				if !ok {
+					continue
+				}
+
+				nextMask := mask | (1 << next)
+				if departure := start + serviceTime; times[nextMask][next] == -1 || departure < times[nextMask][next] {
+					times[nextMask][next] = departure
+					parents[nextMask][next] = last
+				}
+			}
+		}
+	}
+
+	bestMask, bestLast, bestCount, bestFinish := -1, -1, 0, 0
+	for mask := 0; mask < 1<<n; mask++ {
+		for last := 0; last < n; last++ {
+			if times[mask][last] == -1 {
+				continue
+			}
+
+			finish := times[mask][last]
+			if endPoint != domain.OpenEndPoint {
+				if matrix[last][endPoint] == -1 {
+					continue
+				}
+				finish += matrix[last][endPoint]
+			}
+
+			count := bits.OnesCount(uint(mask))
+			if bestMask == -1 || count > bestCount || count == bestCount && finish < bestFinish {
+				bestMask, bestLast, bestCount, bestFinish = mask, last, count, finish
+			}
+		}
+	}
+
+	if bestMask == -1 {
+		return nil, domain.ErrNoPath
+	}
+
+	path := []int{}
+	for mask, last := bestMask, bestLast; last != -1; {
+		path = append(path, last)
+		mask, last = mask^(1<<last), parents[mask][last]
+	}
+	slices.Reverse(path)
+
+	result := &domain.TimeWindowsTour{Duration: bestFinish, Arrivals: []int{0}, Departures: []int{0}}
+	for i := 1; i < len(path); i++ {
+		arrival := result.Departures[i-1] + matrix[path[i-1]][path[i]]
+		start, _ := earliestStart(windows[path[i]], arrival, serviceTime)
+		result.Arrivals = append(result.Arrivals, arrival)
+		result.Departures = append(result.Departures, start+serviceTime)
+	}
+	if endPoint != domain.OpenEndPoint {
+		path = append(path, endPoint)
+		result.Arrivals = append(result.Arrivals, bestFinish)
+		result.Departures = append(result.Departures, bestFinish)
+	}
+	result.Points = path
+
+	for point := 0; point < n; point++ {
+		if bestMask&(1<<point) == 0 && point != endPoint {
+			result.Infeasible = append(result.Infeasible, point)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,184 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"maps_service/internal/mock"
+	"maps_service/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getSquarePolygon() *domain.Polygon {
+	return &domain.Polygon{
+		Type: "Polygon",
+		Coordinates: [][][2]float64{
+			{{82.0, 55.0}, {82.1, 55.0}, {82.1, 55.1}, {82.0, 55.1}},
+			{{82.04, 55.04}, {82.06, 55.04}, {82.06, 55.06}, {82.04, 55.06}, {82.04, 55.04}},
+		},
+	}
+}
+
+func TestPolygonArea(t *testing.T) {
+	area, err := geo.NewPolygonArea(getSquarePolygon())
+	assert.NoError(t, err)
+
+	t.Run(
+		"Points inside, outside and in the hole",
+		func(t *testing.T) {
+			assert.True(t, area.Contains(domain.Point{Lon: 82.01, Lat: 55.01}))
+			assert.False(t, area.Contains(domain.Point{Lon: 82.2, Lat: 55.01}))
+			assert.False(t, area.Contains(domain.Point{Lon: 82.05, Lat: 55.05}))
+		},
+	)
+
+	t.Run(
+		"Distance to the border",
+		func(t *testing.T) {
+			assert.Equal(t, 0., area.Distance(domain.Point{Lon: 82.01, Lat: 55.01}))
+			assert.InDelta(t, 1112, area.Distance(domain.Point{Lon: 82.05, Lat: 54.99}), 5)
+			assert.InDelta(t, 638, area.Distance(domain.Point{Lon: 82.05, Lat: 55.05}), 5)
+		},
+	)
+
+	t.Run(
+		"Invalid polygons",
+		func(t *testing.T) {
+			_, err := geo.NewPolygonArea(&domain.Polygon{Type: "LineString", Coordinates: getSquarePolygon().Coordinates})
+			assert.Error(t, err)
+			_, err = geo.NewPolygonArea(&domain.Polygon{Type: "Polygon"})
+			assert.Error(t, err)
+			_, err = geo.NewPolygonArea(&domain.Polygon{Type: "Polygon", Coordinates: [][][2]float64{{{82.0, 55.0}, {82.1, 55.0}}}})
+			assert.Error(t, err)
+		},
+	)
+}
+
+func TestCorridorArea(t *testing.T) {
+	line := domain.LineString{Type: "LineString", Coordinates: [][2]float64{{82.0, 55.0}, {82.1, 55.0}}}
+	area, err := geo.NewCorridorArea(&domain.Corridor{Line: line, Buffer: 500})
+	assert.NoError(t, err)
+
+	t.Run(
+		"Points within the buffer",
+		func(t *testing.T) {
+			assert.True(t, area.Contains(domain.Point{Lon: 82.05, Lat: 55.004}))
+			assert.False(t, area.Contains(domain.Point{Lon: 82.05, Lat: 55.005}))
+			assert.False(t, area.Contains(domain.Point{Lon: 82.11, Lat: 55.0}))
+			assert.InDelta(t, 612, area.Distance(domain.Point{Lon: 82.05, Lat: 55.01}), 5)
+		},
+	)
+
+	t.Run(
+		"Invalid corridors",
+		func(t *testing.T) {
+			_, err := geo.NewCorridorArea(&domain.Corridor{Line: line})
+			assert.Error(t, err)
+			_, err = geo.NewCorridorArea(&domain.Corridor{Line: domain.LineString{Type: "LineString", Coordinates: [][2]float64{{82.0, 55.0}}}, Buffer: 500})
+			assert.Error(t, err)
+		},
+	)
+}
+
+func TestTiles(t *testing.T) {
+	t.Run(
+		"Small area is a single tile",
+		func(t *testing.T) {
+			area, err := geo.NewCorridorArea(&domain.Corridor{
+				Line:   domain.LineString{Type: "LineString", Coordinates: [][2]float64{{82.0, 55.0}, {82.01, 55.0}}},
+				Buffer: 100,
+			})
+			assert.NoError(t, err)
+
+			tiles := geo.Tiles(area, 2000)
+			assert.Equal(t, 1, len(tiles))
+			assert.InDelta(t, 82.005, tiles[0].Center.Lon, 1e-9)
+			assert.Less(t, tiles[0].Radius, 2000.)
+		},
+	)
+
+	t.Run(
+		"Tiles cover the whole area",
+		func(t *testing.T) {
+			area, err := geo.NewPolygonArea(getSquarePolygon())
+			assert.NoError(t, err)
+
+			tiles := geo.Tiles(area, 2000)
+			assert.Greater(t, len(tiles), 1)
+			for lon := 82.0; lon <= 82.1; lon += 0.01 {
+				for lat := 55.0; lat <= 55.1; lat += 0.01 {
+					point := domain.Point{Lon: lon, Lat: lat}
+					covered := false
+					for _, tile := range tiles {
+						covered = covered || geo.Distance(point, tile.Center) <= tile.Radius
+					}
+					assert.True(t, covered, "point %v is not covered", point)
+				}
+			}
+		},
+	)
+
+	t.Run(
+		"Tiles far from a diagonal corridor are skipped",
+		func(t *testing.T) {
+			area, err := geo.NewCorridorArea(&domain.Corridor{
+				Line:   domain.LineString{Type: "LineString", Coordinates: [][2]float64{{82.0, 55.0}, {82.2, 55.2}}},
+				Buffer: 200,
+			})
+			assert.NoError(t, err)
+
+			southWest, northEast := area.Bounds()
+			box, err := geo.NewPolygonArea(&domain.Polygon{
+				Type: "Polygon",
+				Coordinates: [][][2]float64{{
+					{southWest.Lon, southWest.Lat}, {northEast.Lon, southWest.Lat},
+					{northEast.Lon, northEast.Lat}, {southWest.Lon, northEast.Lat},
+				}},
+			})
+			assert.NoError(t, err)
+			assert.Less(t, 2*len(geo.Tiles(area, 2000)), len(geo.Tiles(box, 2000)))
+		},
+	)
+}
+
+func TestShopsRequester_GetShopsInArea(t *testing.T) {
+	shopsService := services.NewShopsRequester(mock.NewMockShopInfo(map[string]domain.ShopInfo{
+		"Лента": {
+			Shop: "Лента",
+			Info: []domain.Place{
+				{Id: "1", Point: domain.Point{Lon: 82.01, Lat: 55.01}},
+				{Id: "2", Point: domain.Point{Lon: 82.05, Lat: 55.05}},
+				{Id: "3", Point: domain.Point{Lon: 82.2, Lat: 55.01}},
+			},
+		},
+	}), getChainRegistry())
+
+	t.Run(
+		"Places are filtered by the polygon and returned once",
+		func(t *testing.T) {
+			area, err := geo.NewPolygonArea(getSquarePolygon())
+			assert.NoError(t, err)
+
+			shops, err := shopsService.GetShopsInArea(area)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.ShopInfo{
+				{Shop: "Лента", Info: []domain.Place{{Id: "1", Point: domain.Point{Lon: 82.01, Lat: 55.01}}}},
+			}, shops)
+		},
+	)
+
+	t.Run(
+		"Too large area",
+		func(t *testing.T) {
+			area, err := geo.NewPolygonArea(&domain.Polygon{
+				Type:        "Polygon",
+				Coordinates: [][][2]float64{{{82.0, 55.0}, {83.0, 55.0}, {83.0, 56.0}, {82.0, 56.0}}},
+			})
+			assert.NoError(t, err)
+
+			_, err = shopsService.GetShopsInArea(area)
+			assert.Error(t, err)
+		},
+	)
+}
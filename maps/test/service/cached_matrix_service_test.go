@@ -0,0 +1,176 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/mock"
+	"maps_service/internal/services"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lonRoute(from, to domain.Point) (int, int) {
+	distance := int(math.Round(math.Abs(to.Lon-from.Lon) * 1000))
+	return distance, 2 * distance
+}
+
+func getCachePoints() []domain.Point {
+	return []domain.Point{
+		{Lon: 82.001, Lat: 55.0},
+		{Lon: 82.003, Lat: 55.0},
+		{Lon: 82.007, Lat: 55.0},
+	}
+}
+
+func TestCachedMatrixService_Hits(t *testing.T) {
+	matrix := mock.NewMockPointsMatrix(lonRoute)
+	metrics := mock.NewMockMetrics()
+	service := services.NewCachedMatrixService(matrix, mock.NewMockMatrixCache(), metrics)
+
+	points := getCachePoints()
+	sequence := []int{0, 1, 2}
+
+	t.Run(
+		"Second request is served from cache",
+		func(t *testing.T) {
+			firstDistance, firstDuration, err := service.Get(points, sequence, sequence, "walking", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(matrix.Calls))
+			assert.Equal(t, 0, metrics.Hits)
+			assert.Equal(t, 6, metrics.Misses)
+
+			secondDistance, secondDuration, err := service.Get(points, sequence, sequence, "walking", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(matrix.Calls), "matrix service should not be called on full cache hit")
+			assert.Equal(t, 6, metrics.Hits)
+
+			assert.Equal(t, firstDistance, secondDistance)
+			assert.Equal(t, firstDuration, secondDuration)
+			assert.Equal(t, [][]int{{0, 2, 6}, {2, 0, 4}, {6, 4, 0}}, secondDistance)
+		},
+	)
+
+	t.Run(
+		"Other transport is not mixed with cached one",
+		func(t *testing.T) {
+			_, _, err := service.Get(points, sequence, sequence, "driving", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(matrix.Calls))
+		},
+	)
+}
+
+func TestCachedMatrixService_DepartureBuckets(t *testing.T) {
+	points := getCachePoints()
+	sequence := []int{0, 1, 2}
+	evening := time.Date(2024, 1, 20, 18, 10, 0, 0, time.UTC)
+
+	t.Run(
+		"Departures of the same hour share the cache",
+		func(t *testing.T) {
+			matrix := mock.NewMockPointsMatrix(lonRoute)
+			service := services.NewCachedMatrixService(matrix, mock.NewMockMatrixCache(), mock.NewMockMetrics())
+
+			_, _, err := service.Get(points, sequence, sequence, "driving", &evening)
+			assert.NoError(t, err)
+			assert.Equal(t, []*time.Time{&evening}, matrix.Departures)
+
+			later := evening.Add(40 * time.Minute)
+			_, _, err = service.Get(points, sequence, sequence, "driving", &later)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(matrix.Calls))
+		},
+	)
+
+	t.Run(
+		"Traffic dependent routes are not mixed with others",
+		func(t *testing.T) {
+			matrix := mock.NewMockPointsMatrix(lonRoute)
+			service := services.NewCachedMatrixService(matrix, mock.NewMockMatrixCache(), mock.NewMockMetrics())
+
+			_, _, err := service.Get(points, sequence, sequence, "taxi", &evening)
+			assert.NoError(t, err)
+			_, _, err = service.Get(points, sequence, sequence, "taxi", nil)
+			assert.NoError(t, err)
+			morning := evening.Add(-9 * time.Hour)
+			_, _, err = service.Get(points, sequence, sequence, "taxi", &morning)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, len(matrix.Calls))
+		},
+	)
+
+	t.Run(
+		"Walking does not depend on departure",
+		func(t *testing.T) {
+			matrix := mock.NewMockPointsMatrix(lonRoute)
+			service := services.NewCachedMatrixService(matrix, mock.NewMockMatrixCache(), mock.NewMockMetrics())
+
+			_, _, err := service.Get(points, sequence, sequence, "walking", nil)
+			assert.NoError(t, err)
+			_, _, err = service.Get(points, sequence, sequence, "walking", &evening)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(matrix.Calls))
+		},
+	)
+}
+
+func TestCachedMatrixService_OnlyMissingPairs(t *testing.T) {
+	matrix := mock.NewMockPointsMatrix(lonRoute)
+	service := services.NewCachedMatrixService(matrix, mock.NewMockMatrixCache(), mock.NewMockMetrics())
+
+	points := getCachePoints()
+
+	_, _, err := service.Get(points[:2], []int{0, 1}, []int{0, 1}, "walking", nil)
+	assert.NoError(t, err)
+
+	t.Run(
+		"Only points of missing pairs are requested",
+		func(t *testing.T) {
+			distance, _, err := service.Get(points, []int{0, 1}, []int{1, 2}, "walking", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(matrix.Calls))
+			assert.Equal(t, []domain.Point{points[0], points[1], points[2]}, matrix.Calls[1])
+			assert.Equal(t, [][]int{{-1, 2, 6}, {-1, 0, 4}, {-1, -1, -1}}, distance)
+
+			_, _, err = service.Get(points, []int{2}, []int{0}, "walking", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Point{points[2], points[0]}, matrix.Calls[2])
+		},
+	)
+}
+
+func TestDiskMatrixCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "matrix.json")
+	routes := map[string]domain.CachedRoute{"walking:a:b": {Distance: 10, Duration: 20}}
+
+	t.Run(
+		"Routes survive restart",
+		func(t *testing.T) {
+			cache, err := services.NewDiskMatrixCache(path, time.Hour)
+			assert.NoError(t, err)
+			assert.NoError(t, cache.Set(routes))
+
+			reloaded, err := services.NewDiskMatrixCache(path, time.Hour)
+			assert.NoError(t, err)
+			cached, err := reloaded.Get([]string{"walking:a:b", "walking:b:a"})
+			assert.NoError(t, err)
+			assert.Equal(t, routes, cached)
+		},
+	)
+
+	t.Run(
+		"Expired routes are not returned",
+		func(t *testing.T) {
+			cache, err := services.NewDiskMatrixCache(path, -time.Second)
+			assert.NoError(t, err)
+			assert.NoError(t, cache.Set(routes))
+
+			cached, err := cache.Get([]string{"walking:a:b"})
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]domain.CachedRoute{}, cached)
+		},
+	)
+}
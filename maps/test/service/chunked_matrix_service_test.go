@@ -0,0 +1,96 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/mock"
+	"maps_service/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getChunkedSettings() services.ChunkedMatrixSettings {
+	return services.ChunkedMatrixSettings{
+		ChunkSize:   10,
+		Concurrency: 3,
+		RateLimit:   1000,
+		RateBurst:   100,
+		Retries:     2,
+	}
+}
+
+func TestChunkedMatrixService_Split(t *testing.T) {
+	points := []domain.Point{}
+	sources, targets := []int{}, []int{}
+	for i := 0; i < 37; i += 1 {
+		points = append(points, domain.Point{Lon: 82 + float64(i)/1000, Lat: 55})
+		if i < 25 {
+			sources = append(sources, i)
+		} else {
+			targets = append(targets, i)
+		}
+	}
+
+	matrix := mock.NewMockPointsMatrix(lonRoute)
+	service := services.NewChunkedMatrixService(matrix, getChunkedSettings())
+
+	t.Run(
+		"Matrix is split into chunks and put back together",
+		func(t *testing.T) {
+			distance, duration, err := service.Get(points, sources, targets, "walking", nil)
+			assert.NoError(t, err)
+
+			expectedDistance, expectedDuration, err := mock.NewMockPointsMatrix(lonRoute).Get(points, sources, targets, "walking", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedDistance, distance)
+			assert.Equal(t, expectedDuration, duration)
+
+			assert.Equal(t, 6, len(matrix.Calls))
+			for _, call := range matrix.Calls {
+				assert.LessOrEqual(t, len(call), 20)
+			}
+		},
+	)
+}
+
+func TestChunkedMatrixService_Retries(t *testing.T) {
+	points := getCachePoints()
+	sequence := []int{0, 1, 2}
+
+	t.Run(
+		"Temporary errors are retried",
+		func(t *testing.T) {
+			matrix := mock.NewMockFlakyMatrix(mock.NewMockPointsMatrix(lonRoute), 2, &domain.UpstreamError{StatusCode: 429})
+			service := services.NewChunkedMatrixService(matrix, getChunkedSettings())
+
+			distance, _, err := service.Get(points, sequence, sequence, "walking", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, matrix.Calls)
+			assert.Equal(t, [][]int{{0, 2, 6}, {2, 0, 4}, {6, 4, 0}}, distance)
+		},
+	)
+
+	t.Run(
+		"Retries are limited",
+		func(t *testing.T) {
+			matrix := mock.NewMockFlakyMatrix(mock.NewMockPointsMatrix(lonRoute), 5, &domain.UpstreamError{StatusCode: 502})
+			service := services.NewChunkedMatrixService(matrix, getChunkedSettings())
+
+			_, _, err := service.Get(points, sequence, sequence, "walking", nil)
+			assert.Error(t, err)
+			assert.Equal(t, 3, matrix.Calls)
+		},
+	)
+
+	t.Run(
+		"Client errors are not retried",
+		func(t *testing.T) {
+			matrix := mock.NewMockFlakyMatrix(mock.NewMockPointsMatrix(lonRoute), 1, &domain.UpstreamError{StatusCode: 403})
+			service := services.NewChunkedMatrixService(matrix, getChunkedSettings())
+
+			_, _, err := service.Get(points, sequence, sequence, "walking", nil)
+			assert.Error(t, err)
+			assert.Equal(t, 1, matrix.Calls)
+		},
+	)
+}
@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/services"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const geocoderFixture = `[
+  {"name": "Новосибирск, Красный проспект, 1", "point": {"lon": 82.920430, "lat": 55.020320}},
+  {"name": "Новосибирск, Красный проспект, 25", "point": {"lon": 82.917790, "lat": 55.030210}},
+  {"name": "Новосибирск, улица Ленина, 12", "point": {"lon": 82.911160, "lat": 55.030080}}
+]`
+
+func createFixtureGeocoderService(t *testing.T) *services.FixtureGeocoderService {
+	path := filepath.Join(t.TempDir(), "geocoder.json")
+	assert.NoError(t, os.WriteFile(path, []byte(geocoderFixture), 0644))
+
+	geocoder, err := services.LoadFixtureGeocoderService(path)
+	assert.NoError(t, err)
+	return geocoder
+}
+
+func TestFixtureGeocoderService(t *testing.T) {
+	geocoder := createFixtureGeocoderService(t)
+
+	t.Run(
+		"Geocode matches every word",
+		func(t *testing.T) {
+			addresses, err := geocoder.Geocode("красный проспект, 25")
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Address{
+				{Name: "Новосибирск, Красный проспект, 25", Point: domain.Point{Lon: 82.917790, Lat: 55.030210}},
+			}, addresses)
+
+			addresses, err = geocoder.Geocode("Красный проспект")
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(addresses))
+		},
+	)
+
+	t.Run(
+		"Geocode without candidates",
+		func(t *testing.T) {
+			addresses, err := geocoder.Geocode("Москва")
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Address{}, addresses)
+		},
+	)
+
+	t.Run(
+		"Reverse geocode returns the nearest address",
+		func(t *testing.T) {
+			address, err := geocoder.ReverseGeocode(domain.Point{Lon: 82.9112, Lat: 55.0302})
+			assert.NoError(t, err)
+			assert.Equal(t, "Новосибирск, улица Ленина, 12", address.Name)
+		},
+	)
+
+	t.Run(
+		"Reverse geocode far from addresses",
+		func(t *testing.T) {
+			_, err := geocoder.ReverseGeocode(domain.Point{Lon: 83.0, Lat: 55.0})
+			assert.ErrorIs(t, err, domain.ErrAddressNotFound)
+		},
+	)
+
+	t.Run(
+		"Invalid fixture",
+		func(t *testing.T) {
+			_, err := services.LoadFixtureGeocoderService(filepath.Join(t.TempDir(), "missing.json"))
+			assert.Error(t, err)
+		},
+	)
+}
@@ -0,0 +1,84 @@
+package service_test
+
+import (
+	"encoding/json"
+	"maps_service/internal/api"
+	"maps_service/internal/domain"
+	"maps_service/internal/replay"
+	"maps_service/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Recorded 2GIS answers of the shops of Лента near (82, 55) and of the
+// walking distance from there, the key in them is scrubbed.
+const handlerFixtures = "testdata/2gis"
+
+// newHandlerMux serves the handlers the same way as the maps service, with
+// the 2GIS requests sent through the client.
+func newHandlerMux(client *http.Client) *http.ServeMux {
+	chainRegistry := services.NewChainRegistry([]domain.Chain{
+		{Name: "Лента", Query: "Лента", Enabled: true, Match: domain.ChainMatch{Names: []string{"Лента"}}},
+	})
+	shopsRequester := services.NewShopsRequester(services.NewShopInfo2GisService("", client), chainRegistry)
+	matrixService := services.NewMatrix2GisService("", client)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /shops", api.CreateShopsHandler(shopsRequester))
+	mux.HandleFunc("POST /distance", api.CreateDistanceHandler(matrixService))
+	return mux
+}
+
+func serve(mux *http.ServeMux, path, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return recorder
+}
+
+func TestHandlersWithFixtures(t *testing.T) {
+	client, err := replay.NewClient(failing2Gis(), replay.ModeReplay, handlerFixtures)
+	assert.NoError(t, err)
+	mux := newHandlerMux(client)
+
+	t.Run(
+		"Shops",
+		func(t *testing.T) {
+			recorder := serve(mux, "/shops", `{"point": {"lon": 82.0, "lat": 55.0}, "radius": 1000}`)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+
+			var response struct {
+				Shops []domain.ShopInfo `json:"shops"`
+			}
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			if assert.Len(t, response.Shops, 1) && assert.Len(t, response.Shops[0].Info, 1) {
+				place := response.Shops[0].Info[0]
+				assert.Equal(t, "Лента", response.Shops[0].Shop)
+				assert.Equal(t, "1", place.Id)
+				assert.Equal(t, domain.Point{Lon: 82.0, Lat: 55.0}, place.Point)
+				assert.Equal(t, domain.FormatHypermarket, place.Format)
+			}
+		},
+	)
+
+	t.Run(
+		"Distance",
+		func(t *testing.T) {
+			recorder := serve(mux, "/distance", `{"from": [{"lon": 82.0, "lat": 55.0}], "to": [{"lon": 82.01, "lat": 55.0}], "type": "walking"}`)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.JSONEq(t, `{"info": [{"from": 0, "routes": [{"to": 0, "time": 90, "distance": 120}]}]}`, recorder.Body.String())
+		},
+	)
+
+	t.Run(
+		"Request without a fixture",
+		func(t *testing.T) {
+			recorder := serve(mux, "/distance", `{"from": [{"lon": 82.0, "lat": 55.0}], "to": [{"lon": 82.01, "lat": 55.0}], "type": "driving"}`)
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), "no fixture")
+		},
+	)
+}
@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/services"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const osmFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="55.000" lon="82.000"/>
+  <node id="2" lat="55.000" lon="82.010"/>
+  <node id="3" lat="55.005" lon="82.010"/>
+  <node id="4" lat="55.000" lon="82.020"/>
+  <way id="10">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <tag k="highway" v="residential"/>
+    <tag k="oneway" v="yes"/>
+  </way>
+  <way id="11">
+    <nd ref="2"/>
+    <nd ref="3"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="12">
+    <nd ref="2"/>
+    <nd ref="4"/>
+    <tag k="highway" v="residential"/>
+  </way>
+</osm>
+`
+
+func createOsmMatrixService(t *testing.T) *services.OsmMatrixService {
+	path := filepath.Join(t.TempDir(), "city.osm")
+	assert.NoError(t, os.WriteFile(path, []byte(osmFixture), 0644))
+
+	service, err := services.NewOsmMatrixService(path)
+	assert.NoError(t, err)
+	return service
+}
+
+func TestOsmMatrixService(t *testing.T) {
+	service := createOsmMatrixService(t)
+
+	points := []domain.Point{
+		{Lon: 82.000, Lat: 55.000},
+		{Lon: 82.010, Lat: 55.005},
+		{Lon: 82.020, Lat: 55.000},
+	}
+	sequence := []int{0, 1, 2}
+
+	t.Run(
+		"Walking ignores oneway and uses footways",
+		func(t *testing.T) {
+			distance, duration, err := service.Get(points, sequence, sequence, "walking", nil)
+			assert.NoError(t, err)
+
+			assert.InDelta(t, 1194, distance[0][1], 5)
+			assert.InDelta(t, 860, duration[0][1], 5)
+			assert.InDelta(t, 1277, distance[2][0], 5)
+			assert.Equal(t, 0, distance[1][1])
+		},
+	)
+
+	t.Run(
+		"Driving respects oneway",
+		func(t *testing.T) {
+			distance, duration, err := service.Get(points, []int{0, 2}, []int{0, 2}, "driving", nil)
+			assert.NoError(t, err)
+
+			assert.InDelta(t, 1277, distance[0][2], 5)
+			assert.InDelta(t, 184, duration[0][2], 5)
+			assert.Equal(t, -1, distance[2][0])
+			assert.Equal(t, -1, duration[2][0])
+		},
+	)
+
+	t.Run(
+		"Unsupported transport",
+		func(t *testing.T) {
+			_, _, err := service.Get(points, sequence, sequence, "bicycle", nil)
+			assert.Error(t, err)
+		},
+	)
+}
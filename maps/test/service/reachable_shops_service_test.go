@@ -0,0 +1,83 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/mock"
+	"maps_service/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReachableShopsService(t *testing.T) {
+	shopsService := services.NewShopsRequester(mock.NewMockShopInfo(map[string]domain.ShopInfo{
+		"Лента": {
+			Shop: "Лента",
+			Info: []domain.Place{
+				{Id: "1", Point: domain.Point{Lon: 82.010, Lat: 55.0}},
+				{Id: "2", Point: domain.Point{Lon: 82.100, Lat: 55.0}},
+			},
+		},
+		"Перекрёсток": {
+			Shop: "Перекрёсток",
+			Info: []domain.Place{
+				{Id: "3", Point: domain.Point{Lon: 82.005, Lat: 55.0}},
+			},
+		},
+	}), getChainRegistry())
+	point := domain.Point{Lon: 82.0, Lat: 55.0}
+
+	t.Run(
+		"Shops are filtered by travel time",
+		func(t *testing.T) {
+			matrix := mock.NewMockPointsMatrix(lonRoute)
+			service := services.NewReachableShopsService(shopsService, matrix)
+
+			shops, err := service.Get(point, "walking", 20)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.ShopInfo{
+				{Shop: "Лента", Info: []domain.Place{{Id: "1", Point: domain.Point{Lon: 82.010, Lat: 55.0}}}},
+				{Shop: "Перекрёсток", Info: []domain.Place{{Id: "3", Point: domain.Point{Lon: 82.005, Lat: 55.0}}}},
+			}, shops)
+			assert.Equal(t, 1, len(matrix.Calls))
+		},
+	)
+
+	t.Run(
+		"Unreachable shops are skipped",
+		func(t *testing.T) {
+			unreachable := func(from, to domain.Point) (int, int) { return -1, -1 }
+			service := services.NewReachableShopsService(shopsService, mock.NewMockPointsMatrix(unreachable))
+
+			shops, err := service.Get(point, "driving", 3600)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.ShopInfo{
+				{Shop: "Лента", Info: []domain.Place{}},
+				{Shop: "Перекрёсток", Info: []domain.Place{}},
+			}, shops)
+		},
+	)
+
+	t.Run(
+		"Invalid requests",
+		func(t *testing.T) {
+			service := services.NewReachableShopsService(shopsService, mock.NewMockPointsMatrix(lonRoute))
+
+			_, err := service.Get(point, "bicycle", 600)
+			assert.Error(t, err)
+			_, err = service.Get(point, "walking", 0)
+			assert.Error(t, err)
+		},
+	)
+
+	t.Run(
+		"Matrix error",
+		func(t *testing.T) {
+			matrix := mock.NewMockMatrix(map[string]mock.ReturnData{})
+			service := services.NewReachableShopsService(shopsService, matrix)
+
+			_, err := service.Get(point, "taxi", 600)
+			assert.Error(t, err)
+		},
+	)
+}
@@ -0,0 +1,135 @@
+package service_test
+
+import (
+	"fmt"
+	"io"
+	"maps_service/internal/domain"
+	"maps_service/internal/replay"
+	"maps_service/internal/services"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testApiKey = "secret-test-key"
+
+// fake2Gis answers the shops and matrix requests the way 2GIS does.
+func fake2Gis(calls *int) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		*calls += 1
+		body := `{"routes": [{"source_id": 0, "target_id": 1, "distance": 120, "duration": 90, "status": "OK"}]}`
+		if req.URL.Host == "catalog.api.2gis.com" {
+			body = `{"result": {"items": [{
+				"name": "Лента, гипермаркет",
+				"id": "1",
+				"point": {"lon": 82.0, "lat": 55.0},
+				"org": {"name": "Лента"},
+				"rubrics": [{"name": "Гипермаркеты"}]
+			}]}}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+}
+
+func failing2Gis() http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, fmt.Errorf("network is not available in replay")
+	})
+}
+
+func TestReplayTransport(t *testing.T) {
+	dir := t.TempDir()
+	chain := domain.Chain{Name: "Лента", Query: "Лента", Match: domain.ChainMatch{Names: []string{"Лента"}}}
+	points := []domain.Point{{Lon: 82.0, Lat: 55.0}, {Lon: 82.01, Lat: 55.0}}
+
+	calls := 0
+	recordClient, err := replay.NewClient(fake2Gis(&calls), replay.ModeRecord, dir, testApiKey)
+	assert.NoError(t, err)
+
+	recordedShops, err := services.NewShopInfo2GisService(testApiKey, recordClient).Get(chain, points[0], 1000)
+	assert.NoError(t, err)
+	recordedDistance, _, err := services.NewMatrix2GisService(testApiKey, recordClient).Get(points, []int{0}, []int{1}, "walking", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	t.Run(
+		"Fixtures have the key scrubbed",
+		func(t *testing.T) {
+			files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(files))
+			for _, file := range files {
+				bytes, err := os.ReadFile(file)
+				assert.NoError(t, err)
+				assert.NotContains(t, string(bytes), testApiKey)
+			}
+		},
+	)
+
+	t.Run(
+		"Recorded requests are replayed with another key",
+		func(t *testing.T) {
+			replayClient, err := replay.NewClient(failing2Gis(), replay.ModeReplay, dir)
+			assert.NoError(t, err)
+
+			shops, err := services.NewShopInfo2GisService("other-key", replayClient).Get(chain, points[0], 1000)
+			assert.NoError(t, err)
+			assert.Equal(t, recordedShops, shops)
+			assert.Equal(t, domain.FormatHypermarket, shops.Info[0].Format)
+
+			distance, _, err := services.NewMatrix2GisService("other-key", replayClient).Get(points, []int{0}, []int{1}, "walking", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, recordedDistance, distance)
+		},
+	)
+
+	t.Run(
+		"Unknown request is not replayed",
+		func(t *testing.T) {
+			replayClient, err := replay.NewClient(failing2Gis(), replay.ModeReplay, dir)
+			assert.NoError(t, err)
+
+			_, _, err = services.NewMatrix2GisService(testApiKey, replayClient).Get(points, []int{0}, []int{1}, "driving", nil)
+			assert.ErrorContains(t, err, "no fixture")
+		},
+	)
+
+	t.Run(
+		"Errors of the API are not recorded",
+		func(t *testing.T) {
+			dir := t.TempDir()
+			forbidden := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`{"message": "Invalid key"}`))}, nil
+			})
+			client, err := replay.NewClient(forbidden, replay.ModeRecord, dir, testApiKey)
+			assert.NoError(t, err)
+
+			_, _, err = services.NewMatrix2GisService(testApiKey, client).Get(points, []int{0}, []int{1}, "walking", nil)
+			assert.Error(t, err)
+			files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			assert.NoError(t, err)
+			assert.Empty(t, files)
+		},
+	)
+
+	t.Run(
+		"Passthrough and invalid modes",
+		func(t *testing.T) {
+			calls := 0
+			client, err := replay.NewClient(fake2Gis(&calls), replay.ModePassthrough, "")
+			assert.NoError(t, err)
+			_, _, err = services.NewMatrix2GisService(testApiKey, client).Get(points, []int{0}, []int{1}, "walking", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, calls)
+
+			_, err = replay.NewClient(nil, replay.ModeReplay, "")
+			assert.Error(t, err)
+			_, err = replay.NewClient(nil, "mock", dir)
+			assert.Error(t, err)
+		},
+	)
+}
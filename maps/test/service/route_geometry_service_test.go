@@ -0,0 +1,105 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"maps_service/internal/mock"
+	"maps_service/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteGeometryService(t *testing.T) {
+	points := []domain.Point{
+		{Lon: 82.000, Lat: 55.000},
+		{Lon: 82.010, Lat: 55.000},
+		{Lon: 82.010, Lat: 55.010},
+	}
+
+	t.Run(
+		"Legs follow the route order",
+		func(t *testing.T) {
+			legService := mock.NewMockRouteLeg(map[string]float64{"walking": 1})
+			service := services.NewRouteGeometryService(legService)
+
+			geometry, err := service.Get(points, []int{0, 2, 1, 0}, "walking")
+			assert.NoError(t, err)
+
+			assert.Equal(t, "walking", geometry.Transport)
+			assert.Equal(t, 3, len(geometry.Legs))
+			assert.Equal(t, 3, legService.Calls)
+
+			distance, duration := 0, 0
+			for i, leg := range geometry.Legs {
+				assert.Equal(t, []int{0, 2, 1, 0}[i], leg.From)
+				assert.Equal(t, []int{0, 2, 1, 0}[i+1], leg.To)
+				assert.Equal(t, "LineString", leg.Geometry.Type)
+				distance += leg.Distance
+				duration += leg.Duration
+			}
+			assert.Equal(t, distance, geometry.Distance)
+			assert.Equal(t, duration, geometry.Duration)
+			assert.InDelta(t, 1112, geometry.Legs[1].Distance, 2)
+		},
+	)
+
+	t.Run(
+		"Leg between the same points is empty",
+		func(t *testing.T) {
+			legService := mock.NewMockRouteLeg(map[string]float64{"taxi": 10})
+			service := services.NewRouteGeometryService(legService)
+
+			geometry, err := service.Get(points, []int{1, 1}, "taxi")
+			assert.NoError(t, err)
+			assert.Equal(t, 0, legService.Calls)
+			assert.Equal(t, 0, geometry.Distance)
+			assert.Equal(t, [][2]float64{{82.010, 55.000}, {82.010, 55.000}}, geometry.Legs[0].Geometry.Coordinates)
+		},
+	)
+
+	t.Run(
+		"Invalid requests",
+		func(t *testing.T) {
+			service := services.NewRouteGeometryService(mock.NewMockRouteLeg(map[string]float64{"walking": 1}))
+
+			_, err := service.Get(points, []int{0, 1}, "bicycle")
+			assert.Error(t, err)
+			_, err = service.Get(points, []int{0}, "walking")
+			assert.Error(t, err)
+			_, err = service.Get(points, []int{0, 3}, "walking")
+			assert.Error(t, err)
+		},
+	)
+
+	t.Run(
+		"Provider error",
+		func(t *testing.T) {
+			service := services.NewRouteGeometryService(mock.NewMockRouteLeg(map[string]float64{}))
+
+			_, err := service.Get(points, []int{0, 1}, "driving")
+			assert.Error(t, err)
+		},
+	)
+}
+
+func TestParseLineString(t *testing.T) {
+	t.Run(
+		"Valid linestring",
+		func(t *testing.T) {
+			coordinates, err := geo.ParseLineString("LINESTRING(82.9 55.01, 82.91 55.02)")
+			assert.NoError(t, err)
+			assert.Equal(t, [][2]float64{{82.9, 55.01}, {82.91, 55.02}}, coordinates)
+		},
+	)
+
+	t.Run(
+		"Invalid linestring",
+		func(t *testing.T) {
+			for _, wkt := range []string{"POINT(82.9 55.01)", "LINESTRING(82.9)", "LINESTRING(a b)"} {
+				_, err := geo.ParseLineString(wkt)
+				assert.Error(t, err)
+			}
+		},
+	)
+}
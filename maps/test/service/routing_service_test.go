@@ -33,8 +33,10 @@ func TestRoutingService_WithErrorsMatrix(t *testing.T) {
 						},
 					},
 					0,
+					0,
 					false,
-				),
+					nil,
+				).Routes,
 				"routing service should return empty array without errors",
 			)
 		},
@@ -68,26 +70,10 @@ func TestRoutingService_Correct(t *testing.T) {
 				t,
 				[]domain.MinTimeRoute{
 					{
-						Points: []domain.Point{
-							{
-								Lon: 82.876501,
-								Lat: 55.009161,
-							},
-							{
-								Lon: 82.839666,
-								Lat: 54.969566,
-							},
-							{
-								Lon: 82.876501,
-								Lat: 555.009161,
-							},
-							{
-								Lon: 82.876501,
-								Lat: 55.009161,
-							},
-						},
+						Points:    []int{0, 1, 2, 0},
 						Duration:  6,
 						Transport: "walking",
+						Optimal:   true,
 					},
 				},
 				service.Get(
@@ -106,8 +92,10 @@ func TestRoutingService_Correct(t *testing.T) {
 						},
 					},
 					0,
+					0,
 					true,
-				),
+					nil,
+				).Routes,
 				"routing service should return correct data for walking",
 			)
 		},
@@ -120,26 +108,10 @@ func TestRoutingService_Correct(t *testing.T) {
 				t,
 				[]domain.MinTimeRoute{
 					{
-						Points: []domain.Point{
-							{
-								Lon: 82.876501,
-								Lat: 55.009161,
-							},
-							{
-								Lon: 82.839666,
-								Lat: 54.969566,
-							},
-							{
-								Lon: 82.876501,
-								Lat: 55.009161,
-							},
-							{
-								Lon: 82.876501,
-								Lat: 55.009161,
-							},
-						},
+						Points:    []int{0, 1, 2, 0},
 						Duration:  16,
 						Transport: "walking",
+						Optimal:   true,
 					},
 				},
 				service.Get(
@@ -158,10 +130,62 @@ func TestRoutingService_Correct(t *testing.T) {
 						},
 					},
 					0,
+					0,
 					false,
-				),
+					nil,
+				).Routes,
 				"routing service should return correct data for walking",
 			)
 		},
 	)
 }
+
+func TestRoutingService_Statuses(t *testing.T) {
+	points := []domain.Point{{Lon: 82.0, Lat: 55.0}, {Lon: 82.01, Lat: 55.0}}
+	service := services.NewRoutingService(mock.NewMockMatrix(map[string]mock.ReturnData{
+		"walking": {
+			Distance: [][]int{{0, -1}, {-1, 0}},
+			Duration: [][]int{{0, -1}, {-1, 0}},
+		},
+		"taxi": {E: &domain.UpstreamError{StatusCode: 429, Message: "quota"}},
+		"driving": {
+			Distance: [][]int{{0, 10}, {10, 0}},
+			Duration: [][]int{{0, 20}, {20, 0}},
+		},
+	}), services.NewTSPBruteforce())
+
+	t.Run(
+		"Each transport has its status",
+		func(t *testing.T) {
+			result := service.Get(points, 0, 0, false, nil)
+			assert.Equal(t, []domain.MinTimeRoute{{Points: []int{0, 1, 0}, Duration: 40, Transport: "driving", Optimal: true}}, result.Routes)
+			assert.Equal(t, []domain.TransportStatus{
+				{Transport: "walking", Status: domain.RouteStatusNoPath, Error: "no path"},
+				{Transport: "taxi", Status: domain.RouteStatusQuotaExceeded, Error: "api returned status 429: quota"},
+				{Transport: "driving", Status: domain.RouteStatusOk},
+			}, result.Statuses)
+		},
+	)
+
+	t.Run(
+		"Invalid input",
+		func(t *testing.T) {
+			result := service.Get(points, 2, 2, false, nil)
+			assert.Equal(t, []domain.MinTimeRoute{}, result.Routes)
+			assert.Equal(t, domain.RouteStatusInvalidInput, result.Statuses[2].Status)
+		},
+	)
+
+	t.Run(
+		"Upstream and unknown errors",
+		func(t *testing.T) {
+			service := services.NewRoutingService(mock.NewMockMatrix(map[string]mock.ReturnData{
+				"walking": {E: &domain.UpstreamError{StatusCode: 503, Message: "unavailable"}},
+			}), services.NewTSPBruteforce())
+
+			result := service.Get(points, 0, 0, false, nil)
+			assert.Equal(t, domain.RouteStatusUpstreamError, result.Statuses[0].Status)
+			assert.Equal(t, domain.RouteStatusError, result.Statuses[1].Status)
+		},
+	)
+}
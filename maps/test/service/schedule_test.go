@@ -0,0 +1,138 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/schedule"
+	"maps_service/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	novosibirsk, err := time.LoadLocation("Asia/Novosibirsk")
+	assert.NoError(t, err)
+	// Monday.
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 15, hour, minute, 0, 0, novosibirsk)
+	}
+
+	t.Run(
+		"Day schedule",
+		func(t *testing.T) {
+			parsed, err := schedule.Parse(everyDaySchedule("09:00", "21:00"), novosibirsk)
+			assert.NoError(t, err)
+
+			assert.False(t, parsed.IsOpenAt(monday(8, 59)))
+			assert.True(t, parsed.IsOpenAt(monday(9, 0)))
+			assert.False(t, parsed.IsOpenAt(monday(21, 0)))
+
+			next, ok := parsed.NextOpening(monday(22, 0))
+			assert.True(t, ok)
+			assert.True(t, monday(33, 0).Equal(next))
+
+			next, ok = parsed.NextOpening(monday(10, 0))
+			assert.True(t, ok)
+			assert.True(t, monday(10, 0).Equal(next))
+		},
+	)
+
+	t.Run(
+		"Overnight schedule",
+		func(t *testing.T) {
+			parsed, err := schedule.Parse(everyDaySchedule("22:00", "02:00"), novosibirsk)
+			assert.NoError(t, err)
+
+			assert.True(t, parsed.IsOpenAt(monday(1, 30)))
+			assert.False(t, parsed.IsOpenAt(monday(2, 0)))
+			assert.True(t, parsed.IsOpenAt(monday(23, 0)))
+		},
+	)
+
+	t.Run(
+		"Round the clock schedule",
+		func(t *testing.T) {
+			parsed, err := schedule.Parse(everyDaySchedule("00:00", "24:00"), novosibirsk)
+			assert.NoError(t, err)
+
+			assert.False(t, parsed.IsAlwaysOpen())
+			assert.True(t, parsed.IsOpenAt(monday(0, 0)))
+			assert.True(t, parsed.IsOpenAt(monday(23, 59)))
+		},
+	)
+
+	t.Run(
+		"Time zone of the schedule",
+		func(t *testing.T) {
+			parsed, err := schedule.Parse(everyDaySchedule("09:00", "21:00"), novosibirsk)
+			assert.NoError(t, err)
+
+			// 09:30 in Moscow is 13:30 in Novosibirsk.
+			moscow := time.FixedZone("MSK", 3*60*60)
+			assert.True(t, parsed.IsOpenAt(time.Date(2024, 1, 15, 9, 30, 0, 0, moscow)))
+			// 18:30 in Moscow is 22:30 in Novosibirsk.
+			assert.False(t, parsed.IsOpenAt(time.Date(2024, 1, 15, 18, 30, 0, 0, moscow)))
+		},
+	)
+
+	t.Run(
+		"Closed days",
+		func(t *testing.T) {
+			weekend := &domain.Schedule{
+				Saturday: domain.DaySchedule{WorkingHours: []domain.WorkingHours{{From: "10:00", To: "18:00"}}},
+			}
+			parsed, err := schedule.Parse(weekend, novosibirsk)
+			assert.NoError(t, err)
+
+			assert.False(t, parsed.IsOpenAt(monday(12, 0)))
+			next, ok := parsed.NextOpening(monday(12, 0))
+			assert.True(t, ok)
+			assert.True(t, time.Date(2024, 1, 20, 10, 0, 0, 0, novosibirsk).Equal(next))
+		},
+	)
+
+	t.Run(
+		"No schedule is always open",
+		func(t *testing.T) {
+			parsed, err := schedule.Parse(&domain.Schedule{}, novosibirsk)
+			assert.NoError(t, err)
+			assert.True(t, parsed.IsAlwaysOpen())
+			assert.True(t, parsed.IsOpenAt(monday(3, 0)))
+		},
+	)
+
+	t.Run(
+		"Invalid schedule",
+		func(t *testing.T) {
+			_, err := schedule.Parse(everyDaySchedule("09:00", "25:00"), novosibirsk)
+			assert.Error(t, err)
+		},
+	)
+}
+
+func TestFilterOpenShops(t *testing.T) {
+	shops := []domain.ShopInfo{
+		{
+			Shop: "Лента",
+			Info: []domain.Place{
+				{Id: "1", Schedule: *everyDaySchedule("00:00", "24:00")},
+				{Id: "2", Schedule: *everyDaySchedule("09:00", "21:00"), TimeZone: "Asia/Novosibirsk"},
+				{Id: "3"},
+			},
+		},
+	}
+
+	// 23:00 in Novosibirsk.
+	at := time.Date(2024, 1, 15, 16, 0, 0, 0, time.UTC)
+
+	result := services.FilterOpenShops(shops, at)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "Лента", result[0].Shop)
+
+	ids := []string{}
+	for _, place := range result[0].Info {
+		ids = append(ids, place.Id)
+	}
+	assert.Equal(t, []string{"1", "3"}, ids)
+}
@@ -0,0 +1,173 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/geo"
+	"maps_service/internal/mock"
+	"maps_service/internal/services"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getCatalogueCities() []domain.City {
+	return []domain.City{{Name: "Новосибирск", Point: domain.Point{Lon: 82.0, Lat: 55.0}, Radius: 3000}}
+}
+
+func getCataloguePlaces() map[string]domain.ShopInfo {
+	return map[string]domain.ShopInfo{
+		"Лента": {
+			Shop: "Лента",
+			Info: []domain.Place{
+				{Id: "1", Point: domain.Point{Lon: 82.01, Lat: 55.0}},
+				{Id: "2", Point: domain.Point{Lon: 82.002, Lat: 55.0}},
+				{Id: "3", Point: domain.Point{Lon: 82.2, Lat: 55.0}},
+			},
+		},
+		"Перекрёсток": {
+			Shop: "Перекрёсток",
+			Info: []domain.Place{
+				{Id: "4", Point: domain.Point{Lon: 82.0, Lat: 55.001}},
+			},
+		},
+	}
+}
+
+func TestShopCatalogue_Lookup(t *testing.T) {
+	shopInfo := mock.NewMockShopInfo(getCataloguePlaces())
+	catalogue, err := services.NewShopCatalogue(shopInfo, getChainRegistry(), getCatalogueCities(), "")
+	assert.NoError(t, err)
+	lenta := domain.Chain{Name: "Лента"}
+	point := domain.Point{Lon: 82.0, Lat: 55.0}
+
+	t.Run(
+		"Live lookup before the first sync",
+		func(t *testing.T) {
+			shops, err := catalogue.Get(lenta, point, 1000)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, len(shops.Info))
+			assert.Equal(t, 1, shopInfo.Calls)
+			assert.Nil(t, catalogue.GetLastDiff())
+		},
+	)
+
+	t.Run(
+		"Lookup inside the city is served from the index",
+		func(t *testing.T) {
+			_, err := catalogue.Sync()
+			assert.NoError(t, err)
+			calls := shopInfo.Calls
+
+			shops, err := catalogue.Get(lenta, point, 1000)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Place{{Id: "2", Point: domain.Point{Lon: 82.002, Lat: 55.0}}, {Id: "1", Point: domain.Point{Lon: 82.01, Lat: 55.0}}}, shops.Info)
+			assert.Equal(t, calls, shopInfo.Calls)
+
+			shops, err = catalogue.Get(lenta, point, 300)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Place{{Id: "2", Point: domain.Point{Lon: 82.002, Lat: 55.0}}}, shops.Info)
+			assert.Equal(t, calls, shopInfo.Calls)
+		},
+	)
+
+	t.Run(
+		"Lookup leaving the city falls back to the live one",
+		func(t *testing.T) {
+			calls := shopInfo.Calls
+			shops, err := catalogue.Get(lenta, point, 5000)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, len(shops.Info))
+			assert.Equal(t, calls+1, shopInfo.Calls)
+		},
+	)
+
+	t.Run(
+		"Shops requester uses the catalogue for areas",
+		func(t *testing.T) {
+			area, err := geo.NewCorridorArea(&domain.Corridor{
+				Line:   domain.LineString{Type: "LineString", Coordinates: [][2]float64{{81.99, 55.0}, {82.005, 55.0}}},
+				Buffer: 200,
+			})
+			assert.NoError(t, err)
+
+			calls := shopInfo.Calls
+			shops, err := services.NewShopsRequester(catalogue, getChainRegistry()).GetShopsInArea(area)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.ShopInfo{
+				{Shop: "Лента", Info: []domain.Place{{Id: "2", Point: domain.Point{Lon: 82.002, Lat: 55.0}}}},
+				{Shop: "Перекрёсток", Info: []domain.Place{{Id: "4", Point: domain.Point{Lon: 82.0, Lat: 55.001}}}},
+			}, shops)
+			assert.Equal(t, calls, shopInfo.Calls)
+		},
+	)
+}
+
+func TestShopCatalogue_Sync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalogue.json")
+	shopInfo := mock.NewMockShopInfo(getCataloguePlaces())
+	catalogue, err := services.NewShopCatalogue(shopInfo, getChainRegistry(), getCatalogueCities(), path)
+	assert.NoError(t, err)
+
+	diff, err := catalogue.Sync()
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.CatalogueChange{}, diff.Opened, "first sync has nothing to compare with")
+
+	t.Run(
+		"Diff of opened and closed shops",
+		func(t *testing.T) {
+			places := getCataloguePlaces()
+			places["Лента"] = domain.ShopInfo{Shop: "Лента", Info: []domain.Place{
+				{Id: "2", Point: domain.Point{Lon: 82.002, Lat: 55.0}},
+				{Id: "5", Point: domain.Point{Lon: 82.0, Lat: 55.01}},
+			}}
+			shopInfo.SetShopsInfo(places)
+
+			diff, err := catalogue.Sync()
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.CatalogueChange{{Shop: "Лента", Place: domain.Place{Id: "5", Point: domain.Point{Lon: 82.0, Lat: 55.01}}}}, diff.Opened)
+			assert.Equal(t, []domain.CatalogueChange{{Shop: "Лента", Place: domain.Place{Id: "1", Point: domain.Point{Lon: 82.01, Lat: 55.0}}}}, diff.Closed)
+			assert.Equal(t, diff, catalogue.GetLastDiff())
+		},
+	)
+
+	t.Run(
+		"Failed chain keeps its places",
+		func(t *testing.T) {
+			places := getCataloguePlaces()
+			delete(places, "Перекрёсток")
+			shopInfo.SetShopsInfo(places)
+
+			diff, err := catalogue.Sync()
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.CatalogueChange{{Shop: "Лента", Place: domain.Place{Id: "5", Point: domain.Point{Lon: 82.0, Lat: 55.01}}}}, diff.Closed)
+
+			shops, err := catalogue.Get(domain.Chain{Name: "Перекрёсток"}, domain.Point{Lon: 82.0, Lat: 55.0}, 500)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(shops.Info))
+		},
+	)
+
+	t.Run(
+		"Sync fails when no chain is synced",
+		func(t *testing.T) {
+			shopInfo.SetShopsInfo(map[string]domain.ShopInfo{})
+			_, err := catalogue.Sync()
+			assert.Error(t, err)
+		},
+	)
+
+	t.Run(
+		"Places survive restart",
+		func(t *testing.T) {
+			reloaded, err := services.NewShopCatalogue(shopInfo, getChainRegistry(), getCatalogueCities(), path)
+			assert.NoError(t, err)
+
+			calls := shopInfo.Calls
+			shops, err := reloaded.Get(domain.Chain{Name: "Лента"}, domain.Point{Lon: 82.0, Lat: 55.0}, 2000)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(shops.Info))
+			assert.Equal(t, calls, shopInfo.Calls)
+		},
+	)
+}
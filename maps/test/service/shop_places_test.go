@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/mock"
+	"maps_service/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFormat(t *testing.T) {
+	magnit := domain.Chain{Name: "Магнит", Formats: map[string][]string{domain.FormatHypermarket: {"Магнит Семейный"}}}
+
+	t.Run(
+		"Formats by names",
+		func(t *testing.T) {
+			assert.Equal(t, domain.FormatHypermarket, services.ClassifyFormat(domain.Chain{}, "Гипер Лента, гипермаркет", nil))
+			assert.Equal(t, domain.FormatExpress, services.ClassifyFormat(domain.Chain{}, "Перекресток Экспресс, супермаркет", nil))
+			assert.Equal(t, domain.FormatCosmetics, services.ClassifyFormat(magnit, "Магнит Косметик, магазин", []string{"Супермаркеты"}))
+			assert.Equal(t, domain.FormatExpress, services.ClassifyFormat(magnit, "Магнит у дома", nil))
+		},
+	)
+
+	t.Run(
+		"Sub-brands of the chain come first",
+		func(t *testing.T) {
+			assert.Equal(t, domain.FormatHypermarket, services.ClassifyFormat(magnit, "Магнит Семейный, супермаркет", nil))
+		},
+	)
+
+	t.Run(
+		"Formats by rubrics",
+		func(t *testing.T) {
+			assert.Equal(t, domain.FormatHypermarket, services.ClassifyFormat(domain.Chain{}, "Лента", []string{"Гипермаркеты"}))
+			assert.Equal(t, domain.FormatSupermarket, services.ClassifyFormat(domain.Chain{}, "Дикси", []string{"Продукты"}))
+		},
+	)
+}
+
+func TestFilterShopFormats(t *testing.T) {
+	shops := []domain.ShopInfo{{Shop: "Магнит", Info: []domain.Place{
+		{Id: "1", Format: domain.FormatSupermarket},
+		{Id: "2", Format: domain.FormatCosmetics},
+		{Id: "3", Format: domain.FormatHypermarket},
+	}}}
+
+	assert.Equal(t, []domain.ShopInfo{{Shop: "Магнит", Info: []domain.Place{
+		{Id: "1", Format: domain.FormatSupermarket},
+		{Id: "3", Format: domain.FormatHypermarket},
+	}}}, services.FilterShopFormats(shops, nil, []string{domain.FormatCosmetics}))
+	assert.Equal(t, []domain.ShopInfo{{Shop: "Магнит", Info: []domain.Place{
+		{Id: "3", Format: domain.FormatHypermarket},
+	}}}, services.FilterShopFormats(shops, []string{domain.FormatHypermarket, domain.FormatCosmetics}, []string{domain.FormatCosmetics}))
+}
+
+func TestShopsRequester_MergeDuplicates(t *testing.T) {
+	shopsService := services.NewShopsRequester(mock.NewMockShopInfo(map[string]domain.ShopInfo{
+		"Лента": {
+			Shop: "Лента",
+			Info: []domain.Place{
+				{Id: "1", Name: "Лента, гипермаркет", Point: domain.Point{Lon: 82.0, Lat: 55.0}},
+				{Id: "2", Name: "Гипер Лента, гипермаркет", Point: domain.Point{Lon: 82.0003, Lat: 55.0}},
+				{Id: "3", Name: "Лента, гипермаркет", Point: domain.Point{Lon: 82.01, Lat: 55.0}},
+			},
+		},
+		"Перекрёсток": {
+			Shop: "Перекрёсток",
+			Info: []domain.Place{
+				{Id: "1", Name: "Лента, гипермаркет", Point: domain.Point{Lon: 82.0, Lat: 55.0}},
+				{Id: "4", Name: "Перекрёсток, супермаркет", Point: domain.Point{Lon: 82.0001, Lat: 55.0}},
+			},
+		},
+	}), getChainRegistry())
+
+	t.Run(
+		"Near places with similar names are merged",
+		func(t *testing.T) {
+			shops := shopsService.GetNearbyShops(domain.Point{Lon: 82.0, Lat: 55.0}, 1000)
+			assert.Equal(t, []domain.ShopInfo{
+				{Shop: "Лента", Info: []domain.Place{
+					{Id: "1", Name: "Лента, гипермаркет", Point: domain.Point{Lon: 82.0, Lat: 55.0}},
+					{Id: "3", Name: "Лента, гипермаркет", Point: domain.Point{Lon: 82.01, Lat: 55.0}},
+				}},
+				{Shop: "Перекрёсток", Info: []domain.Place{
+					{Id: "4", Name: "Перекрёсток, супермаркет", Point: domain.Point{Lon: 82.0001, Lat: 55.0}},
+				}},
+			}, shops)
+		},
+	)
+
+	t.Run(
+		"Near places of different formats are kept",
+		func(t *testing.T) {
+			places := []domain.Place{
+				{Id: "5", Name: "Лента", Point: domain.Point{Lon: 83.0, Lat: 55.0}, Format: domain.FormatHypermarket},
+				{Id: "6", Name: "Лента", Point: domain.Point{Lon: 83.0001, Lat: 55.0}, Format: domain.FormatCosmetics},
+			}
+			shopsService := services.NewShopsRequester(mock.NewMockShopInfo(map[string]domain.ShopInfo{
+				"Лента":       {Shop: "Лента", Info: places},
+				"Перекрёсток": {Shop: "Перекрёсток", Info: []domain.Place{}},
+			}), getChainRegistry())
+
+			shops := shopsService.GetNearbyShops(domain.Point{Lon: 83.0, Lat: 55.0}, 1000)
+			assert.Equal(t, []domain.ShopInfo{
+				{Shop: "Лента", Info: places},
+				{Shop: "Перекрёсток", Info: []domain.Place{}},
+			}, shops)
+		},
+	)
+}
@@ -1,9 +1,14 @@
 package service_test
 
 import (
+	"fmt"
+	"io"
 	"maps_service/internal/domain"
 	"maps_service/internal/mock"
 	"maps_service/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -284,11 +289,19 @@ func getShopsData() map[string]domain.ShopInfo {
 	}
 }
 
+func getChainRegistry() *services.ChainRegistry {
+	return services.NewChainRegistry([]domain.Chain{
+		{Name: "Лента", Query: "Лента", Enabled: true},
+		{Name: "Перекрёсток", Query: "Перекрёсток", Enabled: true},
+		{Name: "Дикси", Query: "Дикси", Enabled: false},
+	})
+}
+
 func TestShopsService(t *testing.T) {
 	shopsData := getShopsData()
 	mock := mock.NewMockShopInfo(shopsData)
 
-	shopsService := services.NewShopsRequester(mock)
+	shopsService := services.NewShopsRequester(mock, getChainRegistry())
 
 	t.Run(
 		"AvailableShops",
@@ -325,7 +338,7 @@ func TestShopsService_NoShops(t *testing.T) {
 	shopsData := make(map[string]domain.ShopInfo)
 	mock := mock.NewMockShopInfo(shopsData)
 
-	shopsService := services.NewShopsRequester(mock)
+	shopsService := services.NewShopsRequester(mock, getChainRegistry())
 
 	t.Run(
 		"GetNearbyShops_NoShops",
@@ -345,7 +358,7 @@ func TestShopsService_NoПерекрёсток(t *testing.T) {
 	delete(shopsData, "Перекрёсток")
 	mock := mock.NewMockShopInfo(shopsData)
 
-	shopsService := services.NewShopsRequester(mock)
+	shopsService := services.NewShopsRequester(mock, getChainRegistry())
 
 	t.Run(
 		"GetNearbyShops_NoShops",
@@ -361,3 +374,81 @@ func TestShopsService_NoПерекрёсток(t *testing.T) {
 		},
 	)
 }
+
+func TestChainRegistry_Config(t *testing.T) {
+	registry, err := services.LoadChainRegistry("../../configs/chains.json")
+	assert.NoError(t, err)
+
+	t.Run(
+		"Config contains all chains",
+		func(t *testing.T) {
+			shopsService := services.NewShopsRequester(mock.NewMockShopInfo(nil), registry)
+			assert.Equal(
+				t,
+				[]string{
+					"Лента",
+					"Перекрёсток",
+					"Дикси",
+					"Магнит",
+				},
+				shopsService.GetAvailableShops(),
+				"ShopsService return incorrect available shops",
+			)
+		},
+	)
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// paged2Gis serves the places of the chain by the pages of the request.
+func paged2Gis(total int, requests *[]string) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		*requests = append(*requests, req.URL.Query().Get("page"))
+		page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(req.URL.Query().Get("page_size"))
+
+		items := []string{}
+		for i := (page - 1) * size; i < min(page*size, total); i += 1 {
+			items = append(items, fmt.Sprintf(`{"id": "%d", "name": "Лента", "point": {"lon": 82.0, "lat": 55.0}, "org": {"name": "Лента"}}`, i))
+		}
+		// The last place of the page is repeated on the next one.
+		if page > 1 {
+			items = append(items, fmt.Sprintf(`{"id": "%d", "name": "Лента", "org": {"name": "Лента"}}`, (page-1)*size-1))
+		}
+		body := fmt.Sprintf(`{"result": {"items": [%s], "total": %d}}`, strings.Join(items, ","), total)
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+}
+
+func TestShopInfo2GisService_Pages(t *testing.T) {
+	chain := domain.Chain{Name: "Лента", Query: "Лента", Match: domain.ChainMatch{Names: []string{"Лента"}}}
+	point := domain.Point{Lon: 82.0, Lat: 55.0}
+
+	t.Run(
+		"All the pages are requested",
+		func(t *testing.T) {
+			requests := []string{}
+			service := services.NewShopInfo2GisService(testApiKey, &http.Client{Transport: paged2Gis(120, &requests)})
+			shops, err := service.Get(chain, point, 2000)
+			assert.NoError(t, err)
+			assert.Equal(t, 120, len(shops.Info))
+			assert.Equal(t, []string{"1", "2", "3"}, requests)
+		},
+	)
+
+	t.Run(
+		"One page is requested for few places",
+		func(t *testing.T) {
+			requests := []string{}
+			service := services.NewShopInfo2GisService(testApiKey, &http.Client{Transport: paged2Gis(3, &requests)})
+			shops, err := service.Get(chain, point, 2000)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, len(shops.Info))
+			assert.Equal(t, []string{"1"}, requests)
+		},
+	)
+}
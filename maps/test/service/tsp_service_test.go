@@ -1,7 +1,9 @@
 package service_test
 
 import (
+	"maps_service/internal/domain"
 	"maps_service/internal/services"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,8 +22,8 @@ func TestTSPService_EqualBruteforceAndDynProgramming(t *testing.T) {
 		"Equal answers for tsp bruteforce and tsp dyn programming",
 		func(t *testing.T) {
 			for startPoint := 0; startPoint < 3; startPoint += 1 {
-				bruteforceCost, bruteforcePath, bruteforceError := tspBruteforce.Get(matrix, startPoint)
-				dynProgrammingCost, dynProgrammingPath, dynProgrammingError := tspDynProgramming.Get(matrix, startPoint)
+				bruteforceCost, bruteforcePath, bruteforceError := tspBruteforce.Get(matrix, startPoint, startPoint)
+				dynProgrammingCost, dynProgrammingPath, dynProgrammingError := tspDynProgramming.Get(matrix, startPoint, startPoint)
 
 				assert.NoError(t, bruteforceError)
 				assert.NoError(t, dynProgrammingError)
@@ -31,4 +33,205 @@ func TestTSPService_EqualBruteforceAndDynProgramming(t *testing.T) {
 			}
 		},
 	)
+
+	t.Run(
+		"Equal answers for open paths and fixed end points",
+		func(t *testing.T) {
+			for seed := int64(0); seed < 5; seed += 1 {
+				matrix := generateMatrix(6, seed)
+
+				for startPoint := 0; startPoint < len(matrix); startPoint += 1 {
+					for endPoint := domain.OpenEndPoint; endPoint < len(matrix); endPoint += 1 {
+						bruteforceCost, bruteforcePath, bruteforceError := tspBruteforce.Get(matrix, startPoint, endPoint)
+						dynProgrammingCost, dynProgrammingPath, dynProgrammingError := tspDynProgramming.Get(matrix, startPoint, endPoint)
+
+						assert.NoError(t, bruteforceError)
+						assert.NoError(t, dynProgrammingError)
+
+						assert.Equal(t, bruteforceCost, dynProgrammingCost)
+						assertValidRoute(t, matrix, startPoint, endPoint, bruteforceCost, bruteforcePath)
+						assertValidRoute(t, matrix, startPoint, endPoint, dynProgrammingCost, dynProgrammingPath)
+					}
+				}
+			}
+		},
+	)
+}
+
+func TestTSPService_EndPoint(t *testing.T) {
+	matrix := [][]int{
+		{0, 1, 4},
+		{8, 0, 3},
+		{2, 5, 0},
+	}
+
+	for name, tsp := range map[string]domain.ITSPService{
+		"bruteforce":   services.NewTSPBruteforce(),
+		"dp":           services.NewTSPDynProgramming(),
+		"local-search": services.NewTSPLocalSearch(),
+		"annealing":    services.NewTSPSimulatedAnnealing(),
+	} {
+		t.Run(
+			name,
+			func(t *testing.T) {
+				cost, path, err := tsp.Get(matrix, 0, 0)
+				assert.NoError(t, err)
+				assert.Equal(t, 6, cost)
+				assert.Equal(t, []int{0, 1, 2, 0}, path)
+
+				cost, path, err = tsp.Get(matrix, 0, domain.OpenEndPoint)
+				assert.NoError(t, err)
+				assert.Equal(t, 4, cost)
+				assert.Equal(t, []int{0, 1, 2}, path)
+
+				cost, path, err = tsp.Get(matrix, 0, 1)
+				assert.NoError(t, err)
+				assert.Equal(t, 9, cost)
+				assert.Equal(t, []int{0, 2, 1}, path)
+
+				_, _, err = tsp.Get(matrix, 0, 3)
+				assert.Error(t, err)
+			},
+		)
+	}
+
+	t.Run(
+		"No path to the end point",
+		func(t *testing.T) {
+			matrix := [][]int{
+				{0, 1, 1},
+				{1, 0, -1},
+				{1, -1, 0},
+			}
+
+			_, _, err := services.NewTSPBruteforce().Get(matrix, 0, 1)
+			assert.Error(t, err)
+			_, _, err = services.NewTSPDynProgramming().Get(matrix, 0, 1)
+			assert.Error(t, err)
+		},
+	)
+}
+
+func generateMatrix(n int, seed int64) [][]int {
+	random := rand.New(rand.NewSource(seed))
+	matrix := make([][]int, n)
+	for i := range matrix {
+		matrix[i] = make([]int, n)
+		for j := range matrix[i] {
+			if i != j {
+				matrix[i][j] = 1 + random.Intn(100)
+			}
+		}
+	}
+	return matrix
+}
+
+func assertValidRoute(t *testing.T, matrix [][]int, startPoint, endPoint, cost int, path []int) {
+	if endPoint == startPoint {
+		assert.Equal(t, len(matrix)+1, len(path))
+	} else {
+		assert.Equal(t, len(matrix), len(path))
+	}
+	assert.Equal(t, startPoint, path[0])
+	if endPoint != domain.OpenEndPoint {
+		assert.Equal(t, endPoint, path[len(path)-1])
+	}
+
+	visited := make(map[int]struct{})
+	pathCost := 0
+	for i := range path {
+		visited[path[i]] = struct{}{}
+		if i+1 < len(path) {
+			assert.NotEqual(t, -1, matrix[path[i]][path[i+1]])
+			pathCost += matrix[path[i]][path[i+1]]
+		}
+	}
+	assert.Equal(t, len(matrix), len(visited))
+	assert.Equal(t, pathCost, cost)
+}
+
+func TestTSPService_HeuristicsNotBetterThanExact(t *testing.T) {
+	tspDynProgramming := services.NewTSPDynProgramming()
+	heuristics := map[string]domain.ITSPService{
+		"local-search": services.NewTSPLocalSearch(),
+		"annealing":    services.NewTSPSimulatedAnnealing(),
+	}
+
+	for name, heuristic := range heuristics {
+		t.Run(
+			name,
+			func(t *testing.T) {
+				for seed := int64(0); seed < 10; seed += 1 {
+					matrix := generateMatrix(8, seed)
+
+					for _, endPoint := range []int{0, domain.OpenEndPoint, 5} {
+						exactCost, _, err := tspDynProgramming.Get(matrix, 0, endPoint)
+						assert.NoError(t, err)
+
+						cost, path, err := heuristic.Get(matrix, 0, endPoint)
+						assert.NoError(t, err)
+						assertValidRoute(t, matrix, 0, endPoint, cost, path)
+						assert.GreaterOrEqual(t, cost, exactCost)
+					}
+					assert.False(t, heuristic.IsExact(len(matrix)))
+				}
+			},
+		)
+	}
+}
+
+func TestTSPService_HeuristicsAvoidMissingEdges(t *testing.T) {
+	// The only tour is 0 -> 2 -> 1 -> 3 -> 0.
+	matrix := [][]int{
+		{0, -1, 5, -1},
+		{-1, 0, -1, 5},
+		{-1, 1, 0, -1},
+		{1, -1, -1, 0},
+	}
+
+	for name, tsp := range map[string]domain.ITSPService{
+		"local-search": services.NewTSPLocalSearch(),
+		"annealing":    services.NewTSPSimulatedAnnealing(),
+	} {
+		t.Run(
+			name,
+			func(t *testing.T) {
+				cost, path, err := tsp.Get(matrix, 0, 0)
+				assert.NoError(t, err)
+				assert.Equal(t, 12, cost)
+				assert.Equal(t, []int{0, 2, 1, 3, 0}, path)
+			},
+		)
+	}
+}
+
+func TestTSPService_Auto(t *testing.T) {
+	tsp := services.NewTSPAuto(services.NewTSPDynProgramming(), services.NewTSPLocalSearch())
+
+	t.Run(
+		"Exact for small inputs",
+		func(t *testing.T) {
+			matrix := generateMatrix(6, 1)
+			exactCost, exactPath, err := services.NewTSPDynProgramming().Get(matrix, 2, 2)
+			assert.NoError(t, err)
+
+			cost, path, err := tsp.Get(matrix, 2, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, exactCost, cost)
+			assert.Equal(t, exactPath, path)
+			assert.True(t, tsp.IsExact(len(matrix)))
+		},
+	)
+
+	t.Run(
+		"Heuristic for large inputs",
+		func(t *testing.T) {
+			matrix := generateMatrix(60, 1)
+
+			cost, path, err := tsp.Get(matrix, 0, 0)
+			assert.NoError(t, err)
+			assertValidRoute(t, matrix, 0, 0, cost, path)
+			assert.False(t, tsp.IsExact(len(matrix)))
+		},
+	)
 }
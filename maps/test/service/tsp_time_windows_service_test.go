@@ -0,0 +1,158 @@
+package service_test
+
+import (
+	"maps_service/internal/domain"
+	"maps_service/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func everyDaySchedule(from, to string) *domain.Schedule {
+	day := domain.DaySchedule{WorkingHours: []domain.WorkingHours{{From: from, To: to}}}
+	return &domain.Schedule{
+		Monday:    day,
+		Tuesday:   day,
+		Wednesday: day,
+		Thursday:  day,
+		Friday:    day,
+		Saturday:  day,
+		Sunday:    day,
+	}
+}
+
+func TestGetTimeWindows(t *testing.T) {
+	// Monday, 10:00.
+	departure := time.Date(2024, 1, 15, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	t.Run(
+		"Day schedule",
+		func(t *testing.T) {
+			windows, err := services.GetTimeWindows(everyDaySchedule("09:00", "21:00"), departure)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.TimeWindow{{From: -3600, To: 39600}, {From: 82800, To: 126000}, {From: 169200, To: 212400}}, windows)
+		},
+	)
+
+	t.Run(
+		"Overnight schedule",
+		func(t *testing.T) {
+			windows, err := services.GetTimeWindows(everyDaySchedule("22:00", "02:00"), departure)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.TimeWindow{{From: 43200, To: 57600}, {From: 129600, To: 144000}, {From: 216000, To: 230400}}, windows)
+		},
+	)
+
+	t.Run(
+		"Round the clock schedule",
+		func(t *testing.T) {
+			windows, err := services.GetTimeWindows(everyDaySchedule("00:00", "24:00"), departure)
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.TimeWindow{{From: -36000, To: 223200}}, windows)
+		},
+	)
+
+	t.Run(
+		"No schedule",
+		func(t *testing.T) {
+			windows, err := services.GetTimeWindows(&domain.Schedule{}, departure)
+			assert.NoError(t, err)
+			assert.Nil(t, windows)
+		},
+	)
+
+	t.Run(
+		"Invalid schedule",
+		func(t *testing.T) {
+			_, err := services.GetTimeWindows(everyDaySchedule("9 am", "21:00"), departure)
+			assert.Error(t, err)
+		},
+	)
+}
+
+func TestTSPTimeWindows(t *testing.T) {
+	tsp := services.NewTSPTimeWindows()
+	matrix := [][]int{
+		{0, 600, 600, 600},
+		{600, 0, 600, 600},
+		{600, 600, 0, 600},
+		{600, 600, 600, 0},
+	}
+
+	t.Run(
+		"Closing point is visited first and waiting for opening",
+		func(t *testing.T) {
+			windows := [][]domain.TimeWindow{nil, {{From: 0, To: 1800}}, {{From: 3600, To: 10000}}}
+
+			tour, err := tsp.Get([][]int{matrix[0][:3], matrix[1][:3], matrix[2][:3]}, 0, 0, windows, 300)
+			assert.NoError(t, err)
+			assert.Equal(t, &domain.TimeWindowsTour{
+				Points:     []int{0, 1, 2, 0},
+				Duration:   4500,
+				Arrivals:   []int{0, 600, 1500, 4500},
+				Departures: []int{0, 900, 3900, 4500},
+			}, tour)
+		},
+	)
+
+	t.Run(
+		"Point closed on arrival is infeasible",
+		func(t *testing.T) {
+			windows := [][]domain.TimeWindow{nil, {{From: 0, To: 1800}}, {{From: 3600, To: 10000}}, {{From: -100, To: 100}}}
+
+			tour, err := tsp.Get(matrix, 0, 0, windows, 300)
+			assert.NoError(t, err)
+			assert.Equal(t, []int{0, 1, 2, 0}, tour.Points)
+			assert.Equal(t, []int{3}, tour.Infeasible)
+		},
+	)
+
+	t.Run(
+		"Without windows it is a regular tsp",
+		func(t *testing.T) {
+			distance := [][]int{
+				{0, 1, 4},
+				{8, 0, 3},
+				{2, 5, 0},
+			}
+
+			tour, err := tsp.Get(distance, 0, 0, make([][]domain.TimeWindow, 3), 0)
+			assert.NoError(t, err)
+
+			cost, path, err := services.NewTSPDynProgramming().Get(distance, 0, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, cost, tour.Duration)
+			assert.Equal(t, path, tour.Points)
+		},
+	)
+	t.Run(
+		"Open path and fixed end point",
+		func(t *testing.T) {
+			distance := [][]int{
+				{0, 1, 4},
+				{8, 0, 3},
+				{2, 5, 0},
+			}
+			windows := make([][]domain.TimeWindow, 3)
+
+			tour, err := tsp.Get(distance, 0, domain.OpenEndPoint, windows, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, &domain.TimeWindowsTour{
+				Points:     []int{0, 1, 2},
+				Duration:   4,
+				Arrivals:   []int{0, 1, 4},
+				Departures: []int{0, 1, 4},
+			}, tour)
+
+			tour, err = tsp.Get(distance, 0, 1, windows, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, &domain.TimeWindowsTour{
+				Points:     []int{0, 2, 1},
+				Duration:   9,
+				Arrivals:   []int{0, 4, 9},
+				Departures: []int{0, 4, 9},
+			}, tour)
+		},
+	)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"optimizer/internal/domain"
 	"optimizer/internal/handler"
 	"optimizer/internal/services"
 	"os"
@@ -13,6 +14,12 @@ type Settings struct {
 	Products string
 	Maps     string
 	Port     string
+	// Loyalty programs config, without it the discount cards give the card
+	// prices of the chains.
+	Loyalty string
+	// Pricing of the chains config, without it all the chains price the
+	// weighed products per kg.
+	Pricing string
 }
 
 func GetSettings() (*Settings, error) {
@@ -36,6 +43,9 @@ func GetSettings() (*Settings, error) {
 		return nil, fmt.Errorf("can't get PORT env")
 	}
 
+	settings.Loyalty = os.Getenv("LOYALTY_CONFIG")
+	settings.Pricing = os.Getenv("PRICING_CONFIG")
+
 	return &settings, nil
 }
 
@@ -45,13 +55,32 @@ func main() {
 		panic(err)
 	}
 
-	productService := services.NewProductService(settings.Products)
-	mapsService := services.NewMapsService(settings.Maps)
-	optimizer := services.NewOptimizerService(mapsService, productService)
-	nearbyProducts := services.NewNearbyProductsService(mapsService, productService)
+	productService := services.NewProductService(settings.Products, http.DefaultClient)
+	mapsService := services.NewMapsService(settings.Maps, http.DefaultClient)
+	loyalty, err := services.NewLoyaltyEngine(nil)
+	if settings.Loyalty != "" {
+		loyalty, err = services.LoadLoyaltyEngine(settings.Loyalty)
+	}
+	if err != nil {
+		panic(err)
+	}
+	pricing, err := services.NewPricingRules(services.DefaultPricingConfig)
+	if settings.Pricing != "" {
+		pricing, err = services.LoadPricingRules(settings.Pricing)
+	}
+	if err != nil {
+		panic(err)
+	}
+	optimizer := services.NewSolverSelector(domain.SolverHeuristic, map[string]domain.IOptimizerService{
+		domain.SolverHeuristic: services.NewOptimizerService(mapsService, productService, loyalty, pricing),
+		domain.SolverExact:     services.NewExactOptimizerService(mapsService, productService, loyalty, pricing),
+	})
+	pareto := services.NewParetoOptimizerService(mapsService, productService, loyalty, pricing)
+	nearbyProducts := services.NewNearbyProductsService(mapsService, productService, loyalty, pricing)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /products", handler.CreateProductsHandler(optimizer))
+	mux.HandleFunc("POST /products/pareto", handler.CreateParetoProductsHandler(pareto))
 	mux.HandleFunc("POST /nearby-products", handler.CreateNearbyProductsHandler(nearbyProducts))
 
 	handlerWithCORS := handler.CorsMiddleware(mux)
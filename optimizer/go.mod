@@ -3,7 +3,13 @@ module optimizer
 go 1.22.1
 
 require (
-	github.com/emirpasic/gods v1.18.1 // indirect
-	github.com/oleiade/lane/v2 v2.0.0 // indirect
+	github.com/oleiade/lane/v2 v2.0.0
+	github.com/stretchr/testify v1.10.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/exp v0.0.0-20220827204233-334a2380cb91 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidRequest is wrapped by the errors caused by the request fields.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// RouteError is returned when the maps service can't build the route between
+// the shops, Error gives the reason to show to users.
+type RouteError struct {
+	Transport string
+	Status    string
+	Message   string
+}
+
+func (e *RouteError) Error() string {
+	switch e.Status {
+	case RouteStatusNoPath:
+		return fmt.Sprintf("no %s route between the selected shops", e.Transport)
+	case RouteStatusQuotaExceeded:
+		return "routing quota is exceeded, try again later"
+	case RouteStatusUpstreamError:
+		return fmt.Sprintf("routing provider is unavailable: %s", e.Message)
+	case RouteStatusInvalidInput:
+		return fmt.Sprintf("invalid route request: %s", e.Message)
+	}
+	return fmt.Sprintf("can't build %s route: %s", e.Transport, e.Message)
+}
+
+// HttpStatus returns the code of the optimizer response for the error.
+func (e *RouteError) HttpStatus() int {
+	switch e.Status {
+	case RouteStatusNoPath:
+		return http.StatusUnprocessableEntity
+	case RouteStatusQuotaExceeded:
+		return http.StatusTooManyRequests
+	case RouteStatusUpstreamError:
+		return http.StatusBadGateway
+	case RouteStatusInvalidInput:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// ConstraintError is returned when no plan meets the constraints of the
+// request. Binding are the constraints a plan is found without, or all of them
+// when only dropping several ones helps.
+type ConstraintError struct {
+	Binding []string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("no plan meets the constraints, binding: %s", strings.Join(e.Binding, ", "))
+}
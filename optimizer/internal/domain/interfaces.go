@@ -2,18 +2,24 @@ package domain
 
 type IMapsService interface {
 	GetNearShops(Point, int64) ([]ShopInfo, error)
+	GetReachableShops(Point, string, int) ([]ShopInfo, error)
 	GetRoutesBetweenAddresses([]Point, []Point, string) ([]RoutesInfo, error)
-	GetTSP([]Point, int) (*MinTimeRoute, error)
+	GetTSP([]Point, int, string) (*MinTimeRoute, error)
 }
 
 type IProductsService interface {
 	GetProducts(string, []string) ([]MatchData, error)
+	GetProductsByCategory(string, []MasterDataConstraint) ([]MatchData, error)
 }
 
 type IOptimizerService interface {
-	Get([]InputProductInfo, []string, Point, int64, int64) (*OptimizerResult, error)
+	Get(OptimizerRequest) (*OptimizerResult, error)
+}
+
+type IParetoOptimizerService interface {
+	Get(OptimizerRequest) (*ParetoResult, error)
 }
 
 type INearbyProductsService interface {
-	Get([]InputProductInfo, []string, Point, int64, int64) (*OptimizerResult, error)
+	Get(OptimizerRequest) (*OptimizerResult, error)
 }
@@ -8,6 +8,7 @@ type Point struct {
 type MatchData struct {
 	Title    string        `json:"title"`
 	Category string        `json:"category"`
+	Data     []MasterData  `json:"master_data"`
 	Prices   []MatchPrices `json:"prices"`
 }
 
@@ -15,6 +16,38 @@ type MatchPrices struct {
 	PriceDiscount int64  `json:"price_discount"`
 	PriceRegular  int64  `json:"price_regular"`
 	ShopName      string `json:"shop_name"`
+	// Title of the substitute the price is of, it is empty for the requested
+	// product.
+	Title string `json:"title,omitempty"`
+	// Pack of the product the price is of, it is read from the master data.
+	Pack Pack `json:"-"`
+	// Pricing of the chain, it is read from the pricing config.
+	Pricing ChainPricing `json:"-"`
+}
+
+// Pack of a product as it is sold, the size is 0 when it is unknown.
+type Pack struct {
+	Size    int64
+	Unit    string
+	Weighed bool
+}
+
+// ChainPricing tells how the prices of a chain are given, a price is of one
+// pack unless it is said to be per kg.
+type ChainPricing struct {
+	Chain string `json:"chain"`
+	// Prices of the weighed products are per kg.
+	WeighedPerKg bool `json:"weighed_per_kg"`
+	// Prices of the packed products are per kg when the pack size is known,
+	// e.g. Lenta normalises them by the net weight.
+	PackedPerKg bool `json:"packed_per_kg"`
+}
+
+// PricingConfig gives the pricing of the chains, the default one is of the
+// chains missing in it.
+type PricingConfig struct {
+	Default ChainPricing   `json:"default"`
+	Chains  []ChainPricing `json:"chains"`
 }
 
 type MasterData struct {
@@ -28,19 +61,29 @@ type ShopInfo struct {
 }
 
 type Place struct {
-	Name  string `json:"name"`
-	Point Point  `json:"point"`
-	Id    string `json:"id"`
+	Name   string `json:"name"`
+	Point  Point  `json:"point"`
+	Id     string `json:"id"`
+	Format string `json:"format"`
 }
 
+// Formats of the places as the maps service classifies them.
+const (
+	FormatHypermarket = "hypermarket"
+	FormatSupermarket = "supermarket"
+	FormatExpress     = "express"
+	FormatCosmetics   = "cosmetics"
+)
+
 type RoutesInfo struct {
 	From   int     `json:"from"`
 	Routes []Route `json:"routes"`
 }
 
 type Route struct {
-	To   int `json:"to"`
-	Time int `json:"time"`
+	To       int `json:"to"`
+	Time     int `json:"time"`
+	Distance int `json:"distance"`
 }
 
 type ProductInfo struct {
@@ -51,8 +94,42 @@ type ProductInfo struct {
 }
 
 type InputProductInfo struct {
-	Info   ProductInfo `json:"info"`
-	Amount int64       `json:"amount"`
+	Info ProductInfo `json:"info"`
+	// Amount in tenths of a piece, or of a kilogram for the weighed
+	// products. It is used only when the quantity is not set.
+	Amount   int64     `json:"amount"`
+	Quantity *Quantity `json:"quantity"`
+	// A substitutable product can be replaced by any product of its category
+	// meeting the constraints when it is cheaper or the product is missing.
+	// It must have constraints, the request is invalid otherwise.
+	Substitutable bool                   `json:"substitutable"`
+	Constraints   []MasterDataConstraint `json:"constraints"`
+}
+
+// MasterDataConstraint limits the substitutes by a master data value. The
+// value must be one of the values when they are set, and it must be within
+// the min and the max when they are set. The bounds are in millilitres and
+// grams for the volumes and the weights, so "1л" is 1000.
+type MasterDataConstraint struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+}
+
+// Units of the quantities, a litre is taken as a kilogram when the units of a
+// quantity and of a pack differ.
+const (
+	UnitPieces      = "pcs"
+	UnitGrams       = "g"
+	UnitMillilitres = "ml"
+)
+
+var UNITS = []string{UnitPieces, UnitGrams, UnitMillilitres}
+
+type Quantity struct {
+	Value int64  `json:"value"`
+	Unit  string `json:"unit"`
 }
 
 type ProductInfoWithAmount struct {
@@ -61,24 +138,177 @@ type ProductInfoWithAmount struct {
 	Url	 	string 	`json:"url"`
 	Weighed bool	`json:"isWeighed"`
 	Amount	int64   `json:"amount"`
+	Quantity Quantity `json:"quantity"`
 }
 
 type OutputProductInfo struct {
 	Info  ProductInfoWithAmount	`json:"info"`
 	Price int64					`json:"price"`
+	// Title of the product bought instead of the requested one.
+	Substitute string `json:"substitute,omitempty"`
+	// Packs to buy, 0 when the product is sold by weight.
+	Packs int64 `json:"packs,omitempty"`
+}
+
+type OptimizerRequest struct {
+	Products      []InputProductInfo `json:"products"`
+	DiscountCards []string           `json:"discount_cards"`
+	UserPoint     Point              `json:"point"`
+	Radius        int64              `json:"radius"`
+	// Travel time budget in seconds, when set the shops are selected by the
+	// travel time by the transport from the user point instead of the radius.
+	TravelTime int   `json:"travel_time"`
+	Exchange   int64 `json:"exchange"`
+	// Shop formats to visit, all of them when empty, and to skip. Without
+	// both fields cosmetics shops are skipped, they sell no groceries.
+	Formats        []string `json:"formats"`
+	ExcludeFormats []string `json:"exclude_formats"`
+	// Straight-line pruning of the shops before the travel times are
+	// requested, nothing is pruned when it is not set.
+	Prefilter *PrefilterSettings `json:"prefilter"`
+	// Solver of the store selection, the heuristic one when it is empty.
+	Solver string `json:"solver"`
+	// Time limit of the exact solver in milliseconds, the default one is
+	// used when it is not set.
+	TimeLimit int `json:"time_limit"`
+	// Limits every plan must meet, unlike the exchange they are never traded
+	// for a lower cost.
+	Constraints *PlanConstraints `json:"constraints"`
+	// Transport of the route, walking when it is empty and the one giving the
+	// lowest cost for "any".
+	Transport string `json:"transport"`
+	// Money cost of the trip by transport, added to the cost together with
+	// the exchange weighted time. The default ones are used for the missing
+	// transports.
+	TripCosts map[string]TripCost `json:"trip_costs"`
+	// Personal coupons of the user, applied with the loyalty programs.
+	Coupons []Coupon `json:"coupons"`
+}
+
+// TripCost is in the units of the product prices.
+type TripCost struct {
+	// Fixed cost of the trip, e.g. the taxi boarding fee.
+	Base  int64   `json:"base"`
+	PerKm float64 `json:"per_km"`
+}
+
+// PlanConstraints are the hard limits of a plan, 0 means no limit.
+type PlanConstraints struct {
+	MaxStores int `json:"max_stores"`
+	// Duration of the whole route from the user point and back in seconds.
+	MaxDuration int   `json:"max_duration"`
+	MaxPrice    int64 `json:"max_price"`
+}
+
+type PrefilterSettings struct {
+	// Places of each chain nearest to the user point to keep, 0 keeps all.
+	NearestPerChain int `json:"nearest_per_chain"`
+	// Drop a place when another place of the chain is not farther from the
+	// user point and from every place of the other chains.
+	Dominance bool `json:"dominance"`
 }
 
 type OptimizerResult struct {
 	Stores     []StoreInfo `json:"stores"`
 	TotalPrice int64       `json:"price"`
 	Cost       int64       `json:"cost"`
+	// Number of the candidate places dropped by the prefilter.
+	PrunedShops int    `json:"pruned_shops"`
+	Solver      string `json:"solver"`
+	Transport   string `json:"transport"`
+	// Money cost of the trip included into the cost.
+	TripCost int64 `json:"trip_cost"`
+	// Relative difference between the cost and its lower bound, set by the
+	// exact solver only. It is 0 when the plan is proven optimal and greater
+	// when the time limit is reached or the products of a plan are too many
+	// to try all their assignments to the stores for the loyalty programs.
+	Gap *float64 `json:"gap,omitempty"`
+}
+
+type ParetoPlan struct {
+	Stores     []StoreInfo `json:"stores"`
+	TotalPrice int64       `json:"price"`
+	Duration   int         `json:"duration"`
+	Transport  string      `json:"transport"`
+	// Money cost of the trip, the price includes it.
+	TripCost int64 `json:"trip_cost"`
+	// Range of the exchange values for which the plan has the lowest cost.
+	// Both are nil when no value selects the plan, and the upper one is nil
+	// when the range is not bounded.
+	MinExchange *float64 `json:"min_exchange"`
+	MaxExchange *float64 `json:"max_exchange"`
+}
+
+type ParetoResult struct {
+	// Plans from the cheapest to the fastest, each one is faster than the
+	// cheaper ones.
+	Plans []ParetoPlan `json:"plans"`
+	// False when the time limit is reached before all the plans are compared,
+	// or when the products of a plan are too many to try all their
+	// assignments to the stores for the loyalty programs.
+	Complete    bool `json:"complete"`
+	PrunedShops int  `json:"pruned_shops"`
 }
 
 type StoreInfo struct {
 	Products   []OutputProductInfo `json:"products"`
 	Store      string              `json:"store"`
 	StorePoint Point               `json:"point"`
-	Price      int64               `json:"total_price"`
+	// Price of the products with the savings of the loyalty programs taken.
+	Price   int64           `json:"total_price"`
+	Savings []ProgramSaving `json:"savings"`
+}
+
+type ProgramSaving struct {
+	Program string `json:"program"`
+	Saving  int64  `json:"saving"`
+}
+
+// LoyaltyProgram of a chain applies when the user has its card, that is the
+// program or the chain is in the discount cards, or when it is open to all.
+type LoyaltyProgram struct {
+	Name  string        `json:"name"`
+	Chain string        `json:"chain"`
+	Open  bool          `json:"open"`
+	Rules []LoyaltyRule `json:"rules"`
+}
+
+// Types of the loyalty rules.
+const (
+	// The products are priced by the card prices instead of the regular ones.
+	LoyaltyCardPrice = "card_price"
+	// Percent of the basket is returned.
+	LoyaltyCashback = "cashback"
+	// Amount and percent off the basket.
+	LoyaltyThreshold = "threshold"
+	// Points per ruble of the basket, a point is worth the amount.
+	LoyaltyPoints = "points"
+)
+
+// LoyaltyRule is one rule of a program, the rules of the basket apply when
+// the basket of the store is not cheaper than the min basket. The amounts are
+// in the units of the product prices.
+type LoyaltyRule struct {
+	Type           string  `json:"type"`
+	Percent        float64 `json:"percent"`
+	Amount         int64   `json:"amount"`
+	PointsPerRuble float64 `json:"points_per_ruble"`
+	MinBasket      int64   `json:"min_basket"`
+	// Max saving of the rule, 0 means no limit.
+	MaxSaving int64 `json:"max_saving"`
+}
+
+// Program the savings of the coupons are shown by.
+const CouponsProgram = "coupons"
+
+// Coupon takes the amount and the percent off the products of the category
+// in the chain, or off the whole basket when the category is empty.
+type Coupon struct {
+	Chain     string  `json:"chain"`
+	Category  string  `json:"category"`
+	Percent   float64 `json:"percent"`
+	Amount    int64   `json:"amount"`
+	MinBasket int64   `json:"min_basket"`
 }
 
 type MinTimeRoute struct {
@@ -86,3 +316,34 @@ type MinTimeRoute struct {
 	Duration  int    `json:"duration"`
 	Transport string `json:"transport"`
 }
+
+// Statuses of routing with one transport reported by the maps service.
+const (
+	RouteStatusOk            = "ok"
+	RouteStatusNoPath        = "no_path"
+	RouteStatusQuotaExceeded = "quota_exceeded"
+	RouteStatusUpstreamError = "upstream_error"
+	RouteStatusInvalidInput  = "invalid_input"
+	RouteStatusError         = "error"
+)
+
+type TransportStatus struct {
+	Transport string `json:"transport"`
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+}
+
+// Solvers of the store selection selectable per request.
+const (
+	SolverHeuristic = "heuristic"
+	SolverExact     = "exact"
+)
+
+const (
+	TransportWalking = "walking"
+	TransportDriving = "driving"
+	TransportTaxi    = "taxi"
+	TransportAny     = "any"
+)
+
+var TRANSPORTS = []string{TransportWalking, TransportDriving, TransportTaxi}
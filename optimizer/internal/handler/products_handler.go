@@ -2,17 +2,30 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"optimizer/internal/domain"
 )
 
-type request struct {
-	Products      []domain.InputProductInfo `json:"products"`
-	DiscountCards []string                  `json:"discount_cards"`
-	UserPoint     domain.Point              `json:"point"`
-	Radius        int64                     `json:"radius"`
-	Exchange      int64                     `json:"exchange"`
+// writeOptimizerError shows the routing failure reason, the binding constraints
+// and the request errors as is, other errors are internal ones.
+func writeOptimizerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrInvalidRequest) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var routeErr *domain.RouteError
+	if errors.As(err, &routeErr) {
+		http.Error(w, routeErr.Error(), routeErr.HttpStatus())
+		return
+	}
+	var constraintErr *domain.ConstraintError
+	if errors.As(err, &constraintErr) {
+		http.Error(w, constraintErr.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
 }
 
 func CreateProductsHandler(optimizer domain.IOptimizerService) func(http.ResponseWriter, *http.Request) {
@@ -31,15 +44,48 @@ func CreateProductsHandler(optimizer domain.IOptimizerService) func(http.Respons
 		}
 		defer r.Body.Close()
 
-		var req request
+		var req domain.OptimizerRequest
 		if err := json.Unmarshal(body, &req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		result, err := optimizer.Get(req.Products, req.DiscountCards, req.UserPoint, req.Radius, req.Exchange)
+		result, err := optimizer.Get(req)
 		if err != nil {
+			writeOptimizerError(w, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(result); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func CreateParetoProductsHandler(pareto domain.IParetoOptimizerService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		var req domain.OptimizerRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := pareto.Get(req)
+		if err != nil {
+			writeOptimizerError(w, err)
 			return
 		}
 		if err := json.NewEncoder(w).Encode(result); err != nil {
@@ -64,15 +110,15 @@ func CreateNearbyProductsHandler(nearbyProducts domain.INearbyProductsService) f
 		}
 		defer r.Body.Close()
 
-		var req request
+		var req domain.OptimizerRequest
 		if err := json.Unmarshal(body, &req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		result, err := nearbyProducts.Get(req.Products, req.DiscountCards, req.UserPoint, req.Radius, req.Exchange)
+		result, err := nearbyProducts.Get(req)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeOptimizerError(w, err)
 			return
 		}
 		if err := json.NewEncoder(w).Encode(result); err != nil {
@@ -5,16 +5,29 @@ import (
 	"fmt"
 	"log"
 	"optimizer/internal/domain"
+	"slices"
 )
 
 const USER_POINT_ID string = "USER_POINT_ID_UNIQUE_DATA_FOR_MAPPING"
 
-func getProductInfo(matchPrice domain.MatchPrices, shopName string, discounts map[string]struct{}) productInfo {
-	price := matchPrice.PriceRegular
-	if _, ok := discounts[shopName]; ok {
-		price = matchPrice.PriceDiscount
+// getProductInfo prices the quantity of the product by the card price when a
+// program of the shop gives it, the price is nil when the quantity can't be
+// priced in the shop.
+func getProductInfo(matchPrice domain.MatchPrices, category string, shopName string, loyalty *loyaltyPricing, quantity domain.Quantity) productInfo {
+	result := productInfo{StoreName: &shopName, Substitute: matchPrice.Title, Category: category}
+	regular, packs, ok := linePrice(matchPrice.PriceRegular, matchPrice.Pricing, matchPrice.Pack, quantity)
+	if !ok {
+		return result
 	}
-	return productInfo{Price: &price, StoreName: &shopName}
+	regular *= priceScale
+	result.Price, result.Packs = &regular, packs
+
+	if program, ok := loyalty.cardProgram(shopName); ok {
+		card, _, _ := linePrice(matchPrice.PriceDiscount, matchPrice.Pricing, matchPrice.Pack, quantity)
+		card *= priceScale
+		result.Price, result.Saving, result.Program = &card, regular-card, program
+	}
+	return result
 }
 
 func groupProductsByCategory(products []domain.InputProductInfo) map[string][]string {
@@ -41,6 +54,53 @@ func createDiscountsMap(discounts []string) map[string]struct{} {
 	return result
 }
 
+var defaultExcludeFormats = []string{domain.FormatCosmetics}
+
+// getCandidateShops selects shops by the travel time from the user point when
+// it is set and by the radius otherwise. It returns the number of the places
+// pruned by the prefilter.
+func getCandidateShops(mapsService domain.IMapsService, request domain.OptimizerRequest, transport string) ([]domain.ShopInfo, int, error) {
+	var shops []domain.ShopInfo
+	var err error
+	if request.TravelTime > 0 {
+		shops, err = mapsService.GetReachableShops(request.UserPoint, transport, request.TravelTime)
+	} else {
+		shops, err = mapsService.GetNearShops(request.UserPoint, request.Radius)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	exclude := request.ExcludeFormats
+	if len(request.Formats) == 0 && len(exclude) == 0 {
+		exclude = defaultExcludeFormats
+	}
+	shops = filterShopFormats(shops, request.Formats, exclude)
+
+	prefilter := defaultPrefilter
+	if request.Prefilter != nil {
+		prefilter = *request.Prefilter
+	}
+	shops, pruned := prefilterShops(shops, request.UserPoint, prefilter)
+	log.Printf("%d shops pruned by prefilter", pruned)
+
+	return shops, pruned, nil
+}
+
+func filterShopFormats(shops []domain.ShopInfo, include, exclude []string) []domain.ShopInfo {
+	result := []domain.ShopInfo{}
+	for _, shop := range shops {
+		filtered := domain.ShopInfo{Info: []domain.Place{}, Shop: shop.Shop}
+		for _, place := range shop.Info {
+			if (len(include) == 0 || slices.Contains(include, place.Format)) && !slices.Contains(exclude, place.Format) {
+				filtered.Info = append(filtered.Info, place)
+			}
+		}
+		result = append(result, filtered)
+	}
+	return result
+}
+
 func createIdToShop(shopInfos []domain.ShopInfo) map[string]extendedPlace {
 	result := make(map[string]extendedPlace)
 	for _, shopInfo := range shopInfos {
@@ -56,8 +116,22 @@ func createIdToShop(shopInfos []domain.ShopInfo) map[string]extendedPlace {
 	return result
 }
 
-func createDurMatrix(mapsService domain.IMapsService, shopInfos []domain.ShopInfo, userPoint domain.Point) (map[string]map[string]int, error) {
-	result := make(map[string]map[string]int)
+// setRoute saves the duration and the distance of the route between the
+// places.
+func setRoute(durMatrix, distMatrix map[string]map[string]int, from, to string, route domain.Route) {
+	if _, ok := durMatrix[from]; !ok {
+		durMatrix[from] = make(map[string]int)
+		distMatrix[from] = make(map[string]int)
+	}
+	durMatrix[from][to] = route.Time
+	distMatrix[from][to] = route.Distance
+}
+
+// createDurMatrix returns the durations and the distances of the routes by
+// the transport between the shops and from and to the user point.
+func createDurMatrix(mapsService domain.IMapsService, shopInfos []domain.ShopInfo, userPoint domain.Point, transport string) (map[string]map[string]int, map[string]map[string]int, error) {
+	durMatrix := make(map[string]map[string]int)
+	distMatrix := make(map[string]map[string]int)
 	for i := 0; i < len(shopInfos); i += 1 {
 		for j := 0; j < len(shopInfos); j += 1 {
 			if i == j {
@@ -66,69 +140,58 @@ func createDurMatrix(mapsService domain.IMapsService, shopInfos []domain.ShopInf
 			source := collectPoints(shopInfos[i])
 			targets := collectPoints(shopInfos[j])
 
-			routes, err := mapsService.GetRoutesBetweenAddresses(source, targets, "walking")
+			routes, err := mapsService.GetRoutesBetweenAddresses(source, targets, transport)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
 			for _, route := range routes {
-				if _, ok := result[shopInfos[i].Info[route.From].Id]; !ok {
-					result[shopInfos[i].Info[route.From].Id] = make(map[string]int)
-				}
 				for _, to := range route.Routes {
-					result[shopInfos[i].Info[route.From].Id][shopInfos[j].Info[to.To].Id] = to.Time
+					setRoute(durMatrix, distMatrix, shopInfos[i].Info[route.From].Id, shopInfos[j].Info[to.To].Id, to)
 				}
 			}
 		}
 	}
 
-	getDurMatrixFromUserPoint(result, mapsService, shopInfos, userPoint)
+	if err := getDurMatrixFromUserPoint(durMatrix, distMatrix, mapsService, shopInfos, userPoint, transport); err != nil {
+		return nil, nil, err
+	}
 
-	return result, nil
+	return durMatrix, distMatrix, nil
 }
 
-func getDurMatrixFromUserPoint(result map[string]map[string]int, mapsService domain.IMapsService, shopInfos []domain.ShopInfo, userPoint domain.Point) error {
+func getDurMatrixFromUserPoint(durMatrix, distMatrix map[string]map[string]int, mapsService domain.IMapsService, shopInfos []domain.ShopInfo, userPoint domain.Point, transport string) error {
 	for _, shopInfo := range shopInfos {
 		points := collectPoints(shopInfo)
 
-		routes, err := mapsService.GetRoutesBetweenAddresses([]domain.Point{userPoint}, points, "walking")
+		routes, err := mapsService.GetRoutesBetweenAddresses([]domain.Point{userPoint}, points, transport)
 		if err != nil {
 			return err
 		}
 		for _, route := range routes {
-			if _, ok := result[USER_POINT_ID]; !ok {
-				result[USER_POINT_ID] = make(map[string]int)
-			}
 			for _, to := range route.Routes {
-				result[USER_POINT_ID][shopInfo.Info[to.To].Id] = to.Time
+				setRoute(durMatrix, distMatrix, USER_POINT_ID, shopInfo.Info[to.To].Id, to)
 			}
 		}
 
-		routes, err = mapsService.GetRoutesBetweenAddresses(points, []domain.Point{userPoint}, "walking")
+		routes, err = mapsService.GetRoutesBetweenAddresses(points, []domain.Point{userPoint}, transport)
 		if err != nil {
 			return err
 		}
 		for _, route := range routes {
-			if _, ok := result[shopInfo.Info[route.From].Id]; !ok {
-				result[shopInfo.Info[route.From].Id] = make(map[string]int)
-			}
 			for _, to := range route.Routes {
-				result[shopInfo.Info[route.From].Id][USER_POINT_ID] = to.Time
+				setRoute(durMatrix, distMatrix, shopInfo.Info[route.From].Id, USER_POINT_ID, to)
 			}
 		}
 	}
 	return nil
 }
 
-func getAmounts(products []domain.InputProductInfo) []int64 {
-	result := []int64{}
-	for _, product := range products {
-		result = append(result, product.Amount)
+func collectProducts(products []domain.InputProductInfo, productsService domain.IProductsService, pricing *PricingRules) ([]domain.MatchData, error) {
+	if err := checkSubstitutes(products); err != nil {
+		return nil, err
 	}
-	return result
-}
 
-func collectProducts(products []domain.InputProductInfo, productsService domain.IProductsService) ([]domain.MatchData, error) {
 	result := make([]domain.MatchData, len(products))
 	productsToIndex := make(map[string]int)
 	for i, product := range products {
@@ -145,6 +208,11 @@ func collectProducts(products []domain.InputProductInfo, productsService domain.
 		}
 	}
 
+	setPacks(products, result, pricing)
+	if err := addSubstitutes(products, result, productsService, pricing); err != nil {
+		return nil, fmt.Errorf("can't collect substitutes: %s", err)
+	}
+
 	return result, nil
 }
 
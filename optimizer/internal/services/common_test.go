@@ -0,0 +1,45 @@
+package services
+
+import (
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// userPointFailingMaps fails the routes from the user point.
+type userPointFailingMaps struct {
+	fakeMaps
+	userPoint domain.Point
+}
+
+func (maps *userPointFailingMaps) GetRoutesBetweenAddresses(from, to []domain.Point, transport string) ([]domain.RoutesInfo, error) {
+	if len(from) == 1 && from[0] == maps.userPoint {
+		return nil, &domain.RouteError{Transport: transport, Status: domain.RouteStatusError, Message: "no route"}
+	}
+	return maps.fakeMaps.GetRoutesBetweenAddresses(from, to, transport)
+}
+
+func TestCreateDurMatrix(t *testing.T) {
+	userPoint := domain.Point{Lat: 0.001}
+
+	t.Run(
+		"Routes between the shops and the user point",
+		func(t *testing.T) {
+			durMatrix, distMatrix, err := createDurMatrix(&fakeMaps{}, fakeShops, userPoint, domain.TransportWalking)
+			assert.NoError(t, err)
+			assert.Equal(t, 100, durMatrix["near"]["far"])
+			assert.Equal(t, 200, durMatrix[USER_POINT_ID]["near"])
+			assert.Equal(t, 300, distMatrix["far"][USER_POINT_ID])
+		},
+	)
+
+	t.Run(
+		"Error of the user point routes",
+		func(t *testing.T) {
+			_, _, err := createDurMatrix(&userPointFailingMaps{userPoint: userPoint}, fakeShops, userPoint, domain.TransportWalking)
+			var routeErr *domain.RouteError
+			assert.ErrorAs(t, err, &routeErr)
+		},
+	)
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"fmt"
+	"optimizer/internal/domain"
+)
+
+const (
+	constraintMaxStores   = "max_stores"
+	constraintMaxDuration = "max_duration"
+	constraintMaxPrice    = "max_price"
+)
+
+func getConstraints(request domain.OptimizerRequest) (domain.PlanConstraints, error) {
+	if request.Constraints == nil {
+		return domain.PlanConstraints{}, nil
+	}
+	constraints := *request.Constraints
+	if constraints.MaxStores < 0 || constraints.MaxDuration < 0 || constraints.MaxPrice < 0 {
+		return constraints, fmt.Errorf("%w: negative constraint %+v", domain.ErrInvalidRequest, constraints)
+	}
+	return constraints, nil
+}
+
+// violatesConstraints reports whether a plan with the stores count, the
+// duration and the price breaks any of the limits.
+func violatesConstraints(constraints domain.PlanConstraints, stores int, duration int, price int64) bool {
+	return constraints.MaxStores > 0 && stores > constraints.MaxStores ||
+		constraints.MaxDuration > 0 && duration > constraints.MaxDuration ||
+		constraints.MaxPrice > 0 && price > constraints.MaxPrice
+}
+
+// relaxConstraints returns the set constraints each with itself dropped.
+func relaxConstraints(constraints domain.PlanConstraints) map[string]domain.PlanConstraints {
+	result := make(map[string]domain.PlanConstraints)
+	if constraints.MaxStores > 0 {
+		relaxed := constraints
+		relaxed.MaxStores = 0
+		result[constraintMaxStores] = relaxed
+	}
+	if constraints.MaxDuration > 0 {
+		relaxed := constraints
+		relaxed.MaxDuration = 0
+		result[constraintMaxDuration] = relaxed
+	}
+	if constraints.MaxPrice > 0 {
+		relaxed := constraints
+		relaxed.MaxPrice = 0
+		result[constraintMaxPrice] = relaxed
+	}
+	return result
+}
+
+// findBindingConstraints is called when no plan meets the constraints. The
+// probe returns whether a plan meets the constraints and whether it is known,
+// the probes of the exact solvers are cut by the time limit. It returns nil
+// when there is no plan even without the constraints, or when it is not known
+// that there is one, so the caller reports its usual error.
+func findBindingConstraints(constraints domain.PlanConstraints, feasible func(domain.PlanConstraints) (bool, bool)) error {
+	relaxed := relaxConstraints(constraints)
+	if len(relaxed) == 0 {
+		return nil
+	}
+	anyPlan, anyKnown := feasible(domain.PlanConstraints{})
+	if anyKnown && !anyPlan {
+		return nil
+	}
+
+	binding := []string{}
+	// Constraints not proven to stay infeasible without them.
+	unknown := []string{}
+	all := []string{}
+	for _, name := range []string{constraintMaxStores, constraintMaxDuration, constraintMaxPrice} {
+		constraints, ok := relaxed[name]
+		if !ok {
+			continue
+		}
+		all = append(all, name)
+		if ok, known := feasible(constraints); ok {
+			binding = append(binding, name)
+		} else if !known {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(binding) == 0 && !anyPlan {
+		return nil
+	}
+	if len(binding) == 0 {
+		binding = unknown
+	}
+	if len(binding) == 0 {
+		binding = all
+	}
+	return &domain.ConstraintError{Binding: binding}
+}
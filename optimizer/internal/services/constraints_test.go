@@ -0,0 +1,70 @@
+package services
+
+import (
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// probe answers by the constraints set, the ones missing are not known.
+func probe(results map[domain.PlanConstraints]bool) func(domain.PlanConstraints) (bool, bool) {
+	return func(constraints domain.PlanConstraints) (bool, bool) {
+		ok, known := results[constraints]
+		return ok, known
+	}
+}
+
+func TestFindBindingConstraints(t *testing.T) {
+	constraints := domain.PlanConstraints{MaxStores: 1, MaxDuration: 600}
+	withoutStores := domain.PlanConstraints{MaxDuration: 600}
+	withoutDuration := domain.PlanConstraints{MaxStores: 1}
+	none := domain.PlanConstraints{}
+
+	bindingOf := func(err error) []string {
+		if err == nil {
+			return nil
+		}
+		return err.(*domain.ConstraintError).Binding
+	}
+
+	t.Run(
+		"Constraint a plan is found without",
+		func(t *testing.T) {
+			err := findBindingConstraints(constraints, probe(map[domain.PlanConstraints]bool{none: true, withoutStores: true, withoutDuration: false}))
+			assert.Equal(t, []string{constraintMaxStores}, bindingOf(err))
+		},
+	)
+
+	t.Run(
+		"All constraints when only dropping both helps",
+		func(t *testing.T) {
+			err := findBindingConstraints(constraints, probe(map[domain.PlanConstraints]bool{none: true, withoutStores: false, withoutDuration: false}))
+			assert.Equal(t, []string{constraintMaxStores, constraintMaxDuration}, bindingOf(err))
+		},
+	)
+
+	t.Run(
+		"No plan even without constraints",
+		func(t *testing.T) {
+			err := findBindingConstraints(constraints, probe(map[domain.PlanConstraints]bool{none: false, withoutStores: false, withoutDuration: false}))
+			assert.NoError(t, err)
+		},
+	)
+
+	t.Run(
+		"Timed out probe is not taken for infeasible",
+		func(t *testing.T) {
+			err := findBindingConstraints(constraints, probe(map[domain.PlanConstraints]bool{none: true, withoutDuration: false}))
+			assert.Equal(t, []string{constraintMaxStores}, bindingOf(err))
+		},
+	)
+
+	t.Run(
+		"Nothing is reported when no probe is known",
+		func(t *testing.T) {
+			err := findBindingConstraints(constraints, probe(map[domain.PlanConstraints]bool{}))
+			assert.NoError(t, err)
+		},
+	)
+}
@@ -0,0 +1,580 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"optimizer/internal/domain"
+	"slices"
+	"time"
+)
+
+const (
+	defaultExactTimeLimit = 2 * time.Second
+	maxExactTimeLimit     = 30 * time.Second
+)
+
+const noPrice int64 = math.MaxInt64
+
+// Assignments of the products to the chains of a plan tried at most, when the
+// loyalty rules of the baskets may make another chain than the cheapest one
+// the best for a product.
+const maxPlanAssignments = 1024
+
+// ExactOptimizerService selects the stores and assigns the products to them by
+// a branch and bound over the chains, unlike the heuristic one it finds the
+// plan of the lowest cost when the search ends in the time limit.
+type ExactOptimizerService struct {
+	mapsService     domain.IMapsService
+	productsService domain.IProductsService
+	loyalty         *LoyaltyEngine
+	pricing         *PricingRules
+}
+
+// exactModel is the store selection problem. At most one store of a chain is
+// visited, as the prices of a chain are the same in all its stores, and every
+// product is bought in the cheapest visited chain.
+type exactModel struct {
+	// Node 0 is the user point, the other ones are the stores.
+	places []extendedPlace
+	// Durations in seconds, the per km trip costs and their sum with the
+	// exchange weighted durations between the nodes, -1 when there is no
+	// route.
+	durations [][]float64
+	tripCosts [][]float64
+	travel    [][]float64
+	// Distances between the places by their ids, for the trip cost of the
+	// final route.
+	distMatrix  map[string]map[string]int
+	chains      []exactChain
+	constraints domain.PlanConstraints
+	loyalty     *loyaltyPricing
+	// Max share of the price and max amount the rules of the baskets save.
+	savingShare  float64
+	savingAmount int64
+	// suffixPrices[d][p] is the lowest price of the product in the chains
+	// from d on.
+	suffixPrices [][]int64
+}
+
+type exactChain struct {
+	name   string
+	stores []int
+	// Prices of the product amounts, noPrice when the chain doesn't sell the
+	// product.
+	prices []int64
+	// Prices of one item used in the result.
+	products []productInfo
+}
+
+type exactSolution struct {
+	stores   []int
+	chains   []int
+	products []productInfo
+	cost     int64
+}
+
+type branchAndBound struct {
+	model    *exactModel
+	deadline time.Time
+	stores   []int
+	chains   []int
+	best     *exactSolution
+	// Lowest bound of the branches cut by the time limit.
+	openBound int64
+	timedOut  bool
+}
+
+func NewExactOptimizerService(mapsService domain.IMapsService, productsService domain.IProductsService, loyalty *LoyaltyEngine, pricing *PricingRules) *ExactOptimizerService {
+	return &ExactOptimizerService{mapsService: mapsService, productsService: productsService, loyalty: loyalty, pricing: pricing}
+}
+
+func getExactTimeLimit(request domain.OptimizerRequest) (time.Duration, error) {
+	if request.TimeLimit < 0 {
+		return 0, fmt.Errorf("%w: negative time limit %d", domain.ErrInvalidRequest, request.TimeLimit)
+	}
+	if request.TimeLimit == 0 {
+		return defaultExactTimeLimit, nil
+	}
+	return min(time.Duration(request.TimeLimit)*time.Millisecond, maxExactTimeLimit), nil
+}
+
+// exactRequest is the request checked and with the products collected, which
+// is shared by the transports.
+type exactRequest struct {
+	request     domain.OptimizerRequest
+	matchData   []domain.MatchData
+	quantities  []domain.Quantity
+	constraints domain.PlanConstraints
+	loyalty     *loyaltyPricing
+	transports  []string
+	// Time limit of the search with one transport.
+	timeLimit time.Duration
+}
+
+func prepareExactRequest(productsService domain.IProductsService, loyalty *LoyaltyEngine, pricing *PricingRules, request domain.OptimizerRequest) (*exactRequest, error) {
+	timeLimit, err := getExactTimeLimit(request)
+	if err != nil {
+		return nil, err
+	}
+	constraints, err := getConstraints(request)
+	if err != nil {
+		return nil, err
+	}
+	transports, err := getTransports(request)
+	if err != nil {
+		return nil, err
+	}
+	matchData, err := collectProducts(request.Products, productsService, pricing)
+	if err != nil {
+		return nil, err
+	}
+	quantities, err := getQuantities(request.Products)
+	if err != nil {
+		return nil, err
+	}
+
+	return &exactRequest{
+		request:     request,
+		matchData:   matchData,
+		quantities:  quantities,
+		constraints: constraints,
+		loyalty:     loyalty.forRequest(request),
+		transports:  transports,
+		timeLimit:   timeLimit / time.Duration(len(transports)),
+	}, nil
+}
+
+// prepareExactModel collects the routes by the transport between the
+// candidate shops, it returns the number of the places pruned by the
+// prefilter.
+func prepareExactModel(mapsService domain.IMapsService, exact *exactRequest, transport string) (*exactModel, int, error) {
+	request := exact.request
+	shopInfos, pruned, err := getCandidateShops(mapsService, request, transport)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	durMatrix, distMatrix, err := createDurMatrix(mapsService, shopInfos, request.UserPoint, transport)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	model := createExactModel(shopInfos, durMatrix, distMatrix, exact.matchData, exact.loyalty, exact.quantities, request.Exchange, getTripCost(request, transport))
+	model.constraints = exact.constraints
+	return model, pruned, nil
+}
+
+func (service *ExactOptimizerService) Get(request domain.OptimizerRequest) (*domain.OptimizerResult, error) {
+	exact, err := prepareExactRequest(service.productsService, service.loyalty, service.pricing, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return solveWithTransports(exact.transports, func(transport string) (*domain.OptimizerResult, error) {
+		return service.getByTransport(exact, transport)
+	})
+}
+
+func (service *ExactOptimizerService) getByTransport(exact *exactRequest, transport string) (*domain.OptimizerResult, error) {
+	request, timeLimit := exact.request, exact.timeLimit
+
+	model, pruned, err := prepareExactModel(service.mapsService, exact, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	// The time limit is of the search only, the routes requests are not
+	// counted.
+	solver := newBranchAndBound(model, time.Now().Add(timeLimit))
+	best, gap := solver.solve()
+	if best == nil {
+		if solver.timedOut {
+			return nil, fmt.Errorf("can't get any route in the time limit %s", timeLimit)
+		}
+		if err := model.probeConstraints(timeLimit); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("can't get optimal route")
+	}
+	log.Printf("exact solver: cost=%d, gap=%f, timed out=%t", best.cost, gap, solver.timedOut)
+
+	stores := model.createStores(best)
+	mtr, err := getTSP(service.mapsService, stores, request.UserPoint, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := collectResult(request.Products, stores, mtr, request.Exchange, exact.loyalty)
+	if err != nil {
+		return nil, err
+	}
+	addTripCost(result, mtr, stores, model.distMatrix, getTripCost(request, transport))
+	result.PrunedShops = pruned
+	result.Solver = domain.SolverExact
+	result.Gap = &gap
+	return result, nil
+}
+
+// createExactModel keeps the places with the routes from and to the user
+// point and the chains selling at least one of the products.
+func createExactModel(shopInfos []domain.ShopInfo, durMatrix, distMatrix map[string]map[string]int, matchData []domain.MatchData, loyalty *loyaltyPricing, quantities []domain.Quantity, exchange int64, cost domain.TripCost) *exactModel {
+	model := &exactModel{places: []extendedPlace{{}}, distMatrix: distMatrix, loyalty: loyalty}
+	model.savingShare, model.savingAmount = loyalty.savingBound()
+	ids := []string{USER_POINT_ID}
+	seen := make(map[string]struct{})
+	chainIndexes := make(map[string]int)
+
+	for _, shopInfo := range shopInfos {
+		for _, place := range shopInfo.Info {
+			if _, ok := seen[place.Id]; ok {
+				continue
+			}
+			_, to := durMatrix[USER_POINT_ID][place.Id]
+			_, from := durMatrix[place.Id][USER_POINT_ID]
+			if !to || !from {
+				continue
+			}
+			seen[place.Id] = struct{}{}
+
+			index, ok := chainIndexes[shopInfo.Shop]
+			if !ok {
+				index = len(model.chains)
+				chainIndexes[shopInfo.Shop] = index
+				model.chains = append(model.chains, createExactChain(shopInfo.Shop, matchData, loyalty, quantities))
+			}
+			model.chains[index].stores = append(model.chains[index].stores, len(model.places))
+			model.places = append(model.places, extendedPlace{ShopInfo: place, ShopName: shopInfo.Shop})
+			ids = append(ids, place.Id)
+		}
+	}
+
+	model.durations = make([][]float64, len(ids))
+	model.tripCosts = make([][]float64, len(ids))
+	model.travel = make([][]float64, len(ids))
+	for i, from := range ids {
+		model.durations[i] = make([]float64, len(ids))
+		model.tripCosts[i] = make([]float64, len(ids))
+		model.travel[i] = make([]float64, len(ids))
+		for j, to := range ids {
+			duration, ok := durMatrix[from][to]
+			distance := distMatrix[from][to]
+			if i == j {
+				duration, distance, ok = 0, 0, true
+			}
+			if !ok {
+				model.durations[i][j], model.tripCosts[i][j], model.travel[i][j] = -1, -1, -1
+				continue
+			}
+			model.durations[i][j] = float64(duration)
+			model.tripCosts[i][j] = float64(distance) / 1000. * cost.PerKm
+			model.travel[i][j] = float64(duration)/6.*float64(exchange) + model.tripCosts[i][j]
+		}
+	}
+
+	model.chains = slices.DeleteFunc(model.chains, func(chain exactChain) bool {
+		return !slices.ContainsFunc(chain.prices, func(price int64) bool { return price != noPrice })
+	})
+	// The chains selling more products and the nearer stores go first, so a
+	// good plan is found early and bounds the rest of the search.
+	slices.SortStableFunc(model.chains, func(a, b exactChain) int {
+		return cmp.Compare(countPrices(b.prices), countPrices(a.prices))
+	})
+	for _, chain := range model.chains {
+		slices.SortStableFunc(chain.stores, func(a, b int) int {
+			return cmp.Compare(model.durations[0][a]+model.durations[a][0], model.durations[0][b]+model.durations[b][0])
+		})
+	}
+
+	model.suffixPrices = make([][]int64, len(model.chains)+1)
+	model.suffixPrices[len(model.chains)] = noPrices(len(matchData))
+	for d := len(model.chains) - 1; d >= 0; d -= 1 {
+		model.suffixPrices[d] = make([]int64, len(matchData))
+		for i := range model.suffixPrices[d] {
+			model.suffixPrices[d][i] = min(model.suffixPrices[d+1][i], model.chains[d].prices[i])
+		}
+	}
+
+	return model
+}
+
+func createExactChain(name string, matchData []domain.MatchData, loyalty *loyaltyPricing, quantities []domain.Quantity) exactChain {
+	chain := exactChain{name: name, prices: noPrices(len(matchData)), products: make([]productInfo, len(matchData))}
+	for i, product := range matchData {
+		for _, price := range product.Prices {
+			if price.ShopName != name {
+				continue
+			}
+			info := getProductInfo(price, product.Category, name, loyalty, quantities[i])
+			if info.Price != nil && (chain.products[i].Price == nil || *info.Price < *chain.products[i].Price) {
+				chain.products[i] = info
+				chain.prices[i] = *info.Price
+			}
+		}
+	}
+	return chain
+}
+
+// cheaper returns the lowest prices of the products when the chain is visited
+// too.
+func (chain *exactChain) cheaper(prices []int64) []int64 {
+	result := make([]int64, len(prices))
+	for i, price := range prices {
+		result[i] = min(price, chain.prices[i])
+	}
+	return result
+}
+
+func countPrices(prices []int64) int {
+	count := 0
+	for _, price := range prices {
+		if price != noPrice {
+			count += 1
+		}
+	}
+	return count
+}
+
+func noPrices(count int) []int64 {
+	result := make([]int64, count)
+	for i := range result {
+		result[i] = noPrice
+	}
+	return result
+}
+
+// tour returns the weight of the shortest round trip from the user point
+// through the stores by the Held-Karp dynamic programming, a plan has no more
+// stores than chains, so there are few of them.
+func (model *exactModel) tour(weights [][]float64, stores []int) (float64, bool) {
+	k := len(stores)
+	if k == 0 {
+		return 0, true
+	}
+
+	// paths[mask*k+last] is the shortest path from the user point through the
+	// stores of the mask ending in the last one, -1 when there is no path.
+	paths := make([]float64, (1<<k)*k)
+	for i := range paths {
+		paths[i] = -1
+	}
+	for i, store := range stores {
+		paths[(1<<i)*k+i] = weights[0][store]
+	}
+
+	for mask := 1; mask < 1<<k; mask += 1 {
+		for last := 0; last < k; last += 1 {
+			current := paths[mask*k+last]
+			if current < 0 {
+				continue
+			}
+			for next := 0; next < k; next += 1 {
+				weight := weights[stores[last]][stores[next]]
+				if mask&(1<<next) != 0 || weight < 0 {
+					continue
+				}
+				index := (mask|1<<next)*k + next
+				if paths[index] < 0 || current+weight < paths[index] {
+					paths[index] = current + weight
+				}
+			}
+		}
+	}
+
+	best := -1.
+	for last := 0; last < k; last += 1 {
+		current, back := paths[((1<<k)-1)*k+last], weights[stores[last]][0]
+		if current >= 0 && back >= 0 && (best < 0 || current+back < best) {
+			best = current + back
+		}
+	}
+	return best, best >= 0
+}
+
+// tourDuration bounds the duration of the plans with the stores, it is
+// computed only for the duration limit.
+func (model *exactModel) tourDuration(stores []int) (int, bool) {
+	if model.constraints.MaxDuration == 0 {
+		return 0, true
+	}
+	duration, ok := model.tour(model.durations, stores)
+	return int(duration), ok
+}
+
+// cheapestProducts assigns every product to the cheapest of the chains.
+func (model *exactModel) cheapestProducts(chains []int) []productInfo {
+	products := make([]productInfo, len(model.suffixPrices[0]))
+	for i := range products {
+		cheapest := -1
+		for _, chain := range chains {
+			price := model.chains[chain].prices[i]
+			if price != noPrice && (cheapest < 0 || price < model.chains[cheapest].prices[i]) {
+				cheapest = chain
+			}
+		}
+		products[i] = model.chains[cheapest].products[i]
+	}
+	return products
+}
+
+// planProducts assigns the products to the chains for the lowest price with
+// the savings of the loyalty programs. Without the rules of the baskets every
+// product goes to its cheapest chain, with them all the assignments are tried.
+// It is false when there are too many of them and only the cheapest one is
+// priced.
+func (model *exactModel) planProducts(chains []int) ([]productInfo, int64, bool) {
+	products := model.cheapestProducts(chains)
+	price := model.loyalty.total(products)
+	if model.savingShare == 0 && model.savingAmount == 0 {
+		return products, price, true
+	}
+
+	options := make([][]int, len(products))
+	count := 1
+	for i := range products {
+		for _, chain := range chains {
+			if model.chains[chain].prices[i] != noPrice {
+				options[i] = append(options[i], chain)
+			}
+		}
+		if count *= len(options[i]); count > maxPlanAssignments {
+			return products, price, false
+		}
+	}
+
+	current := make([]productInfo, len(products))
+	var assign func(i int)
+	assign = func(i int) {
+		if i == len(products) {
+			if total := model.loyalty.total(current); total < price {
+				products, price = slices.Clone(current), total
+			}
+			return
+		}
+		for _, chain := range options[i] {
+			current[i] = model.chains[chain].products[i]
+			assign(i + 1)
+		}
+	}
+	assign(0)
+	return products, price, true
+}
+
+// createStores skips the stores of the solution left without products.
+func (model *exactModel) createStores(solution *exactSolution) optimizedStores {
+	result := optimizedStores{Products: solution.products}
+	for i, store := range solution.stores {
+		name := model.chains[solution.chains[i]].name
+		if !slices.ContainsFunc(solution.products, func(product productInfo) bool { return *product.StoreName == name }) {
+			continue
+		}
+		place := model.places[store]
+		result.Stores = append(result.Stores, finalStoreInfo{Id: place.ShopInfo.Id, StorePoint: place.ShopInfo.Point, StoreName: place.ShopName, OriginalStoreName: place.ShopInfo.Name})
+	}
+	return result
+}
+
+// probeConstraints finds the binding constraints when no plan meets them. The
+// probes share one time limit, and a probe cut by it tells nothing.
+func (model *exactModel) probeConstraints(timeLimit time.Duration) error {
+	deadline := time.Now().Add(timeLimit)
+	constraints := model.constraints
+	defer func() { model.constraints = constraints }()
+
+	return findBindingConstraints(constraints, func(relaxed domain.PlanConstraints) (bool, bool) {
+		model.constraints = relaxed
+		solver := newBranchAndBound(model, deadline)
+		best, _ := solver.solve()
+		return best != nil, best != nil || !solver.timedOut
+	})
+}
+
+func newBranchAndBound(model *exactModel, deadline time.Time) *branchAndBound {
+	return &branchAndBound{model: model, deadline: deadline, openBound: math.MaxInt64}
+}
+
+// solve returns the best solution found and its relative gap to the lowest
+// bound of the branches left unexplored at the time limit.
+func (solver *branchAndBound) solve() (*exactSolution, float64) {
+	solver.search(0, noPrices(len(solver.model.suffixPrices[0])))
+
+	if solver.best == nil {
+		return nil, 0
+	}
+	bound := min(solver.best.cost, solver.openBound)
+	if solver.best.cost <= 0 {
+		return solver.best, 0
+	}
+	return solver.best, float64(solver.best.cost-bound) / float64(solver.best.cost)
+}
+
+// lowerBound returns the price of the products if every chain not decided yet
+// was visited for free, less the most the loyalty programs could save. It is
+// false when some product can't be bought at all.
+func (model *exactModel) lowerBound(depth int, prices []int64) (int64, bool) {
+	total := int64(0)
+	for i, price := range prices {
+		price = min(price, model.suffixPrices[depth][i])
+		if price == noPrice {
+			return 0, false
+		}
+		total += price
+	}
+	return total - int64(math.Ceil(float64(total)*model.savingShare)) - model.savingAmount, true
+}
+
+// search decides whether a store of the chain at the depth is visited and
+// which one. The tour of the stores selected so far bounds the tours of the
+// branch, as the durations and the distances are the shortest paths and can't
+// be shortened by visiting more stores. The same bounds cut the branches
+// breaking the constraints.
+func (solver *branchAndBound) search(depth int, prices []int64) {
+	priceBound, ok := solver.model.lowerBound(depth, prices)
+	if !ok {
+		return
+	}
+	travel, ok := solver.model.tour(solver.model.travel, solver.stores)
+	if !ok {
+		return
+	}
+	duration, ok := solver.model.tourDuration(solver.stores)
+	if !ok || violatesConstraints(solver.model.constraints, len(solver.stores), duration, priceBound) {
+		return
+	}
+	bound := priceBound + int64(travel)
+	if solver.best != nil && bound >= solver.best.cost {
+		return
+	}
+	if time.Now().After(solver.deadline) {
+		solver.timedOut = true
+		solver.openBound = min(solver.openBound, bound)
+		return
+	}
+
+	if depth == len(solver.model.chains) {
+		products, price, optimal := solver.model.planProducts(solver.chains)
+		if !optimal {
+			// Another assignment of the products may be cheaper, so the plan
+			// is bounded as a branch left open.
+			solver.openBound = min(solver.openBound, bound)
+		}
+		cost := price + int64(travel)
+		if violatesConstraints(solver.model.constraints, 0, 0, price) || solver.best != nil && cost >= solver.best.cost {
+			return
+		}
+		solver.best = &exactSolution{stores: slices.Clone(solver.stores), chains: slices.Clone(solver.chains), products: products, cost: cost}
+		return
+	}
+
+	chain := solver.model.chains[depth]
+	next := chain.cheaper(prices)
+	for _, store := range chain.stores {
+		solver.stores = append(solver.stores, store)
+		solver.chains = append(solver.chains, depth)
+		solver.search(depth+1, next)
+		solver.stores = solver.stores[:len(solver.stores)-1]
+		solver.chains = solver.chains[:len(solver.chains)-1]
+	}
+	solver.search(depth+1, prices)
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"optimizer/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testChains = []string{"A", "B", "C", "D"}
+
+// randomModel places the stores on a grid, so the Manhattan distances between
+// them are the shortest paths the bounds of the search rely on. A chain may
+// not sell some of the products.
+func randomModel(random *rand.Rand, chains, stores, products int, pricing *loyaltyPricing) *exactModel {
+	model := &exactModel{loyalty: pricing}
+	model.savingShare, model.savingAmount = pricing.savingBound()
+
+	points := [][2]float64{{0, 0}}
+	for c := 0; c < chains; c++ {
+		chain := exactChain{name: testChains[c], prices: noPrices(products), products: make([]productInfo, products)}
+		for s := 0; s < stores; s++ {
+			chain.stores = append(chain.stores, len(points))
+			points = append(points, [2]float64{float64(random.Intn(200)), float64(random.Intn(200))})
+		}
+		for p := 0; p < products; p++ {
+			if random.Intn(4) > 0 {
+				chain.prices[p] = 100 + random.Int63n(1000)
+				chain.products[p] = line(chain.name, chain.prices[p], "")
+			}
+		}
+		model.chains = append(model.chains, chain)
+	}
+
+	model.travel = make([][]float64, len(points))
+	model.durations = make([][]float64, len(points))
+	for i := range points {
+		model.travel[i] = make([]float64, len(points))
+		model.durations[i] = make([]float64, len(points))
+		for j := range points {
+			model.travel[i][j] = math.Abs(points[i][0]-points[j][0]) + math.Abs(points[i][1]-points[j][1])
+			model.durations[i][j] = model.travel[i][j]
+		}
+	}
+
+	model.suffixPrices = make([][]int64, chains+1)
+	model.suffixPrices[chains] = noPrices(products)
+	for d := chains - 1; d >= 0; d-- {
+		model.suffixPrices[d] = model.chains[d].cheaper(model.suffixPrices[d+1])
+	}
+	return model
+}
+
+// sellAll makes every chain of the model sell all the products at the price.
+func sellAll(model *exactModel, price int64) {
+	for c := range model.chains {
+		for p := range model.chains[c].prices {
+			model.chains[c].prices[p] = price
+			model.chains[c].products[p] = line(model.chains[c].name, price, "")
+		}
+	}
+	for d := range model.chains {
+		model.suffixPrices[d] = model.chains[d].prices
+	}
+}
+
+// permutationTour returns the shortest round trip through the stores by
+// trying all their orders.
+func permutationTour(weights [][]float64, stores []int) float64 {
+	best := math.Inf(1)
+	var visit func(last int, left []int, length float64)
+	visit = func(last int, left []int, length float64) {
+		if len(left) == 0 {
+			best = min(best, length+weights[last][0])
+			return
+		}
+		for i, store := range left {
+			rest := append(append([]int{}, left[:i]...), left[i+1:]...)
+			visit(store, rest, length+weights[last][store])
+		}
+	}
+	visit(0, stores, 0)
+	return best
+}
+
+// bruteForce returns the lowest cost of all the plans, -1 when there is no
+// plan within the constraints.
+func bruteForce(model *exactModel) int64 {
+	best := int64(-1)
+	var visit func(depth int, stores, chains []int)
+	visit = func(depth int, stores, chains []int) {
+		if depth < len(model.chains) {
+			for _, store := range model.chains[depth].stores {
+				visit(depth+1, append(stores, store), append(chains, depth))
+			}
+			visit(depth+1, stores, chains)
+			return
+		}
+
+		for p := range model.suffixPrices[0] {
+			sold := false
+			for _, chain := range chains {
+				sold = sold || model.chains[chain].prices[p] != noPrice
+			}
+			if !sold {
+				return
+			}
+		}
+		_, price, optimal := model.planProducts(chains)
+		if !optimal {
+			panic("too many assignments for the brute force")
+		}
+		duration := int(permutationTour(model.durations, stores))
+		if violatesConstraints(model.constraints, len(stores), duration, price) {
+			return
+		}
+		if cost := price + int64(permutationTour(model.travel, stores)); best < 0 || cost < best {
+			best = cost
+		}
+	}
+	visit(0, nil, nil)
+	return best
+}
+
+func TestBranchAndBound(t *testing.T) {
+	noLoyalty := (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{})
+	coupons := (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{Coupons: []domain.Coupon{
+		{Chain: "B", Amount: 300, MinBasket: 1500},
+		{Chain: "C", Percent: 20},
+	}})
+
+	tests := []struct {
+		name        string
+		pricing     *loyaltyPricing
+		constraints domain.PlanConstraints
+	}{
+		{"Without constraints", noLoyalty, domain.PlanConstraints{}},
+		{"Max stores", noLoyalty, domain.PlanConstraints{MaxStores: 2}},
+		{"Max duration", noLoyalty, domain.PlanConstraints{MaxDuration: 500}},
+		{"Max price", noLoyalty, domain.PlanConstraints{MaxPrice: 2500}},
+		{"Coupons of the baskets", coupons, domain.PlanConstraints{}},
+		{"Coupons with constraints", coupons, domain.PlanConstraints{MaxStores: 2, MaxPrice: 2500}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			random := rand.New(rand.NewSource(1))
+			for i := 0; i < 100; i++ {
+				model := randomModel(random, 1+random.Intn(4), 1+random.Intn(3), 1+random.Intn(4), test.pricing)
+				model.constraints = test.constraints
+
+				best, gap := newBranchAndBound(model, time.Now().Add(time.Minute)).solve()
+				expected := bruteForce(model)
+				if expected < 0 {
+					assert.Nil(t, best)
+					continue
+				}
+				if assert.NotNil(t, best) {
+					assert.Equal(t, expected, best.cost)
+					assert.Equal(t, 0., gap)
+				}
+			}
+		})
+	}
+}
+
+func TestBranchAndBoundGap(t *testing.T) {
+	t.Run(
+		"Timed out before any plan", func(t *testing.T) {
+			model := randomModel(rand.New(rand.NewSource(1)), 3, 2, 3, (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{}))
+			solver := newBranchAndBound(model, time.Now().Add(-time.Second))
+			best, gap := solver.solve()
+			assert.Nil(t, best)
+			assert.Equal(t, 0., gap)
+			assert.True(t, solver.timedOut)
+		},
+	)
+
+	t.Run(
+		"Timed out with a plan", func(t *testing.T) {
+			model := randomModel(rand.New(rand.NewSource(1)), 3, 2, 3, (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{}))
+			solver := newBranchAndBound(model, time.Now().Add(-time.Second))
+			solver.best = &exactSolution{cost: 100000}
+			bound, _ := model.lowerBound(0, noPrices(3))
+
+			best, gap := solver.solve()
+			assert.True(t, solver.timedOut)
+			assert.Equal(t, bound, solver.openBound)
+			assert.InDelta(t, float64(100000-bound)/100000., gap, 1e-9)
+			assert.Equal(t, int64(100000), best.cost)
+		},
+	)
+
+	t.Run(
+		"Too many assignments of the products", func(t *testing.T) {
+			pricing := (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{Coupons: []domain.Coupon{{Chain: "B", Amount: 500, MinBasket: 100000}}})
+			model := randomModel(rand.New(rand.NewSource(1)), 2, 1, 11, pricing)
+			sellAll(model, 100)
+
+			best, gap := newBranchAndBound(model, time.Now().Add(time.Minute)).solve()
+			if assert.NotNil(t, best) {
+				assert.Greater(t, gap, 0.)
+				assert.LessOrEqual(t, gap, 1.)
+			}
+		},
+	)
+}
+
+func TestProbeConstraints(t *testing.T) {
+	model := randomModel(rand.New(rand.NewSource(1)), 3, 2, 3, (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{}))
+	sellAll(model, 100)
+	model.constraints = domain.PlanConstraints{MaxPrice: 1}
+
+	t.Run(
+		"Binding constraint", func(t *testing.T) {
+			var constraintError *domain.ConstraintError
+			if assert.ErrorAs(t, model.probeConstraints(time.Minute), &constraintError) {
+				assert.Equal(t, []string{constraintMaxPrice}, constraintError.Binding)
+			}
+			assert.Equal(t, domain.PlanConstraints{MaxPrice: 1}, model.constraints)
+		},
+	)
+
+	t.Run(
+		"Timed out probes are not reported", func(t *testing.T) {
+			assert.NoError(t, model.probeConstraints(-time.Second))
+		},
+	)
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"optimizer/internal/domain"
+	"os"
+)
+
+// loyaltyBasket is the products bought in one store.
+type loyaltyBasket struct {
+	lines []productInfo
+	total int64
+}
+
+// loyaltyRule is a rule of a program taking money off the basket of a store.
+type loyaltyRule interface {
+	saving(basket loyaltyBasket) int64
+	// bound returns the max share of the basket and the max amount the rule
+	// saves, they lower the price bounds of the exact solver.
+	bound() (float64, int64)
+}
+
+// loyaltyRuleTypes creates the rules of the basket by their types, the card
+// prices are applied to the products and have no rule here.
+var loyaltyRuleTypes = map[string]func(domain.LoyaltyRule) loyaltyRule{
+	domain.LoyaltyCashback:  newPercentRule,
+	domain.LoyaltyThreshold: newPercentRule,
+	domain.LoyaltyPoints:    newPointsRule,
+}
+
+type percentRule struct {
+	rule domain.LoyaltyRule
+}
+
+func newPercentRule(rule domain.LoyaltyRule) loyaltyRule {
+	return &percentRule{rule: rule}
+}
+
+func (rule *percentRule) saving(basket loyaltyBasket) int64 {
+	if basket.total < rule.rule.MinBasket {
+		return 0
+	}
+	return limitSaving(rule.rule.MaxSaving, rule.rule.Amount+int64(float64(basket.total)*rule.rule.Percent/100.))
+}
+
+func (rule *percentRule) bound() (float64, int64) {
+	return rule.rule.Percent / 100., rule.rule.Amount
+}
+
+type pointsRule struct {
+	rule domain.LoyaltyRule
+}
+
+func newPointsRule(rule domain.LoyaltyRule) loyaltyRule {
+	return &pointsRule{rule: rule}
+}
+
+func (rule *pointsRule) saving(basket loyaltyBasket) int64 {
+	if basket.total < rule.rule.MinBasket {
+		return 0
+	}
+	points := int64(float64(basket.total) / pricesPerRuble * rule.rule.PointsPerRuble)
+	return limitSaving(rule.rule.MaxSaving, points*rule.rule.Amount)
+}
+
+func (rule *pointsRule) bound() (float64, int64) {
+	return rule.rule.PointsPerRuble * float64(rule.rule.Amount) / pricesPerRuble, 0
+}
+
+type couponRule struct {
+	coupon domain.Coupon
+}
+
+func (rule *couponRule) saving(basket loyaltyBasket) int64 {
+	if basket.total < rule.coupon.MinBasket {
+		return 0
+	}
+	base := basket.total
+	if rule.coupon.Category != "" {
+		base = 0
+		for _, line := range basket.lines {
+			if line.Category == rule.coupon.Category {
+				base += *line.Price
+			}
+		}
+	}
+	if base == 0 {
+		return 0
+	}
+	return min(base, rule.coupon.Amount+int64(float64(base)*rule.coupon.Percent/100.))
+}
+
+func (rule *couponRule) bound() (float64, int64) {
+	return rule.coupon.Percent / 100., rule.coupon.Amount
+}
+
+func limitSaving(maxSaving, saving int64) int64 {
+	if maxSaving > 0 {
+		return min(saving, maxSaving)
+	}
+	return saving
+}
+
+type loyaltyProgram struct {
+	name      string
+	open      bool
+	cardPrice bool
+	rules     []loyaltyRule
+}
+
+// LoyaltyEngine keeps the loyalty programs of the chains. A chain without
+// programs gives the card prices by the card named as the chain, as the
+// discount cards did before the programs.
+type LoyaltyEngine struct {
+	programs map[string][]loyaltyProgram
+}
+
+func NewLoyaltyEngine(programs []domain.LoyaltyProgram) (*LoyaltyEngine, error) {
+	engine := &LoyaltyEngine{programs: make(map[string][]loyaltyProgram)}
+	for _, program := range programs {
+		if program.Name == "" || program.Chain == "" {
+			return nil, fmt.Errorf("loyalty program without name or chain in config: %+v", program)
+		}
+
+		result := loyaltyProgram{name: program.Name, open: program.Open}
+		for _, rule := range program.Rules {
+			if rule.Type == domain.LoyaltyCardPrice {
+				result.cardPrice = true
+				continue
+			}
+			create, ok := loyaltyRuleTypes[rule.Type]
+			if !ok {
+				return nil, fmt.Errorf("unknown loyalty rule type %s of %s", rule.Type, program.Name)
+			}
+			result.rules = append(result.rules, create(rule))
+		}
+		engine.programs[program.Chain] = append(engine.programs[program.Chain], result)
+	}
+	return engine, nil
+}
+
+func LoadLoyaltyEngine(path string) (*LoyaltyEngine, error) {
+	jsonFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't load loyalty config file: %+v", err)
+	}
+	defer jsonFile.Close()
+
+	bytes, err := io.ReadAll(jsonFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read loyalty config file: %+v", err)
+	}
+
+	var programs []domain.LoyaltyProgram
+	if err := json.Unmarshal(bytes, &programs); err != nil {
+		return nil, fmt.Errorf("can't unmarshal loyalty config: %+v", err)
+	}
+
+	return NewLoyaltyEngine(programs)
+}
+
+// loyaltyPricing is the programs of the request, the ones the user has the
+// cards of and the open ones, with the coupons of the user.
+type loyaltyPricing struct {
+	programs map[string][]loyaltyProgram
+	coupons  map[string][]loyaltyRule
+}
+
+func (engine *LoyaltyEngine) forRequest(request domain.OptimizerRequest) *loyaltyPricing {
+	cards := createDiscountsMap(request.DiscountCards)
+	pricing := &loyaltyPricing{programs: make(map[string][]loyaltyProgram), coupons: make(map[string][]loyaltyRule)}
+
+	for chain, programs := range engine.programs {
+		for _, program := range programs {
+			_, byName := cards[program.name]
+			_, byChain := cards[chain]
+			if program.open || byName || byChain {
+				pricing.programs[chain] = append(pricing.programs[chain], program)
+			}
+		}
+	}
+	for chain := range cards {
+		if _, ok := engine.programs[chain]; !ok {
+			pricing.programs[chain] = []loyaltyProgram{{name: chain, cardPrice: true}}
+		}
+	}
+
+	for _, coupon := range request.Coupons {
+		pricing.coupons[coupon.Chain] = append(pricing.coupons[coupon.Chain], &couponRule{coupon: coupon})
+	}
+	return pricing
+}
+
+// cardProgram returns the program giving the card prices in the chain.
+func (pricing *loyaltyPricing) cardProgram(chain string) (string, bool) {
+	for _, program := range pricing.programs[chain] {
+		if program.cardPrice {
+			return program.name, true
+		}
+	}
+	return "", false
+}
+
+// basket returns the price of the products bought in the store of the chain
+// and the savings of the programs, the ones of the card prices included. The
+// rules apply one by one and never save more than the rest of the basket.
+func (pricing *loyaltyPricing) basket(chain string, lines []productInfo) (int64, []domain.ProgramSaving) {
+	basket := loyaltyBasket{lines: lines}
+	cardSavings := make(map[string]int64)
+	for _, line := range lines {
+		basket.total += *line.Price
+		cardSavings[line.Program] += line.Saving
+	}
+
+	price := basket.total
+	savings := []domain.ProgramSaving{}
+	add := func(program string, rules []loyaltyRule, saving int64) {
+		for _, rule := range rules {
+			ruleSaving := min(rule.saving(basket), price)
+			price -= ruleSaving
+			saving += ruleSaving
+		}
+		if saving > 0 {
+			savings = append(savings, domain.ProgramSaving{Program: program, Saving: saving})
+		}
+	}
+	for _, program := range pricing.programs[chain] {
+		add(program.name, program.rules, cardSavings[program.name])
+	}
+	add(domain.CouponsProgram, pricing.coupons[chain], 0)
+
+	return price, savings
+}
+
+// total returns the price of the products bought in several stores.
+func (pricing *loyaltyPricing) total(products []productInfo) int64 {
+	chains := make(map[string][]productInfo)
+	for _, product := range products {
+		if product.Price != nil {
+			chains[*product.StoreName] = append(chains[*product.StoreName], product)
+		}
+	}
+
+	total := int64(0)
+	for chain, lines := range chains {
+		price, _ := pricing.basket(chain, lines)
+		total += price
+	}
+	return total
+}
+
+// savingBound returns the max share of the price of a plan and the max amount
+// the rules of the baskets save together.
+func (pricing *loyaltyPricing) savingBound() (float64, int64) {
+	maxShare, amount := 0., int64(0)
+	addBound := func(share *float64, rules []loyaltyRule) {
+		for _, rule := range rules {
+			ruleShare, ruleAmount := rule.bound()
+			*share += ruleShare
+			amount += ruleAmount
+		}
+	}
+	for chain, programs := range pricing.programs {
+		share := 0.
+		for _, program := range programs {
+			addBound(&share, program.rules)
+		}
+		addBound(&share, pricing.coupons[chain])
+		maxShare = max(maxShare, share)
+	}
+	for chain, coupons := range pricing.coupons {
+		if _, ok := pricing.programs[chain]; !ok {
+			share := 0.
+			addBound(&share, coupons)
+			maxShare = max(maxShare, share)
+		}
+	}
+	return min(maxShare, 1.), amount
+}
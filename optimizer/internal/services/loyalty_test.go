@@ -0,0 +1,186 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func line(chain string, price int64, category string) productInfo {
+	return productInfo{Price: &price, StoreName: &chain, Category: category}
+}
+
+func basketOf(lines ...productInfo) loyaltyBasket {
+	basket := loyaltyBasket{lines: lines}
+	for _, line := range lines {
+		basket.total += *line.Price
+	}
+	return basket
+}
+
+func TestLoyaltyRules(t *testing.T) {
+	cashback := domain.LoyaltyRule{Type: domain.LoyaltyCashback, Percent: 5}
+	threshold := domain.LoyaltyRule{Type: domain.LoyaltyThreshold, Amount: 300, MinBasket: 5000}
+	limited := domain.LoyaltyRule{Type: domain.LoyaltyCashback, Percent: 10, MaxSaving: 500}
+	points := domain.LoyaltyRule{Type: domain.LoyaltyPoints, PointsPerRuble: 1, Amount: 10}
+	milk := domain.Coupon{Chain: "A", Category: "milk", Percent: 50}
+
+	tests := []struct {
+		name   string
+		rule   loyaltyRule
+		basket loyaltyBasket
+		saving int64
+	}{
+		{"Cashback", newPercentRule(cashback), basketOf(line("A", 10000, "")), 500},
+		{"Threshold below the min basket", newPercentRule(threshold), basketOf(line("A", 4999, "")), 0},
+		{"Threshold at the min basket", newPercentRule(threshold), basketOf(line("A", 5000, "")), 300},
+		{"Max saving", newPercentRule(limited), basketOf(line("A", 10000, "")), 500},
+		{"Points of the whole rubles", newPointsRule(points), basketOf(line("A", 123450, "")), 1230},
+		{"Points below the min basket", newPointsRule(domain.LoyaltyRule{Type: domain.LoyaltyPoints, PointsPerRuble: 1, Amount: 10, MinBasket: 20000}), basketOf(line("A", 12345, "")), 0},
+		{"Coupon of the basket", &couponRule{coupon: domain.Coupon{Chain: "A", Amount: 200, Percent: 10}}, basketOf(line("A", 1000, "milk"), line("A", 3000, "bread")), 600},
+		{"Coupon of the category", &couponRule{coupon: milk}, basketOf(line("A", 1000, "milk"), line("A", 3000, "bread")), 500},
+		{"Coupon without the category", &couponRule{coupon: milk}, basketOf(line("A", 3000, "bread")), 0},
+		{"Coupon above the category", &couponRule{coupon: domain.Coupon{Chain: "A", Category: "milk", Amount: 2000}}, basketOf(line("A", 1000, "milk"), line("A", 3000, "bread")), 1000},
+		{"Coupon below the min basket", &couponRule{coupon: domain.Coupon{Chain: "A", Amount: 200, MinBasket: 5000}}, basketOf(line("A", 4000, "")), 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.saving, test.rule.saving(test.basket))
+		})
+	}
+}
+
+func TestLoyaltyBasket(t *testing.T) {
+	engine, err := NewLoyaltyEngine([]domain.LoyaltyProgram{
+		{Name: "A card", Chain: "A", Rules: []domain.LoyaltyRule{
+			{Type: domain.LoyaltyCardPrice},
+			{Type: domain.LoyaltyThreshold, Amount: 800},
+		}},
+		{Name: "A bonus", Chain: "A", Open: true, Rules: []domain.LoyaltyRule{{Type: domain.LoyaltyThreshold, Amount: 800}}},
+	})
+	assert.NoError(t, err)
+
+	t.Run(
+		"Never saves more than the rest of the basket", func(t *testing.T) {
+			pricing := engine.forRequest(domain.OptimizerRequest{
+				DiscountCards: []string{"A card"},
+				Coupons:       []domain.Coupon{{Chain: "A", Amount: 800}},
+			})
+			carded := line("A", 1000, "")
+			carded.Saving, carded.Program = 100, "A card"
+
+			price, savings := pricing.basket("A", []productInfo{carded})
+			assert.Equal(t, int64(0), price)
+			assert.Equal(t, []domain.ProgramSaving{{Program: "A card", Saving: 900}, {Program: "A bonus", Saving: 200}}, savings)
+		},
+	)
+
+	t.Run(
+		"Only the open programs without the cards", func(t *testing.T) {
+			pricing := engine.forRequest(domain.OptimizerRequest{})
+			_, ok := pricing.cardProgram("A")
+			assert.False(t, ok)
+
+			price, _ := pricing.basket("A", []productInfo{line("A", 1000, "")})
+			assert.Equal(t, int64(200), price)
+		},
+	)
+
+	t.Run(
+		"Card named as the chain gives the card prices", func(t *testing.T) {
+			pricing := engine.forRequest(domain.OptimizerRequest{DiscountCards: []string{"B"}})
+			program, ok := pricing.cardProgram("B")
+			assert.True(t, ok)
+			assert.Equal(t, "B", program)
+
+			price, savings := pricing.basket("B", []productInfo{line("B", 1000, "")})
+			assert.Equal(t, int64(1000), price)
+			assert.Empty(t, savings)
+		},
+	)
+
+	t.Run(
+		"Total of the chains", func(t *testing.T) {
+			pricing := engine.forRequest(domain.OptimizerRequest{})
+			total := pricing.total([]productInfo{line("A", 1000, ""), line("B", 500, ""), line("A", 2000, ""), {}})
+			assert.Equal(t, int64(2200+500), total)
+		},
+	)
+}
+
+func TestSavingBound(t *testing.T) {
+	engine, err := NewLoyaltyEngine([]domain.LoyaltyProgram{
+		{Name: "A", Chain: "A", Open: true, Rules: []domain.LoyaltyRule{
+			{Type: domain.LoyaltyCashback, Percent: 5},
+			{Type: domain.LoyaltyThreshold, Amount: 300, Percent: 2, MinBasket: 5000, MaxSaving: 400},
+		}},
+		{Name: "B", Chain: "B", Open: true, Rules: []domain.LoyaltyRule{{Type: domain.LoyaltyPoints, PointsPerRuble: 2, Amount: 50}}},
+	})
+	assert.NoError(t, err)
+	pricing := engine.forRequest(domain.OptimizerRequest{Coupons: []domain.Coupon{
+		{Chain: "B", Category: "milk", Percent: 20, Amount: 100},
+		{Chain: "C", Amount: 500, MinBasket: 3000},
+	}})
+	share, amount := pricing.savingBound()
+
+	chains := []string{"A", "B", "C", "D"}
+	categories := []string{"", "milk", "bread"}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		products := make([]productInfo, 1+random.Intn(8))
+		regular := int64(0)
+		for j := range products {
+			products[j] = line(chains[random.Intn(len(chains))], 1+random.Int63n(5000), categories[random.Intn(len(categories))])
+			regular += *products[j].Price
+		}
+		saving := regular - pricing.total(products)
+		assert.LessOrEqual(t, saving, int64(math.Ceil(float64(regular)*share))+amount)
+	}
+}
+
+func TestPlanProducts(t *testing.T) {
+	chain := func(name string, price int64, count int) exactChain {
+		result := exactChain{name: name}
+		for i := 0; i < count; i++ {
+			result.prices = append(result.prices, price)
+			result.products = append(result.products, line(name, price, ""))
+		}
+		return result
+	}
+	model := func(count int) *exactModel {
+		pricing := (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{Coupons: []domain.Coupon{{Chain: "B", Amount: 500, MinBasket: 2000}}})
+		share, amount := pricing.savingBound()
+		return &exactModel{
+			chains:       []exactChain{chain("A", 1000, count), chain("B", 1100, count)},
+			loyalty:      pricing,
+			savingShare:  share,
+			savingAmount: amount,
+			suffixPrices: [][]int64{make([]int64, count)},
+		}
+	}
+
+	t.Run(
+		"Coupon of the dearer chain", func(t *testing.T) {
+			products, price, optimal := model(2).planProducts([]int{0, 1})
+			assert.True(t, optimal)
+			assert.Equal(t, int64(1700), price)
+			for _, product := range products {
+				assert.Equal(t, "B", *product.StoreName)
+			}
+		},
+	)
+
+	t.Run(
+		"Only the cheapest chains of too many products", func(t *testing.T) {
+			products, price, optimal := model(11).planProducts([]int{0, 1})
+			assert.False(t, optimal)
+			assert.Equal(t, int64(11000), price)
+			for _, product := range products {
+				assert.Equal(t, "A", *product.StoreName)
+			}
+		},
+	)
+}
@@ -12,7 +12,8 @@ import (
 )
 
 type MapsService struct {
-	url *url.URL
+	url    *url.URL
+	client *http.Client
 }
 
 type mapsPayload struct {
@@ -20,6 +21,12 @@ type mapsPayload struct {
 	Radius int64        `json:"radius"`
 }
 
+type reachableShopsPayload struct {
+	Point       domain.Point `json:"point"`
+	Transport   string       `json:"transport"`
+	MaxDuration int          `json:"maxDuration"`
+}
+
 type nearShopsResponse struct {
 	Shops []domain.ShopInfo `json:"shops"`
 }
@@ -42,16 +49,19 @@ type tspPayload struct {
 }
 
 type tspResponse struct {
-	Routes []domain.MinTimeRoute `json:"routes"`
+	Routes   []domain.MinTimeRoute    `json:"routes"`
+	Statuses []domain.TransportStatus `json:"statuses"`
 }
 
-func NewMapsService(host string) *MapsService {
+// NewMapsService takes the client to let the tests serve the maps requests
+// without the running maps service.
+func NewMapsService(host string, client *http.Client) *MapsService {
 	u, err := url.Parse(host)
 	if err != nil {
 		panic(err)
 	}
 
-	return &MapsService{url: u}
+	return &MapsService{url: u, client: client}
 }
 
 func (service *MapsService) GetNearShops(point domain.Point, radius int64) ([]domain.ShopInfo, error) {
@@ -60,7 +70,7 @@ func (service *MapsService) GetNearShops(point domain.Point, radius int64) ([]do
 	payload := mapsPayload{Point: point, Radius: radius}
 	requestBody, _ := json.Marshal(payload)
 
-	resp, err := http.Post(service.url.JoinPath("shops").String(), "application/json", bytes.NewBuffer(requestBody))
+	resp, err := service.client.Post(service.url.JoinPath("shops").String(), "application/json", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, err
 	}
@@ -83,58 +93,42 @@ func (service *MapsService) GetNearShops(point domain.Point, radius int64) ([]do
 	return result.Shops, nil
 }
 
-func (service *MapsService) GetRoutesBetweenAddresses(source, targets []domain.Point, transport string) ([]domain.RoutesInfo, error) {
-	log.Printf("MapsService.GetRoutesBetweenAddresses(%+v, %+v, %s)", source, targets, transport)
+func (service *MapsService) GetReachableShops(point domain.Point, transport string, maxDuration int) ([]domain.ShopInfo, error) {
+	log.Printf("MapsService.GetReachableShops(%+v, %s, %d)", point, transport, maxDuration)
 
-	result := make([]domain.RoutesInfo, len(source))
-
-	for i := 0; i < len(source); i += 10 {
-		for j := 0; j < len(targets); j += 10 {
-			sourceSlice := source[i:min(len(source), i+10)]
-			targetsSlice := targets[j:min(len(targets), j+10)]
-
-			payload := routesRequest{From: sourceSlice, To: targetsSlice, Type: transport}
-			requestBody, _ := json.Marshal(payload)
-
-			resp, err := http.Post(service.url.JoinPath("distance").String(), "application/json", bytes.NewBuffer(requestBody))
-			if err != nil {
-				return nil, err
-			}
-			defer resp.Body.Close()
-
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return nil, fmt.Errorf("error reading response body: %+v", err)
-			}
-
-			if resp.StatusCode != http.StatusOK {
-				return nil, fmt.Errorf("api returned status %d: %s", resp.StatusCode, string(body))
-			}
-
-			var response routesResponse
-			if err := json.Unmarshal(body, &response); err != nil {
-				return nil, fmt.Errorf("error decoding response: %+v", err)
-			}
-
-			for _, routeInfo := range response.Info {
-				result[i+routeInfo.From].From = i + routeInfo.From
-				for _, route := range routeInfo.Routes {
-					result[i+routeInfo.From].Routes = append(result[i+routeInfo.From].Routes, domain.Route{To: j + route.To, Time: route.Time})
-				}
-			}
-		}
+	payload := reachableShopsPayload{Point: point, Transport: transport, MaxDuration: maxDuration}
+	requestBody, _ := json.Marshal(payload)
+
+	resp, err := service.client.Post(service.url.JoinPath("reachable-shops").String(), "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %+v", err)
 	}
 
-	return result, nil
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result nearShopsResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %+v", err)
+	}
+
+	return result.Shops, nil
 }
 
-func (service *MapsService) GetTSP(points []domain.Point, startPoint int) (*domain.MinTimeRoute, error) {
-	log.Printf("MapsService.GetTSP(%+v, %d)", points, startPoint)
+func (service *MapsService) GetRoutesBetweenAddresses(source, targets []domain.Point, transport string) ([]domain.RoutesInfo, error) {
+	log.Printf("MapsService.GetRoutesBetweenAddresses(%+v, %+v, %s)", source, targets, transport)
 
-	payload := tspPayload{Points: points, StartPoint: startPoint, ByDistance: false, Algorithm: "dp"}
+	payload := routesRequest{From: source, To: targets, Type: transport}
 	requestBody, _ := json.Marshal(payload)
 
-	resp, err := http.Post(service.url.JoinPath("optimal-routes").String(), "application/json", bytes.NewBuffer(requestBody))
+	resp, err := service.client.Post(service.url.JoinPath("distance").String(), "application/json", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, err
 	}
@@ -149,15 +143,52 @@ func (service *MapsService) GetTSP(points []domain.Point, startPoint int) (*doma
 		return nil, fmt.Errorf("api returned status %d: %s", resp.StatusCode, string(body))
 	}
 
+	var response routesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %+v", err)
+	}
+
+	return response.Info, nil
+}
+
+// GetTSP returns the route by the transport, the maps service builds the
+// routes by all the transports at once.
+func (service *MapsService) GetTSP(points []domain.Point, startPoint int, transport string) (*domain.MinTimeRoute, error) {
+	log.Printf("MapsService.GetTSP(%+v, %d, %s)", points, startPoint, transport)
+
+	payload := tspPayload{Points: points, StartPoint: startPoint, ByDistance: false, Algorithm: "dp"}
+	requestBody, _ := json.Marshal(payload)
+
+	resp, err := service.client.Post(service.url.JoinPath("optimal-routes").String(), "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %+v", err)
+	}
+
+	// The routes are returned with the transport statuses for the failed
+	// requests too, only a response without them is an unexpected one.
 	var response tspResponse
 	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("api returned status %d: %s", resp.StatusCode, string(body))
+		}
 		return nil, fmt.Errorf("error decoding response: %+v", err)
 	}
 
 	for _, mtr := range response.Routes {
-		if mtr.Transport == "walking" {
+		if mtr.Transport == transport {
 			return &mtr, nil
 		}
 	}
-	return nil, fmt.Errorf("no min time route for walking type")
+	for _, status := range response.Statuses {
+		if status.Transport == transport {
+			return nil, &domain.RouteError{Transport: status.Transport, Status: status.Status, Message: status.Error}
+		}
+	}
+	return nil, &domain.RouteError{Transport: transport, Status: domain.RouteStatusError, Message: fmt.Sprintf("api returned status %d", resp.StatusCode)}
 }
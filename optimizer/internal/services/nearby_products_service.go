@@ -3,6 +3,8 @@ package services
 import (
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 
 	"optimizer/internal/domain"
 
@@ -12,44 +14,100 @@ import (
 type NearbyProductsService struct {
 	mapsService     domain.IMapsService
 	productsService domain.IProductsService
+	loyalty         *LoyaltyEngine
+	pricing         *PricingRules
 }
 
 type storeInfo struct {
-	Prices []*int64
-	Id     string
-	Dur    int64
+	Products []productInfo
+	Id       string
+	Dur      int64
+	Dist     int
+	Stores   int
+	// Chains of the stores on the route, a chain is visited once as its
+	// stores give the same prices.
+	Visited map[string]struct{}
 }
 
-func NewNearbyProductsService(mapsService domain.IMapsService, productsService domain.IProductsService) *NearbyProductsService {
-	return &NearbyProductsService{mapsService: mapsService, productsService: productsService}
+func NewNearbyProductsService(mapsService domain.IMapsService, productsService domain.IProductsService, loyalty *LoyaltyEngine, pricing *PricingRules) *NearbyProductsService {
+	return &NearbyProductsService{mapsService: mapsService, productsService: productsService, loyalty: loyalty, pricing: pricing}
 }
 
-func (service *NearbyProductsService) Get(products []domain.InputProductInfo, discounts []string, userPoint domain.Point, radius int64, exchange int64) (*domain.OptimizerResult, error) {
-	discountsMap := createDiscountsMap(discounts)
-
-	matchData, err := collectProducts(products, service.productsService)
+func (service *NearbyProductsService) Get(request domain.OptimizerRequest) (*domain.OptimizerResult, error) {
+	constraints, err := getConstraints(request)
+	if err != nil {
+		return nil, err
+	}
+	transports, err := getTransports(request)
 	if err != nil {
 		return nil, err
 	}
-	shopInfos, err := service.mapsService.GetNearShops(userPoint, radius)
+	matchData, err := collectProducts(request.Products, service.productsService, service.pricing)
+	if err != nil {
+		return nil, err
+	}
+	quantities, err := getQuantities(request.Products)
+	if err != nil {
+		return nil, err
+	}
+
+	return solveWithTransports(transports, func(transport string) (*domain.OptimizerResult, error) {
+		return service.getByTransport(request, matchData, quantities, constraints, transport)
+	})
+}
+
+func (service *NearbyProductsService) getByTransport(request domain.OptimizerRequest, matchData []domain.MatchData, quantities []domain.Quantity, constraints domain.PlanConstraints, transport string) (*domain.OptimizerResult, error) {
+	userPoint, exchange := request.UserPoint, request.Exchange
+	loyalty := service.loyalty.forRequest(request)
+
+	shopInfos, pruned, err := getCandidateShops(service.mapsService, request, transport)
 	if err != nil {
 		return nil, err
 	}
-	durMatrix, err := createDurMatrix(service.mapsService, shopInfos, userPoint)
+	durMatrix, distMatrix, err := createDurMatrix(service.mapsService, shopInfos, userPoint, transport)
 	if err != nil {
 		return nil, err
 	}
 	idToShop := createIdToShop(shopInfos)
+	if !sellsAllProducts(shopInfos, matchData, quantities, loyalty) {
+		return nil, fmt.Errorf("can't collect all products in nearby shops")
+	}
+
+	result := searchNearbyProducts(durMatrix, distMatrix, idToShop, matchData, quantities, loyalty, constraints)
+	if result == nil {
+		feasible := func(constraints domain.PlanConstraints) (bool, bool) {
+			return searchNearbyProducts(durMatrix, distMatrix, idToShop, matchData, quantities, loyalty, constraints) != nil, true
+		}
+		if err := findBindingConstraints(constraints, feasible); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("can't collect all products in nearby shops")
+	}
 
+	result.Dur += int64(durMatrix[result.Id][USER_POINT_ID])
+	result.Dist += distMatrix[result.Id][USER_POINT_ID]
+
+	nearbyResult := getNearbyProductsResult(loyalty, result, exchange)
+	nearbyResult.Transport = transport
+	nearbyResult.TripCost = tripCost(getTripCost(request, transport), result.Dist)
+	nearbyResult.Cost += nearbyResult.TripCost
+	nearbyResult.PrunedShops = pruned
+	return nearbyResult, nil
+}
+
+// searchNearbyProducts returns the nearest store, by the route through the
+// stores before it, where all the products are collected within the
+// constraints.
+func searchNearbyProducts(durMatrix, distMatrix map[string]map[string]int, idToShop map[string]extendedPlace, matchData []domain.MatchData, quantities []domain.Quantity, loyalty *loyaltyPricing, constraints domain.PlanConstraints) *storeInfo {
 	var result *storeInfo = nil
 
 	pq := lane.NewMinPriorityQueue[storeInfo, string]()
-	pq.Push(storeInfo{Prices: make([]*int64, len(matchData)), Id: USER_POINT_ID, Dur: 0}, "0:"+USER_POINT_ID)
+	pq.Push(storeInfo{Products: make([]productInfo, len(matchData)), Id: USER_POINT_ID, Dur: 0, Visited: map[string]struct{}{}}, "0:"+USER_POINT_ID)
 
 	for !pq.Empty() {
 		top, _, ok := pq.Pop()
 
-		log.Printf("State: %s %d %+v", top.Id, top.Dur, top.Prices)
+		log.Printf("State: %s %d %+v", top.Id, top.Dur, top.Products)
 		if !ok {
 			continue
 		}
@@ -58,21 +116,25 @@ func (service *NearbyProductsService) Get(products []domain.InputProductInfo, di
 			continue
 		}
 		for to, d := range durMatrix[top.Id] {
-			info := storeInfo{Prices: top.Prices, Id: to}
 			var shopName string
 			if val, ok := idToShop[to]; ok {
 				shopName = val.ShopName
 			} else {
 				continue
 			}
+			if _, ok := top.Visited[shopName]; ok {
+				continue
+			}
+			info := storeInfo{Products: slices.Clone(top.Products), Id: to, Dist: top.Dist + distMatrix[top.Id][to], Stores: top.Stores + 1, Visited: maps.Clone(top.Visited)}
+			info.Visited[shopName] = struct{}{}
 			log.Printf("Possible edge from %s to %s (shopName=%s)", top.Id, to, shopName)
 
 			for i, data := range matchData {
 				for _, price := range data.Prices {
 					if price.ShopName == shopName {
-						productInfo := getProductInfo(price, shopName, discountsMap)
-						if productInfo.Price != nil && (info.Prices[i] == nil || *info.Prices[i] > *productInfo.Price) {
-							info.Prices[i] = productInfo.Price
+						productInfo := getProductInfo(price, data.Category, shopName, loyalty, quantities[i])
+						if productInfo.Price != nil && (info.Products[i].Price == nil || *info.Products[i].Price > *productInfo.Price) {
+							info.Products[i] = productInfo
 						}
 						continue
 					}
@@ -80,18 +142,27 @@ func (service *NearbyProductsService) Get(products []domain.InputProductInfo, di
 			}
 
 			count := 0
-			for _, price := range info.Prices {
-				if price != nil {
+			for _, product := range info.Products {
+				if product.Price != nil {
 					count += 1
 				}
 			}
 
-			if count == len(matchData) {
+			dur := top.Dur + int64(d)
+			info.Dur = dur
+			back, ok := durMatrix[to][USER_POINT_ID]
+			if !ok {
+				log.Printf("no route from %s to userPoint", to)
+				continue
+			}
+			if violatesConstraints(constraints, info.Stores, int(dur)+back, 0) {
+				continue
+			}
+
+			if count == len(matchData) && !violatesConstraints(constraints, 0, 0, getNearbyPrice(loyalty, &info)) {
 				result = &info
 				break
 			}
-			dur := top.Dur + int64(d)
-			info.Dur = dur
 			pq.Push(info, fmt.Sprintf("%d:%s", dur, info.Id))
 		}
 		if result != nil {
@@ -99,29 +170,35 @@ func (service *NearbyProductsService) Get(products []domain.InputProductInfo, di
 		}
 	}
 
-	if result == nil {
-		return nil, fmt.Errorf("can't collect all products in nearby shops")
-	}
+	return result
+}
 
-	if _, ok := durMatrix[result.Id]; !ok {
-		return nil, fmt.Errorf("no route from %s", result.Id)
-	}
-	val := durMatrix[result.Id]
-	if _, ok := val[USER_POINT_ID]; !ok {
-		return nil, fmt.Errorf("no route from %s to userPoint", result.Id)
+// sellsAllProducts tells whether every product is priced in some of the
+// chains, otherwise no route collects them with any constraints.
+func sellsAllProducts(shopInfos []domain.ShopInfo, matchData []domain.MatchData, quantities []domain.Quantity, loyalty *loyaltyPricing) bool {
+	for i, data := range matchData {
+		sold := slices.ContainsFunc(data.Prices, func(price domain.MatchPrices) bool {
+			return slices.ContainsFunc(shopInfos, func(shopInfo domain.ShopInfo) bool {
+				return shopInfo.Shop == price.ShopName && len(shopInfo.Info) > 0 &&
+					getProductInfo(price, data.Category, price.ShopName, loyalty, quantities[i]).Price != nil
+			})
+		})
+		if !sold {
+			return false
+		}
 	}
-	result.Dur += int64(val[USER_POINT_ID])
+	return true
+}
 
-	return getNearbyProductsResult(products, result, exchange), nil
+func getNearbyPrice(loyalty *loyaltyPricing, store *storeInfo) int64 {
+	return loyalty.total(store.Products)
 }
 
-func getNearbyProductsResult(products []domain.InputProductInfo, store *storeInfo, exchange int64) *domain.OptimizerResult {
+func getNearbyProductsResult(loyalty *loyaltyPricing, store *storeInfo, exchange int64) *domain.OptimizerResult {
 	var totalPrice int64 = 0
 	var cost int64 = 0
 
-	for i, price := range store.Prices {
-		totalPrice += *price * products[i].Amount
-	}
+	totalPrice = getNearbyPrice(loyalty, store)
 	cost = totalPrice + int64((float64(store.Dur)/6.)*float64(exchange))
 	return &domain.OptimizerResult{TotalPrice: totalPrice, Cost: cost}
 }
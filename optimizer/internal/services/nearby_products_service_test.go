@@ -0,0 +1,111 @@
+package services
+
+import (
+	"math"
+	"optimizer/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMaps returns the shops and the routes between the points, a thousandth
+// of a degree takes 100 seconds and 100 metres.
+type fakeMaps struct {
+	shops []domain.ShopInfo
+}
+
+func (maps *fakeMaps) GetNearShops(domain.Point, int64) ([]domain.ShopInfo, error) {
+	return maps.shops, nil
+}
+
+func (maps *fakeMaps) GetReachableShops(domain.Point, string, int) ([]domain.ShopInfo, error) {
+	return maps.shops, nil
+}
+
+func (maps *fakeMaps) GetRoutesBetweenAddresses(from, to []domain.Point, transport string) ([]domain.RoutesInfo, error) {
+	result := []domain.RoutesInfo{}
+	for i, source := range from {
+		routes := domain.RoutesInfo{From: i}
+		for j, target := range to {
+			duration := int(math.Round((math.Abs(source.Lon-target.Lon) + math.Abs(source.Lat-target.Lat)) * 100000))
+			routes.Routes = append(routes.Routes, domain.Route{To: j, Time: duration, Distance: duration})
+		}
+		result = append(result, routes)
+	}
+	return result, nil
+}
+
+func (maps *fakeMaps) GetTSP(points []domain.Point, startPoint int, transport string) (*domain.MinTimeRoute, error) {
+	route := &domain.MinTimeRoute{Transport: transport}
+	for i := range points {
+		route.Points = append(route.Points, i)
+	}
+	route.Points = append(route.Points, 0)
+	return route, nil
+}
+
+var fakeShops = []domain.ShopInfo{
+	{Shop: "Near", Info: []domain.Place{{Name: "Near", Id: "near", Point: domain.Point{Lon: 0.001}, Format: domain.FormatSupermarket}}},
+	{Shop: "Far", Info: []domain.Place{{Name: "Far", Id: "far", Point: domain.Point{Lon: 0.002}, Format: domain.FormatSupermarket}}},
+}
+
+func productsRequest(names ...string) domain.OptimizerRequest {
+	request := domain.OptimizerRequest{Radius: 1000}
+	for _, name := range names {
+		request.Products = append(request.Products, domain.InputProductInfo{Info: domain.ProductInfo{Type: "food", Name: name}, Amount: 10})
+	}
+	return request
+}
+
+func TestNearbyProducts(t *testing.T) {
+	loyalty, err := NewLoyaltyEngine(nil)
+	assert.NoError(t, err)
+	pricing, err := NewPricingRules(DefaultPricingConfig)
+	assert.NoError(t, err)
+	products := &fakeProducts{prices: map[string]map[string]int64{
+		"milk":  {"Near": 10000, "Far": 8000},
+		"bread": {"Far": 6000},
+	}}
+	service := NewNearbyProductsService(&fakeMaps{shops: fakeShops}, products, loyalty, pricing)
+
+	t.Run(
+		"Nearest store collecting all the products", func(t *testing.T) {
+			result, err := service.Get(productsRequest("milk", "bread"))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(140000), result.TotalPrice)
+		},
+	)
+
+	t.Run(
+		"Product sold nowhere with constraints", func(t *testing.T) {
+			request := productsRequest("milk", "caviar")
+			request.Constraints = &domain.PlanConstraints{MaxStores: 2}
+
+			done := make(chan error)
+			go func() {
+				_, err := service.Get(request)
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, domain.ErrInvalidRequest)
+			case <-time.After(5 * time.Second):
+				t.Fatal("nearby products search hasn't ended")
+			}
+		},
+	)
+
+	t.Run(
+		"Search visits a chain once", func(t *testing.T) {
+			matchData, err := collectProducts(productsRequest("caviar").Products, products, pricing)
+			assert.NoError(t, err)
+			durMatrix, distMatrix, err := createDurMatrix(&fakeMaps{}, fakeShops, domain.Point{}, domain.TransportWalking)
+			assert.NoError(t, err)
+
+			result := searchNearbyProducts(durMatrix, distMatrix, createIdToShop(fakeShops), matchData, []domain.Quantity{{Value: 1, Unit: domain.UnitPieces}}, loyalty.forRequest(domain.OptimizerRequest{}), domain.PlanConstraints{})
+			assert.Nil(t, result)
+		},
+	)
+}
@@ -11,11 +11,14 @@ import (
 type OptimizerService struct {
 	mapsService     domain.IMapsService
 	productsService domain.IProductsService
+	loyalty         *LoyaltyEngine
+	pricing         *PricingRules
 }
 
 type addressInfo struct {
 	Products          []productInfo `json:"products"`
 	Duration          int           `json:"duration"`
+	Distance          int           `json:"distance"`
 	TotalPrice        int64         `json:"totalPrice"`
 	PricesFilled      int           `json:"pricedFilled"`
 	Id                string        `json:"id"`
@@ -28,8 +31,14 @@ type addressInfo struct {
 }
 
 type productInfo struct {
-	Price     *int64  `json:"price"`
-	StoreName *string `json:"store"`
+	Price      *int64  `json:"price"`
+	StoreName  *string `json:"store"`
+	Substitute string  `json:"substitute"`
+	Packs      int64   `json:"packs"`
+	Category   string  `json:"category"`
+	// Saving of the card price and its program.
+	Saving  int64  `json:"saving"`
+	Program string `json:"program"`
 }
 
 type extendedPlace struct {
@@ -43,20 +52,30 @@ type optimizedStores struct {
 }
 
 type finalStoreInfo struct {
+	Id                string
 	StorePoint        domain.Point
 	StoreName         string
 	OriginalStoreName string
 }
 
-func NewOptimizerService(mapsService domain.IMapsService, productsService domain.IProductsService) *OptimizerService {
-	return &OptimizerService{mapsService: mapsService, productsService: productsService}
+func NewOptimizerService(mapsService domain.IMapsService, productsService domain.IProductsService, loyalty *LoyaltyEngine, pricing *PricingRules) *OptimizerService {
+	return &OptimizerService{mapsService: mapsService, productsService: productsService, loyalty: loyalty, pricing: pricing}
 }
 
-func (service *OptimizerService) Get(products []domain.InputProductInfo, discounts []string, userPoint domain.Point, radius int64, exchange int64) (*domain.OptimizerResult, error) {
-	discountsMap := createDiscountsMap(discounts)
-	amounts := getAmounts(products)
-
-	matchData, err := collectProducts(products, service.productsService)
+func (service *OptimizerService) Get(request domain.OptimizerRequest) (*domain.OptimizerResult, error) {
+	constraints, err := getConstraints(request)
+	if err != nil {
+		return nil, err
+	}
+	transports, err := getTransports(request)
+	if err != nil {
+		return nil, err
+	}
+	matchData, err := collectProducts(request.Products, service.productsService, service.pricing)
+	if err != nil {
+		return nil, err
+	}
+	quantities, err := getQuantities(request.Products)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +85,17 @@ func (service *OptimizerService) Get(products []domain.InputProductInfo, discoun
 		log.Println(string(bytes))
 	}
 
-	shopInfos, err := service.mapsService.GetNearShops(userPoint, radius)
+	return solveWithTransports(transports, func(transport string) (*domain.OptimizerResult, error) {
+		return service.getByTransport(request, matchData, quantities, constraints, transport)
+	})
+}
+
+func (service *OptimizerService) getByTransport(request domain.OptimizerRequest, matchData []domain.MatchData, quantities []domain.Quantity, constraints domain.PlanConstraints, transport string) (*domain.OptimizerResult, error) {
+	products, userPoint, exchange := request.Products, request.UserPoint, request.Exchange
+	loyalty := service.loyalty.forRequest(request)
+	cost := getTripCost(request, transport)
+
+	shopInfos, pruned, err := getCandidateShops(service.mapsService, request, transport)
 	if err != nil {
 		return nil, err
 	}
@@ -76,38 +105,50 @@ func (service *OptimizerService) Get(products []domain.InputProductInfo, discoun
 		log.Println(string(bytes))
 	}
 
-	durMatrix, err := createDurMatrix(service.mapsService, shopInfos, userPoint)
+	durMatrix, distMatrix, err := createDurMatrix(service.mapsService, shopInfos, userPoint, transport)
 	if err != nil {
 		return nil, err
 	}
 	idToShop := createIdToShop(shopInfos)
 
-	states := fordBellman(durMatrix, idToShop, matchData, discountsMap, amounts, exchange)
-
-	best := getBestState(states, durMatrix, exchange)
+	solve := func(constraints domain.PlanConstraints) *addressInfo {
+		states := fordBellman(durMatrix, distMatrix, idToShop, matchData, loyalty, quantities, exchange, cost, constraints)
+		return getBestState(states, durMatrix, distMatrix, exchange, cost, constraints)
+	}
+	best := solve(constraints)
 
 	if best == nil {
+		if err := findBindingConstraints(constraints, func(constraints domain.PlanConstraints) (bool, bool) { return solve(constraints) != nil, true }); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("can't get optimal route")
 	}
 
 	stores := optimizeStores(best)
-	mtr, err := service.getTSP(stores, userPoint)
+	mtr, err := getTSP(service.mapsService, stores, userPoint, transport)
 	if err != nil {
 		return nil, err
 	}
 
-	return collectResult(products, stores, mtr, exchange)
+	result, err := collectResult(products, stores, mtr, exchange, loyalty)
+	if err != nil {
+		return nil, err
+	}
+	addTripCost(result, mtr, stores, distMatrix, cost)
+	result.PrunedShops = pruned
+	result.Solver = domain.SolverHeuristic
+	return result, nil
 }
 
-func (service *OptimizerService) getTSP(stores optimizedStores, userPoint domain.Point) (*domain.MinTimeRoute, error) {
+func getTSP(mapsService domain.IMapsService, stores optimizedStores, userPoint domain.Point, transport string) (*domain.MinTimeRoute, error) {
 	points := []domain.Point{userPoint}
 	for _, store := range stores.Stores {
 		points = append(points, store.StorePoint)
 	}
 
-	mtr, err := service.mapsService.GetTSP(points, 0)
+	mtr, err := mapsService.GetTSP(points, 0, transport)
 	if err != nil {
-		return nil, fmt.Errorf("can't get tsp between points: %+v", err)
+		return nil, fmt.Errorf("can't get tsp between points: %w", err)
 	}
 	if len(mtr.Points) != len(stores.Stores)+2 {
 		return nil, fmt.Errorf("unexpected len of tsp points: actual=%d, expected=%d", len(mtr.Points), len(stores.Stores)+2)
@@ -116,7 +157,7 @@ func (service *OptimizerService) getTSP(stores optimizedStores, userPoint domain
 	return mtr, nil
 }
 
-func getBestState(states map[string]*addressInfo, durMatrix map[string]map[string]int, exchange int64) *addressInfo {
+func getBestState(states map[string]*addressInfo, durMatrix, distMatrix map[string]map[string]int, exchange int64, cost domain.TripCost, constraints domain.PlanConstraints) *addressInfo {
 	var info *addressInfo = nil
 	var bestStateByFilled *addressInfo = nil
 	for id, value := range states {
@@ -133,7 +174,11 @@ func getBestState(states map[string]*addressInfo, durMatrix map[string]map[strin
 				continue
 			}
 			value.Duration += val
-			value.CachedPrice = calculatePriceWithExchange(value, exchange)
+			value.Distance += distMatrix[id][USER_POINT_ID]
+			if violatesConstraints(constraints, countStores(value), value.Duration, value.TotalPrice) {
+				continue
+			}
+			value.CachedPrice = calculatePriceWithExchange(value, exchange, cost)
 			if value.CachedPrice != nil && (info == nil || info.CachedPrice == nil || info.CachedPrice != nil && (*value.CachedPrice < *info.CachedPrice || *value.CachedPrice == *info.CachedPrice && value.TotalPrice < info.TotalPrice)) {
 				info = value
 			}
@@ -158,7 +203,34 @@ func collectPoints(shopInfo domain.ShopInfo) []domain.Point {
 	return result
 }
 
-func fordBellman(durMatrix map[string]map[string]int, idToShop map[string]extendedPlace, matchData []domain.MatchData, discountsMap map[string]struct{}, amounts []int64, exchange int64) map[string]*addressInfo {
+// countStores returns the number of the stores the products of the state are
+// bought in.
+func countStores(info *addressInfo) int {
+	stores := make(map[string]struct{})
+	for _, product := range info.Products {
+		if product.StoreName != nil {
+			stores[*product.StoreName] = struct{}{}
+		}
+	}
+	return len(stores)
+}
+
+// exceedsConstraints reports whether the edge leads to the states breaking the
+// limits of the stores count and the duration, the return to the user point
+// included, as the following edges can only add to them.
+func exceedsConstraints(cur *addressInfo, to string, dur int, durMatrix map[string]map[string]int, constraints domain.PlanConstraints) bool {
+	stores, duration := 0, 0
+	if cur != nil {
+		stores, duration = len(cur.Visited), cur.Duration
+	}
+	if constraints.MaxStores > 0 && stores >= constraints.MaxStores {
+		return true
+	}
+	back, ok := durMatrix[to][USER_POINT_ID]
+	return constraints.MaxDuration > 0 && (!ok || duration+dur+back > constraints.MaxDuration)
+}
+
+func fordBellman(durMatrix, distMatrix map[string]map[string]int, idToShop map[string]extendedPlace, matchData []domain.MatchData, loyalty *loyaltyPricing, quantities []domain.Quantity, exchange int64, cost domain.TripCost, constraints domain.PlanConstraints) map[string]*addressInfo {
 	states := make(map[string]*addressInfo)
 	states[USER_POINT_ID] = nil
 
@@ -186,8 +258,11 @@ func fordBellman(durMatrix map[string]map[string]int, idToShop map[string]extend
 						continue
 					}
 				}
+				if exceedsConstraints(cur, to, dur, durMatrix, constraints) {
+					continue
+				}
 
-				addressInfo := buildNewAddressInfo(cur, dur, matchData, discountsMap, val.ShopInfo, val.ShopName, amounts, exchange)
+				addressInfo := buildNewAddressInfo(cur, dur, distMatrix[from][to], matchData, loyalty, val.ShopInfo, val.ShopName, quantities, exchange, cost)
 				old, ok := states[to]
 
 				if !ok {
@@ -229,35 +304,35 @@ func fordBellman(durMatrix map[string]map[string]int, idToShop map[string]extend
 	return states
 }
 
-func buildNewAddressInfo(info *addressInfo, dur int, matchData []domain.MatchData, discounts map[string]struct{}, shop domain.Place, shopName string, amounts []int64, exchange int64) addressInfo {
+func buildNewAddressInfo(info *addressInfo, dur int, dist int, matchData []domain.MatchData, loyalty *loyaltyPricing, shop domain.Place, shopName string, quantities []domain.Quantity, exchange int64, cost domain.TripCost) addressInfo {
 	var products []productInfo
-	var duration int
+	var duration, distance int
 	if info == nil {
 		duration = 0
 		products = make([]productInfo, len(matchData))
 	} else {
-		duration = info.Duration
+		duration, distance = info.Duration, info.Distance
 		products = info.Products
 	}
-	result := addressInfo{Duration: duration + dur, Id: shop.Id, StorePoint: shop.Point, OriginalStoreName: shop.Name, StoreName: shopName, Visited: make(map[string]struct{}), Previous: info, PricesFilled: 0, Products: []productInfo{}, TotalPrice: 0}
+	result := addressInfo{Duration: duration + dur, Distance: distance + dist, Id: shop.Id, StorePoint: shop.Point, OriginalStoreName: shop.Name, StoreName: shopName, Visited: make(map[string]struct{}), Previous: info, PricesFilled: 0, Products: []productInfo{}, TotalPrice: 0}
 	for i, product := range matchData {
 		productInfo := products[i]
 		for _, price := range product.Prices {
 			if price.ShopName != shopName {
 				continue
 			}
-			newInfo := getProductInfo(price, shopName, discounts)
+			newInfo := getProductInfo(price, product.Category, shopName, loyalty, quantities[i])
 			if newInfo.Price != nil && (productInfo.Price == nil || *productInfo.Price > *newInfo.Price) {
 				productInfo = newInfo
 			}
 		}
 		if productInfo.Price != nil {
-			result.TotalPrice += *productInfo.Price * amounts[i]
 			result.PricesFilled += 1
 		}
 		result.Products = append(result.Products, productInfo)
 	}
-	result.CachedPrice = calculatePriceWithExchange(&result, exchange)
+	result.TotalPrice = loyalty.total(result.Products)
+	result.CachedPrice = calculatePriceWithExchange(&result, exchange, cost)
 	if info != nil {
 		for key := range info.Visited {
 			result.Visited[key] = struct{}{}
@@ -267,11 +342,13 @@ func buildNewAddressInfo(info *addressInfo, dur int, matchData []domain.MatchDat
 	return result
 }
 
-func calculatePriceWithExchange(info *addressInfo, exchange int64) *int64 {
+// calculatePriceWithExchange leaves out the base trip cost, it is the same for
+// all the states.
+func calculatePriceWithExchange(info *addressInfo, exchange int64, cost domain.TripCost) *int64 {
 	if info.PricesFilled != len(info.Products) {
 		return nil
 	}
-	summary := info.TotalPrice + int64((float64(info.Duration)/6.)*float64(exchange))
+	summary := info.TotalPrice + int64((float64(info.Duration)/6.)*float64(exchange)) + distanceCost(cost, info.Distance)
 	return &summary
 }
 
@@ -287,7 +364,7 @@ func optimizeStores(info *addressInfo) optimizedStores {
 	for cur != nil {
 		_, ok := finalVisitedStores[cur.StoreName]
 		if ok {
-			result.Stores = append(result.Stores, finalStoreInfo{StorePoint: cur.StorePoint, StoreName: cur.StoreName, OriginalStoreName: cur.OriginalStoreName})
+			result.Stores = append(result.Stores, finalStoreInfo{Id: cur.Id, StorePoint: cur.StorePoint, StoreName: cur.StoreName, OriginalStoreName: cur.OriginalStoreName})
 		}
 		cur = cur.Previous
 	}
@@ -296,7 +373,7 @@ func optimizeStores(info *addressInfo) optimizedStores {
 	return result
 }
 
-func collectResult(products []domain.InputProductInfo, stores optimizedStores, mtr *domain.MinTimeRoute, exchange int64) (*domain.OptimizerResult, error) {
+func collectResult(products []domain.InputProductInfo, stores optimizedStores, mtr *domain.MinTimeRoute, exchange int64, loyalty *loyaltyPricing) (*domain.OptimizerResult, error) {
 	result := domain.OptimizerResult{}
 	points := mtr.Points[1 : len(mtr.Points)-1]
 	if len(points) != len(stores.Stores) {
@@ -306,14 +383,15 @@ func collectResult(products []domain.InputProductInfo, stores optimizedStores, m
 	for _, storeId := range points {
 		store := stores.Stores[storeId-1]
 		info := domain.StoreInfo{Products: []domain.OutputProductInfo{}, Store: store.OriginalStoreName, StorePoint: store.StorePoint, Price: 0}
+		lines := []productInfo{}
 		for i, data := range stores.Products {
 			if data.StoreName != nil && *data.StoreName == store.StoreName {
-				price := *data.Price * products[i].Amount
-				info.Price += price
-				result.TotalPrice += price
-				info.Products = append(info.Products, domain.OutputProductInfo{Info: createProductInfoWithAmount(&products[i]), Price: price})
+				lines = append(lines, data)
+				info.Products = append(info.Products, domain.OutputProductInfo{Info: createProductInfoWithAmount(&products[i]), Price: *data.Price, Substitute: data.Substitute, Packs: data.Packs})
 			}
 		}
+		info.Price, info.Savings = loyalty.basket(store.StoreName, lines)
+		result.TotalPrice += info.Price
 		result.Stores = append(result.Stores, info)
 	}
 
@@ -322,5 +400,5 @@ func collectResult(products []domain.InputProductInfo, stores optimizedStores, m
 }
 
 func createProductInfoWithAmount(product *domain.InputProductInfo) domain.ProductInfoWithAmount {
-	return domain.ProductInfoWithAmount{Type: product.Info.Type, Name: product.Info.Name, Url: product.Info.Url, Weighed: product.Info.Weighed, Amount: product.Amount}
+	return domain.ProductInfoWithAmount{Type: product.Info.Type, Name: product.Info.Name, Url: product.Info.Url, Weighed: product.Info.Weighed, Amount: product.Amount, Quantity: getQuantity(*product)}
 }
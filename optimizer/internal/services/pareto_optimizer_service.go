@@ -0,0 +1,241 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"optimizer/internal/domain"
+	"slices"
+	"time"
+)
+
+// Plans returned at most, every plan needs a route request.
+const maxParetoPlans = 10
+
+// ParetoOptimizerService returns the plans not worse than each other in both
+// price and duration instead of the one plan of the lowest cost.
+type ParetoOptimizerService struct {
+	mapsService     domain.IMapsService
+	productsService domain.IProductsService
+	loyalty         *LoyaltyEngine
+	pricing         *PricingRules
+}
+
+type paretoPlan struct {
+	solution exactSolution
+	price    int64
+	duration int
+}
+
+// paretoSearch enumerates the same decisions as branchAndBound, but cuts a
+// branch only when its bounds are dominated by a plan found before.
+type paretoSearch struct {
+	model    *exactModel
+	deadline time.Time
+	stores   []int
+	chains   []int
+	front    []paretoPlan
+	timedOut bool
+	// A plan is priced by the cheapest assignment of its products only.
+	approximate bool
+}
+
+func NewParetoOptimizerService(mapsService domain.IMapsService, productsService domain.IProductsService, loyalty *LoyaltyEngine, pricing *PricingRules) *ParetoOptimizerService {
+	return &ParetoOptimizerService{mapsService: mapsService, productsService: productsService, loyalty: loyalty, pricing: pricing}
+}
+
+func (service *ParetoOptimizerService) Get(request domain.OptimizerRequest) (*domain.ParetoResult, error) {
+	exact, err := prepareExactRequest(service.productsService, service.loyalty, service.pricing, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.ParetoResult{Complete: true}
+	routed := []domain.ParetoPlan{}
+	var firstErr error
+	for _, transport := range exact.transports {
+		plans, complete, pruned, err := service.getByTransport(exact, transport)
+		if err != nil {
+			log.Printf("can't get pareto front with %s: %+v", transport, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		routed = append(routed, plans...)
+		result.Complete = result.Complete && complete
+		result.PrunedShops = max(result.PrunedShops, pruned)
+	}
+	if len(routed) == 0 {
+		return nil, firstErr
+	}
+
+	// The routes of the plans may differ a bit from the matrix durations, and
+	// the plans of the transports are merged, so the front is filtered again.
+	slices.SortStableFunc(routed, func(a, b domain.ParetoPlan) int {
+		return cmp.Compare(a.TotalPrice, b.TotalPrice)
+	})
+	result.Plans = []domain.ParetoPlan{}
+	for i, plan := range routed {
+		dominated := slices.ContainsFunc(routed[:i], func(other domain.ParetoPlan) bool {
+			return other.TotalPrice <= plan.TotalPrice && other.Duration <= plan.Duration
+		}) || slices.ContainsFunc(routed[i+1:], func(other domain.ParetoPlan) bool {
+			return other.TotalPrice <= plan.TotalPrice && other.Duration <= plan.Duration &&
+				(other.TotalPrice < plan.TotalPrice || other.Duration < plan.Duration)
+		})
+		if !dominated {
+			result.Plans = append(result.Plans, plan)
+		}
+	}
+	setExchangeRanges(result.Plans)
+
+	return result, nil
+}
+
+// getByTransport returns the routed plans of the front by the transport and
+// whether the search has ended in the time limit.
+func (service *ParetoOptimizerService) getByTransport(exact *exactRequest, transport string) ([]domain.ParetoPlan, bool, int, error) {
+	request, timeLimit := exact.request, exact.timeLimit
+
+	model, pruned, err := prepareExactModel(service.mapsService, exact, transport)
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	// The time limit is of the search only, as in the exact solver.
+	search := &paretoSearch{model: model, deadline: time.Now().Add(timeLimit)}
+	search.search(0, noPrices(len(model.suffixPrices[0])))
+	if len(search.front) == 0 {
+		if search.timedOut {
+			return nil, false, 0, fmt.Errorf("can't get any route in the time limit %s", timeLimit)
+		}
+		if err := model.probeConstraints(timeLimit); err != nil {
+			return nil, false, 0, err
+		}
+		return nil, false, 0, fmt.Errorf("can't get optimal route")
+	}
+	log.Printf("pareto front with %s: %d plans, timed out=%t", transport, len(search.front), search.timedOut)
+
+	slices.SortFunc(search.front, func(a, b paretoPlan) int {
+		return cmp.Compare(a.price, b.price)
+	})
+
+	cost := getTripCost(request, transport)
+	plans := []domain.ParetoPlan{}
+	for _, plan := range thinParetoFront(search.front, maxParetoPlans) {
+		stores := model.createStores(&plan.solution)
+		mtr, err := getTSP(service.mapsService, stores, request.UserPoint, transport)
+		if err != nil {
+			return nil, false, 0, err
+		}
+		result, err := collectResult(request.Products, stores, mtr, request.Exchange, exact.loyalty)
+		if err != nil {
+			return nil, false, 0, err
+		}
+		addTripCost(result, mtr, stores, model.distMatrix, cost)
+		plans = append(plans, domain.ParetoPlan{
+			Stores:     result.Stores,
+			TotalPrice: result.TotalPrice + result.TripCost,
+			Duration:   mtr.Duration,
+			Transport:  transport,
+			TripCost:   result.TripCost,
+		})
+	}
+	return plans, !search.timedOut && !search.approximate, pruned, nil
+}
+
+// thinParetoFront keeps the cheapest and the fastest plans and the ones evenly
+// spaced between them.
+func thinParetoFront(front []paretoPlan, maxPlans int) []paretoPlan {
+	if len(front) <= maxPlans {
+		return front
+	}
+	result := []paretoPlan{}
+	for i := 0; i < maxPlans; i += 1 {
+		result = append(result, front[i*(len(front)-1)/(maxPlans-1)])
+	}
+	return result
+}
+
+// setExchangeRanges walks the lower envelope of the plan costs as functions of
+// the exchange. The cheapest plan is chosen from zero, and every next plan is
+// the faster one whose cost line crosses the current one first. The plans
+// sorted by price are sorted by duration descending.
+func setExchangeRanges(plans []domain.ParetoPlan) {
+	current, from := 0, 0.
+	for {
+		next, at := -1, 0.
+		for i := current + 1; i < len(plans); i += 1 {
+			exchange := float64(plans[i].TotalPrice-plans[current].TotalPrice) * 6. / float64(plans[current].Duration-plans[i].Duration)
+			if next < 0 || exchange <= at {
+				next, at = i, exchange
+			}
+		}
+
+		minExchange := from
+		plans[current].MinExchange = &minExchange
+		if next < 0 {
+			return
+		}
+		plans[current].MaxExchange = &at
+		current, from = next, at
+	}
+}
+
+func (search *paretoSearch) dominated(price int64, duration int) bool {
+	return slices.ContainsFunc(search.front, func(plan paretoPlan) bool {
+		return plan.price <= price && plan.duration <= duration
+	})
+}
+
+func (search *paretoSearch) add(plan paretoPlan) {
+	search.front = slices.DeleteFunc(search.front, func(other paretoPlan) bool {
+		return plan.price <= other.price && plan.duration <= other.duration
+	})
+	search.front = append(search.front, plan)
+}
+
+func (search *paretoSearch) search(depth int, prices []int64) {
+	priceBound, ok := search.model.lowerBound(depth, prices)
+	if !ok {
+		return
+	}
+	duration, ok := search.model.tour(search.model.durations, search.stores)
+	if !ok || violatesConstraints(search.model.constraints, len(search.stores), int(duration), priceBound) {
+		return
+	}
+	// The trip cost is bounded by the tour of the shortest distance, not the
+	// fastest one, the routed plans are compared again by their real costs.
+	tripCost, ok := search.model.tour(search.model.tripCosts, search.stores)
+	price := priceBound + int64(tripCost)
+	if !ok || search.dominated(price, int(duration)) {
+		return
+	}
+	if time.Now().After(search.deadline) {
+		search.timedOut = true
+		return
+	}
+
+	if depth == len(search.model.chains) {
+		products, planPrice, optimal := search.model.planProducts(search.chains)
+		search.approximate = search.approximate || !optimal
+		price = planPrice + int64(tripCost)
+		if violatesConstraints(search.model.constraints, 0, 0, planPrice) || search.dominated(price, int(duration)) {
+			return
+		}
+		solution := exactSolution{stores: slices.Clone(search.stores), chains: slices.Clone(search.chains), products: products}
+		search.add(paretoPlan{solution: solution, price: price, duration: int(duration)})
+		return
+	}
+
+	chain := search.model.chains[depth]
+	next := chain.cheaper(prices)
+	for _, store := range chain.stores {
+		search.stores = append(search.stores, store)
+		search.chains = append(search.chains, depth)
+		search.search(depth+1, next)
+		search.stores = search.stores[:len(search.stores)-1]
+		search.chains = search.chains[:len(search.chains)-1]
+	}
+	search.search(depth+1, prices)
+}
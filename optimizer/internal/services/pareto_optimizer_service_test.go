@@ -0,0 +1,180 @@
+package services
+
+import (
+	"cmp"
+	"math/rand"
+	"optimizer/internal/domain"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestSetExchangeRanges(t *testing.T) {
+	type exchangeRange struct {
+		min *float64
+		max *float64
+	}
+
+	tests := []struct {
+		name   string
+		plans  []domain.ParetoPlan
+		ranges []exchangeRange
+	}{
+		{
+			"One plan",
+			[]domain.ParetoPlan{{TotalPrice: 1000, Duration: 600}},
+			[]exchangeRange{{float(0), nil}},
+		},
+		{
+			"All plans on the envelope",
+			[]domain.ParetoPlan{{TotalPrice: 1000, Duration: 600}, {TotalPrice: 1100, Duration: 300}, {TotalPrice: 1300, Duration: 0}},
+			[]exchangeRange{{float(0), float(2)}, {float(2), float(4)}, {float(4), nil}},
+		},
+		{
+			"Plan above the envelope",
+			[]domain.ParetoPlan{{TotalPrice: 1000, Duration: 600}, {TotalPrice: 1250, Duration: 300}, {TotalPrice: 1300, Duration: 0}},
+			[]exchangeRange{{float(0), float(3)}, {nil, nil}, {float(3), nil}},
+		},
+		{
+			"Plan on the crossing of the others",
+			[]domain.ParetoPlan{{TotalPrice: 1000, Duration: 600}, {TotalPrice: 1150, Duration: 300}, {TotalPrice: 1300, Duration: 0}},
+			[]exchangeRange{{float(0), float(3)}, {nil, nil}, {float(3), nil}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setExchangeRanges(test.plans)
+			for i, plan := range test.plans {
+				assert.Equal(t, test.ranges[i].min, plan.MinExchange, "min exchange of plan %d", i)
+				assert.Equal(t, test.ranges[i].max, plan.MaxExchange, "max exchange of plan %d", i)
+			}
+		})
+	}
+}
+
+func TestThinParetoFront(t *testing.T) {
+	front := func(count int) []paretoPlan {
+		result := []paretoPlan{}
+		for i := 0; i < count; i++ {
+			result = append(result, paretoPlan{price: int64(i)})
+		}
+		return result
+	}
+	prices := func(front []paretoPlan) []int64 {
+		result := []int64{}
+		for _, plan := range front {
+			result = append(result, plan.price)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		count    int
+		maxPlans int
+		prices   []int64
+	}{
+		{"Fewer plans", 3, 10, []int64{0, 1, 2}},
+		{"As many plans", 4, 4, []int64{0, 1, 2, 3}},
+		{"Evenly spaced plans", 10, 4, []int64{0, 3, 6, 9}},
+		{"Cheapest and fastest plans", 7, 2, []int64{0, 6}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.prices, prices(thinParetoFront(front(test.count), test.maxPlans)))
+		})
+	}
+}
+
+func TestParetoSearchFront(t *testing.T) {
+	search := &paretoSearch{front: []paretoPlan{{price: 100, duration: 50}, {price: 200, duration: 20}}}
+
+	t.Run(
+		"Dominated", func(t *testing.T) {
+			assert.True(t, search.dominated(150, 60))
+			assert.True(t, search.dominated(200, 20))
+			assert.False(t, search.dominated(150, 30))
+			assert.False(t, search.dominated(90, 100))
+		},
+	)
+
+	t.Run(
+		"Add drops the dominated plans", func(t *testing.T) {
+			search.add(paretoPlan{price: 150, duration: 20})
+			assert.Equal(t, []paretoPlan{{price: 100, duration: 50}, {price: 150, duration: 20}}, search.front)
+
+			search.add(paretoPlan{price: 90, duration: 10})
+			assert.Equal(t, []paretoPlan{{price: 90, duration: 10}}, search.front)
+		},
+	)
+}
+
+// bruteForceFront returns the prices and the durations of the plans not
+// dominated by any other plan.
+func bruteForceFront(model *exactModel) [][2]int64 {
+	plans := [][2]int64{}
+	var visit func(depth int, stores, chains []int)
+	visit = func(depth int, stores, chains []int) {
+		if depth < len(model.chains) {
+			for _, store := range model.chains[depth].stores {
+				visit(depth+1, append(stores, store), append(chains, depth))
+			}
+			visit(depth+1, stores, chains)
+			return
+		}
+
+		for p := range model.suffixPrices[0] {
+			if !slices.ContainsFunc(chains, func(chain int) bool { return model.chains[chain].prices[p] != noPrice }) {
+				return
+			}
+		}
+		_, price, _ := model.planProducts(chains)
+		duration := int64(permutationTour(model.durations, stores))
+		if !violatesConstraints(model.constraints, len(stores), int(duration), price) {
+			plans = append(plans, [2]int64{price + int64(permutationTour(model.tripCosts, stores)), duration})
+		}
+	}
+	visit(0, nil, nil)
+
+	front := [][2]int64{}
+	for _, plan := range plans {
+		dominated := slices.ContainsFunc(plans, func(other [2]int64) bool {
+			return other[0] <= plan[0] && other[1] <= plan[1] && other != plan
+		})
+		if !dominated && !slices.Contains(front, plan) {
+			front = append(front, plan)
+		}
+	}
+	slices.SortFunc(front, func(a, b [2]int64) int { return cmp.Compare(a[0], b[0]) })
+	return front
+}
+
+func TestParetoSearch(t *testing.T) {
+	pricing := (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{Coupons: []domain.Coupon{{Chain: "B", Amount: 300, MinBasket: 1500}}})
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		model := randomModel(random, 1+random.Intn(4), 1+random.Intn(3), 1+random.Intn(4), pricing)
+		model.tripCosts = model.travel
+		if i%2 == 1 {
+			model.constraints = domain.PlanConstraints{MaxStores: 2}
+		}
+
+		search := &paretoSearch{model: model, deadline: time.Now().Add(time.Minute)}
+		search.search(0, noPrices(len(model.suffixPrices[0])))
+		front := [][2]int64{}
+		for _, plan := range search.front {
+			front = append(front, [2]int64{plan.price, int64(plan.duration)})
+		}
+		slices.SortFunc(front, func(a, b [2]int64) int { return cmp.Compare(a[0], b[0]) })
+
+		assert.Equal(t, bruteForceFront(model), front)
+		assert.False(t, search.timedOut)
+		assert.False(t, search.approximate)
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"cmp"
+	"log"
+	"math"
+	"optimizer/internal/domain"
+	"slices"
+)
+
+const earthRadius = 6371000.
+
+// Nothing is pruned unless the request asks for it, so the plans of the
+// requests without the prefilter don't change.
+var defaultPrefilter = domain.PrefilterSettings{}
+
+// haversine returns the straight-line distance in metres.
+func haversine(a, b domain.Point) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(b.Lat - a.Lat)
+	dLon := toRadians(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRadians(a.Lat))*math.Cos(toRadians(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// keepNearest keeps the k places of the chain nearest to the user point.
+func keepNearest(places []domain.Place, userPoint domain.Point, k int) []domain.Place {
+	if k <= 0 || len(places) <= k {
+		return places
+	}
+	sorted := slices.Clone(places)
+	slices.SortStableFunc(sorted, func(a, b domain.Place) int {
+		return cmp.Compare(haversine(userPoint, a.Point), haversine(userPoint, b.Point))
+	})
+	return sorted[:k]
+}
+
+// dominates reports whether the place is not farther than the other one from
+// every point the route can come from or go to. A chain is visited once and
+// its places have the same prices, so visiting the place instead would never
+// be longer if the routes were straight lines. It is a heuristic: the routes
+// around rivers and railways may be longer to the nearer place, which is why
+// the request has to ask for it.
+func dominates(place, other domain.Place, references []domain.Point) bool {
+	for _, reference := range references {
+		if haversine(place.Point, reference) > haversine(other.Point, reference) {
+			return false
+		}
+	}
+	return true
+}
+
+// prefilterShops prunes the candidate shops before the matrices are requested
+// and returns the number of the pruned places. The dominance is checked once
+// against the places left after the nearest ones are selected.
+func prefilterShops(shops []domain.ShopInfo, userPoint domain.Point, settings domain.PrefilterSettings) ([]domain.ShopInfo, int) {
+	pruned := 0
+	nearest := []domain.ShopInfo{}
+	for _, shop := range shops {
+		places := keepNearest(shop.Info, userPoint, settings.NearestPerChain)
+		pruned += len(shop.Info) - len(places)
+		nearest = append(nearest, domain.ShopInfo{Info: places, Shop: shop.Shop})
+	}
+
+	if !settings.Dominance {
+		return nearest, pruned
+	}
+
+	result := []domain.ShopInfo{}
+	for i, shop := range nearest {
+		references := []domain.Point{userPoint}
+		for j, other := range nearest {
+			if i != j {
+				references = append(references, collectPoints(other)...)
+			}
+		}
+
+		kept := []domain.Place{}
+		for k, place := range shop.Info {
+			dominated := false
+			for l, other := range shop.Info {
+				// Of two places equally good the first one is kept.
+				if k != l && dominates(other, place, references) && (l < k || !dominates(place, other, references)) {
+					dominated = true
+					break
+				}
+			}
+			if dominated {
+				log.Printf("place %s of %s is dominated", place.Id, shop.Shop)
+				pruned += 1
+				continue
+			}
+			kept = append(kept, place)
+		}
+		result = append(result, domain.ShopInfo{Info: kept, Shop: shop.Shop})
+	}
+
+	return result, pruned
+}
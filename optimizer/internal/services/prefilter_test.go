@@ -0,0 +1,118 @@
+package services
+
+import (
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func place(id string, lon, lat float64) domain.Place {
+	return domain.Place{Id: id, Point: domain.Point{Lon: lon, Lat: lat}}
+}
+
+func placeIds(shops []domain.ShopInfo) map[string][]string {
+	result := make(map[string][]string)
+	for _, shop := range shops {
+		result[shop.Shop] = []string{}
+		for _, place := range shop.Info {
+			result[shop.Shop] = append(result[shop.Shop], place.Id)
+		}
+	}
+	return result
+}
+
+func TestKeepNearest(t *testing.T) {
+	places := []domain.Place{place("far", 0.03, 0), place("near", 0.01, 0), place("middle", 0.02, 0)}
+
+	tests := []struct {
+		name string
+		k    int
+		ids  []string
+	}{
+		{"All places without the limit", 0, []string{"far", "near", "middle"}},
+		{"Fewer places than the limit", 5, []string{"far", "near", "middle"}},
+		{"Nearest places", 2, []string{"near", "middle"}},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				ids := placeIds([]domain.ShopInfo{{Shop: "A", Info: keepNearest(places, domain.Point{}, test.k)}})["A"]
+				assert.Equal(t, test.ids, ids)
+			},
+		)
+	}
+}
+
+func TestDominates(t *testing.T) {
+	references := []domain.Point{{}, {Lon: 0.02}}
+
+	tests := []struct {
+		name      string
+		place     domain.Place
+		other     domain.Place
+		dominates bool
+	}{
+		{"Nearer to every point", place("a", 0.01, 0), place("b", 0.01, 0.01), true},
+		{"Same point", place("a", 0.01, 0), place("b", 0.01, 0), true},
+		{"Farther from one point", place("a", -0.005, 0), place("b", 0.015, 0), false},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				assert.Equal(t, test.dominates, dominates(test.place, test.other, references))
+			},
+		)
+	}
+}
+
+func TestPrefilterShops(t *testing.T) {
+	shops := []domain.ShopInfo{
+		{Shop: "A", Info: []domain.Place{place("a-far", 0.01, 0.01), place("a-near", 0.01, 0), place("a-twin", 0.01, 0), place("a-west", -0.005, 0)}},
+		{Shop: "B", Info: []domain.Place{place("b", 0.02, 0)}},
+	}
+
+	tests := []struct {
+		name     string
+		settings domain.PrefilterSettings
+		ids      map[string][]string
+		pruned   int
+	}{
+		{
+			"Nothing is pruned by default",
+			defaultPrefilter,
+			map[string][]string{"A": {"a-far", "a-near", "a-twin", "a-west"}, "B": {"b"}},
+			0,
+		},
+		{
+			"Nearest places of each chain",
+			domain.PrefilterSettings{NearestPerChain: 2},
+			map[string][]string{"A": {"a-west", "a-near"}, "B": {"b"}},
+			2,
+		},
+		{
+			"Dominated places and the later one of the equal places",
+			domain.PrefilterSettings{Dominance: true},
+			map[string][]string{"A": {"a-near", "a-west"}, "B": {"b"}},
+			2,
+		},
+		{
+			"Dominance after the nearest places",
+			domain.PrefilterSettings{NearestPerChain: 3, Dominance: true},
+			map[string][]string{"A": {"a-west", "a-near"}, "B": {"b"}},
+			2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				result, pruned := prefilterShops(shops, domain.Point{}, test.settings)
+				assert.Equal(t, test.ids, placeIds(result))
+				assert.Equal(t, test.pruned, pruned)
+			},
+		)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"optimizer/internal/domain"
+	"optimizer/internal/handler"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Prices of the products in the chains, the nearer chain is dearer for milk.
+var testPrices = map[string]map[string]int64{
+	"milk":  {"Near": 10000, "Far": 8000},
+	"bread": {"Near": 5000, "Far": 6000},
+}
+
+var testShops = []domain.ShopInfo{
+	{Shop: "Near", Info: []domain.Place{{Name: "Near, Lenina 1", Id: "near", Point: domain.Point{Lon: 0.001}, Format: domain.FormatSupermarket}}},
+	{Shop: "Far", Info: []domain.Place{{Name: "Far, Lenina 2", Id: "far", Point: domain.Point{Lon: 0.002}, Format: domain.FormatSupermarket}}},
+}
+
+// testDuration takes a thousandth of a degree for 100 seconds and 100 metres.
+func testDuration(from, to domain.Point) int {
+	return int(math.Round((math.Abs(from.Lon-to.Lon) + math.Abs(from.Lat-to.Lat)) * 100000))
+}
+
+func jsonResponse(t *testing.T, value any) *http.Response {
+	body, err := json.Marshal(value)
+	assert.NoError(t, err)
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body))}
+}
+
+// fakeServices answers the requests of the products and the maps services the
+// way they do.
+func fakeServices(t *testing.T) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+
+		switch req.URL.Path {
+		case "/products-by-names":
+			var payload struct {
+				Type  string   `json:"type"`
+				Names []string `json:"names"`
+			}
+			assert.NoError(t, json.Unmarshal(body, &payload))
+			result := []domain.MatchData{}
+			for _, name := range payload.Names {
+				match := domain.MatchData{Title: name, Category: payload.Type}
+				for shop, price := range testPrices[name] {
+					match.Prices = append(match.Prices, domain.MatchPrices{PriceRegular: price, PriceDiscount: price, ShopName: shop})
+				}
+				result = append(result, match)
+			}
+			return jsonResponse(t, result), nil
+
+		case "/shops":
+			return jsonResponse(t, map[string]any{"shops": testShops}), nil
+
+		case "/distance":
+			var payload struct {
+				From []domain.Point `json:"from"`
+				To   []domain.Point `json:"to"`
+			}
+			assert.NoError(t, json.Unmarshal(body, &payload))
+			info := []domain.RoutesInfo{}
+			for i, from := range payload.From {
+				routes := domain.RoutesInfo{From: i}
+				for j, to := range payload.To {
+					duration := testDuration(from, to)
+					routes.Routes = append(routes.Routes, domain.Route{To: j, Time: duration, Distance: duration})
+				}
+				info = append(info, routes)
+			}
+			return jsonResponse(t, map[string]any{"info": info}), nil
+
+		case "/optimal-routes":
+			var payload struct {
+				Points []domain.Point `json:"points"`
+			}
+			assert.NoError(t, json.Unmarshal(body, &payload))
+			order, duration := []int{}, 0
+			for i := range payload.Points {
+				order = append(order, i)
+				duration += testDuration(payload.Points[i], payload.Points[(i+1)%len(payload.Points)])
+			}
+			routes := []domain.MinTimeRoute{}
+			for _, transport := range domain.TRANSPORTS {
+				routes = append(routes, domain.MinTimeRoute{Points: append(order, 0), Duration: duration, Transport: transport})
+			}
+			return jsonResponse(t, map[string]any{"routes": routes, "statuses": []domain.TransportStatus{}}), nil
+		}
+
+		t.Errorf("unexpected request to %s", req.URL)
+		return &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+	})}
+}
+
+func TestProductsHandler(t *testing.T) {
+	client := fakeServices(t)
+	productService := NewProductService("http://products", client)
+	mapsService := NewMapsService("http://maps", client)
+	loyalty, err := NewLoyaltyEngine(nil)
+	assert.NoError(t, err)
+	pricing, err := NewPricingRules(DefaultPricingConfig)
+	assert.NoError(t, err)
+	optimizer := NewSolverSelector(domain.SolverHeuristic, map[string]domain.IOptimizerService{
+		domain.SolverHeuristic: NewOptimizerService(mapsService, productService, loyalty, pricing),
+		domain.SolverExact:     NewExactOptimizerService(mapsService, productService, loyalty, pricing),
+	})
+
+	tests := []struct {
+		name     string
+		solver   string
+		exchange int64
+		stores   []string
+		price    int64
+	}{
+		{"Heuristic solver buys in both chains", domain.SolverHeuristic, 0, []string{"Near, Lenina 1", "Far, Lenina 2"}, 130000},
+		{"Heuristic solver saves the time", domain.SolverHeuristic, 1000, []string{"Near, Lenina 1"}, 150000},
+		{"Exact solver buys in both chains", domain.SolverExact, 0, []string{"Near, Lenina 1", "Far, Lenina 2"}, 130000},
+		{"Exact solver saves the time", domain.SolverExact, 1000, []string{"Near, Lenina 1"}, 150000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := domain.OptimizerRequest{
+				Products: []domain.InputProductInfo{
+					{Info: domain.ProductInfo{Type: "dairy", Name: "milk"}, Amount: 10},
+					{Info: domain.ProductInfo{Type: "bakery", Name: "bread"}, Amount: 10},
+				},
+				Radius:   1000,
+				Exchange: test.exchange,
+				Solver:   test.solver,
+			}
+			body, err := json.Marshal(request)
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			handler.CreateProductsHandler(optimizer)(recorder, httptest.NewRequest(http.MethodPost, "/products", bytes.NewReader(body)))
+			assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+			var result domain.OptimizerResult
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+			stores := []string{}
+			for _, store := range result.Stores {
+				stores = append(stores, store.Store)
+			}
+			assert.ElementsMatch(t, test.stores, stores)
+			assert.Equal(t, test.price, result.TotalPrice)
+			assert.Equal(t, test.solver, result.Solver)
+		})
+	}
+
+	t.Run(
+		"Unknown solver", func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.CreateProductsHandler(optimizer)(recorder, httptest.NewRequest(http.MethodPost, "/products", bytes.NewReader([]byte(`{"solver": "annealing"}`))))
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		},
+	)
+}
@@ -11,7 +11,20 @@ import (
 )
 
 type ProductService struct {
-	url *url.URL
+	url    *url.URL
+	client *http.Client
+}
+
+type categoryPayload struct {
+	Type    string           `json:"type"`
+	Filters []categoryFilter `json:"filters"`
+}
+
+// categoryFilter selects the products by the master data values, as the
+// products service decodes it.
+type categoryFilter struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
 }
 
 type productPayload struct {
@@ -19,21 +32,36 @@ type productPayload struct {
 	Names []string `json:"names"`
 }
 
-func NewProductService(host string) *ProductService {
+func NewProductService(host string, client *http.Client) *ProductService {
 	u, err := url.Parse(host)
 	if err != nil {
 		panic(err)
 	}
 
-	return &ProductService{url: u}
+	return &ProductService{url: u, client: client}
 }
 
 func (service *ProductService) GetProducts(category string, names []string) ([]domain.MatchData, error) {
-	payload := productPayload{Type: category, Names: names}
+	return service.post("products-by-names", productPayload{Type: category, Names: names})
+}
+
+// GetProductsByCategory returns the products of the category with the values
+// of the constraints. The ranges are not sent, they are checked by the
+// optimizer, as the products service matches the exact values only.
+func (service *ProductService) GetProductsByCategory(category string, constraints []domain.MasterDataConstraint) ([]domain.MatchData, error) {
+	filters := []categoryFilter{}
+	for _, constraint := range constraints {
+		if len(constraint.Values) > 0 {
+			filters = append(filters, categoryFilter{Name: constraint.Name, Values: constraint.Values})
+		}
+	}
+	return service.post("products-by-category", categoryPayload{Type: category, Filters: filters})
+}
 
+func (service *ProductService) post(path string, payload any) ([]domain.MatchData, error) {
 	requestBody, _ := json.Marshal(payload)
 
-	resp, err := http.Post(service.url.JoinPath("products-by-names").String(), "application/json", bytes.NewBuffer(requestBody))
+	resp, err := service.client.Post(service.url.JoinPath(path).String(), "application/json", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProductsByCategory(t *testing.T) {
+	var payload categoryPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/products-by-category", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	_, err := NewProductService(server.URL, server.Client()).GetProductsByCategory("milk", []domain.MasterDataConstraint{
+		{Name: "brand", Values: []string{"A", "B"}},
+		{Name: "Объем", Min: float(900)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, categoryPayload{Type: "milk", Filters: []categoryFilter{{Name: "brand", Values: []string{"A", "B"}}}}, payload)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"optimizer/internal/domain"
+	"os"
+	"slices"
+)
+
+// DefaultPricingConfig is used without the pricing config, most chains price
+// the weighed products per kg and the packed ones per pack.
+var DefaultPricingConfig = domain.PricingConfig{Default: domain.ChainPricing{WeighedPerKg: true}}
+
+// PricingRules keeps the pricing of the chains.
+type PricingRules struct {
+	defaultPricing domain.ChainPricing
+	chains         map[string]domain.ChainPricing
+}
+
+func NewPricingRules(config domain.PricingConfig) (*PricingRules, error) {
+	rules := &PricingRules{defaultPricing: config.Default, chains: make(map[string]domain.ChainPricing)}
+	for _, pricing := range config.Chains {
+		if pricing.Chain == "" {
+			return nil, fmt.Errorf("chain pricing without chain in config: %+v", pricing)
+		}
+		rules.chains[pricing.Chain] = pricing
+	}
+	return rules, nil
+}
+
+func LoadPricingRules(path string) (*PricingRules, error) {
+	jsonFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't load pricing config file: %+v", err)
+	}
+	defer jsonFile.Close()
+
+	bytes, err := io.ReadAll(jsonFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read pricing config file: %+v", err)
+	}
+
+	var config domain.PricingConfig
+	if err := json.Unmarshal(bytes, &config); err != nil {
+		return nil, fmt.Errorf("can't unmarshal pricing config: %+v", err)
+	}
+
+	return NewPricingRules(config)
+}
+
+func (rules *PricingRules) forChain(chain string) domain.ChainPricing {
+	if pricing, ok := rules.chains[chain]; ok {
+		return pricing
+	}
+	return rules.defaultPricing
+}
+
+// The line prices are in kopecks, and the prices of the plans are in tenths of
+// a kopeck, as they were when the price of a product was multiplied by its
+// amount in tenths. So the exchange and the price limits keep their meaning.
+const (
+	priceScale     = 10
+	pricesPerRuble = 100 * priceScale
+)
+
+// Master data keys of the pack sizes and their units.
+var packSizeKeys = map[string]string{"Вес": domain.UnitGrams, "Объем": domain.UnitMillilitres}
+
+const (
+	weighedKey   = "Весовой"
+	weighedValue = "Да"
+)
+
+// getQuantities returns the quantities of the products, the amount of the
+// frontend is converted when the quantity is not set. The products are read
+// after they are collected, as the master data may mark them weighed.
+func getQuantities(products []domain.InputProductInfo) ([]domain.Quantity, error) {
+	result := []domain.Quantity{}
+	for _, product := range products {
+		quantity := getQuantity(product)
+		if !slices.Contains(domain.UNITS, quantity.Unit) || quantity.Value <= 0 {
+			return nil, fmt.Errorf("%w: bad quantity of %s: %d %s", domain.ErrInvalidRequest, product.Info.Name, quantity.Value, quantity.Unit)
+		}
+		result = append(result, quantity)
+	}
+	return result, nil
+}
+
+func getQuantity(product domain.InputProductInfo) domain.Quantity {
+	if product.Quantity != nil {
+		return *product.Quantity
+	}
+	if product.Info.Weighed {
+		return domain.Quantity{Value: product.Amount * 100, Unit: domain.UnitGrams}
+	}
+	return domain.Quantity{Value: (product.Amount + 9) / 10, Unit: domain.UnitPieces}
+}
+
+func getPack(data []domain.MasterData) domain.Pack {
+	pack := domain.Pack{}
+	for _, data := range data {
+		if data.Key == weighedKey {
+			pack.Weighed = data.Value == weighedValue
+		}
+		unit, ok := packSizeKeys[data.Key]
+		if !ok || pack.Size > 0 {
+			continue
+		}
+		if low, high, ok := parseMasterDataValue(data.Value); ok && low == high && low > 0 {
+			pack.Size, pack.Unit = int64(low), unit
+		}
+	}
+	return pack
+}
+
+// setPacks sets the packs of the requested products and the pricing of the
+// chains to their prices. A product is weighed when either the master data or
+// the request says so, and the product is marked so, as its amount is then
+// of kilograms.
+func setPacks(products []domain.InputProductInfo, matchData []domain.MatchData, pricing *PricingRules) {
+	for i := range matchData {
+		pack := getPack(matchData[i].Data)
+		pack.Weighed = pack.Weighed || products[i].Info.Weighed
+		products[i].Info.Weighed = pack.Weighed
+		for j := range matchData[i].Prices {
+			matchData[i].Prices[j].Pack = pack
+			matchData[i].Prices[j].Pricing = pricing.forChain(matchData[i].Prices[j].ShopName)
+		}
+	}
+}
+
+// linePrice returns the price of the quantity and the packs to buy, 0 packs
+// when the product is sold by weight. The packs are rounded up. It fails when
+// the quantity can't be priced, e.g. grams of the packs of unknown size.
+func linePrice(price int64, pricing domain.ChainPricing, pack domain.Pack, quantity domain.Quantity) (int64, int64, bool) {
+	// Such a chain may have normalised the price by a net weight missing in
+	// the master data, so the price can't be taken for the price of a pack.
+	if pricing.PackedPerKg && pack.Size == 0 && !pack.Weighed {
+		return 0, 0, false
+	}
+	perKg := pack.Weighed && pricing.WeighedPerKg || pack.Size > 0 && pricing.PackedPerKg
+
+	if quantity.Unit != domain.UnitPieces && pack.Weighed && perKg {
+		return (price*quantity.Value + 500) / 1000, 0, true
+	}
+
+	packPrice := price
+	if perKg {
+		if pack.Size == 0 {
+			return 0, 0, false
+		}
+		packPrice = (price*pack.Size + 500) / 1000
+	}
+
+	if quantity.Unit == domain.UnitPieces {
+		return packPrice * quantity.Value, quantity.Value, true
+	}
+	if pack.Size == 0 {
+		return 0, 0, false
+	}
+	packs := (quantity.Value + pack.Size - 1) / pack.Size
+	return packPrice * packs, packs, true
+}
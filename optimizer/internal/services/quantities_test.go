@@ -0,0 +1,194 @@
+package services
+
+import (
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pieces(value int64) domain.Quantity {
+	return domain.Quantity{Value: value, Unit: domain.UnitPieces}
+}
+
+func grams(value int64) domain.Quantity {
+	return domain.Quantity{Value: value, Unit: domain.UnitGrams}
+}
+
+func TestLinePrice(t *testing.T) {
+	packed := domain.Pack{Size: 500, Unit: domain.UnitGrams}
+	weighed := domain.Pack{Weighed: true}
+	perPack := domain.ChainPricing{WeighedPerKg: true}
+	perKg := domain.ChainPricing{WeighedPerKg: true, PackedPerKg: true}
+
+	tests := []struct {
+		name     string
+		price    int64
+		pricing  domain.ChainPricing
+		pack     domain.Pack
+		quantity domain.Quantity
+		line     int64
+		packs    int64
+		ok       bool
+	}{
+		{"Pieces of the packs", 10000, perPack, packed, pieces(2), 20000, 2, true},
+		{"Pieces of the packs priced per kg", 20000, perKg, packed, pieces(2), 20000, 2, true},
+		{"Grams are rounded up to the packs", 10000, perPack, packed, grams(1200), 30000, 3, true},
+		{"Grams of the whole packs", 10000, perPack, packed, grams(1000), 20000, 2, true},
+		{"Grams of the packs priced per kg", 20000, perKg, packed, grams(700), 20000, 2, true},
+		{"Grams of the weighed product", 30000, perPack, weighed, grams(1500), 45000, 0, true},
+		{"Weighed product priced per pack", 30000, domain.ChainPricing{}, domain.Pack{Size: 800, Unit: domain.UnitGrams, Weighed: true}, grams(1000), 60000, 2, true},
+		{"Pieces of the weighed product of unknown size", 30000, perPack, weighed, pieces(1), 0, 0, false},
+		{"Grams of the packs of unknown size", 10000, perPack, domain.Pack{}, grams(100), 0, 0, false},
+		{"Pieces of unknown size priced per kg", 10000, perKg, domain.Pack{}, pieces(1), 0, 0, false},
+		{"Pieces of unknown size priced per pack", 10000, perPack, domain.Pack{}, pieces(3), 30000, 3, true},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				line, packs, ok := linePrice(test.price, test.pricing, test.pack, test.quantity)
+				assert.Equal(t, test.ok, ok)
+				assert.Equal(t, test.line, line)
+				assert.Equal(t, test.packs, packs)
+			},
+		)
+	}
+}
+
+func TestGetQuantity(t *testing.T) {
+	t.Run(
+		"Quantity of the request",
+		func(t *testing.T) {
+			quantity := grams(300)
+			product := domain.InputProductInfo{Amount: 10, Quantity: &quantity}
+			assert.Equal(t, grams(300), getQuantity(product))
+		},
+	)
+
+	t.Run(
+		"Amount of the weighed product in tenths of a kilogram",
+		func(t *testing.T) {
+			product := domain.InputProductInfo{Amount: 15, Info: domain.ProductInfo{Weighed: true}}
+			assert.Equal(t, grams(1500), getQuantity(product))
+		},
+	)
+
+	t.Run(
+		"Amount in tenths of a piece is rounded up",
+		func(t *testing.T) {
+			assert.Equal(t, pieces(2), getQuantity(domain.InputProductInfo{Amount: 20}))
+			assert.Equal(t, pieces(2), getQuantity(domain.InputProductInfo{Amount: 15}))
+		},
+	)
+
+	t.Run(
+		"Bad quantities are rejected",
+		func(t *testing.T) {
+			zero := grams(0)
+			unknown := domain.Quantity{Value: 1, Unit: "kg"}
+			for _, quantity := range []*domain.Quantity{&zero, &unknown} {
+				_, err := getQuantities([]domain.InputProductInfo{{Quantity: quantity}})
+				assert.ErrorIs(t, err, domain.ErrInvalidRequest)
+			}
+		},
+	)
+}
+
+func TestGetPack(t *testing.T) {
+	t.Run(
+		"Weight of the pack",
+		func(t *testing.T) {
+			pack := getPack([]domain.MasterData{{Key: "Вес", Value: "500г"}, {Key: "Весовой", Value: "Нет"}})
+			assert.Equal(t, domain.Pack{Size: 500, Unit: domain.UnitGrams}, pack)
+		},
+	)
+
+	t.Run(
+		"Volume in litres",
+		func(t *testing.T) {
+			pack := getPack([]domain.MasterData{{Key: "Объем", Value: "0,9 л"}})
+			assert.Equal(t, domain.Pack{Size: 900, Unit: domain.UnitMillilitres}, pack)
+		},
+	)
+
+	t.Run(
+		"Weighed product without size",
+		func(t *testing.T) {
+			pack := getPack([]domain.MasterData{{Key: "Весовой", Value: "Да"}, {Key: "Вес", Value: "0.8-1.2кг"}})
+			assert.Equal(t, domain.Pack{Weighed: true}, pack)
+		},
+	)
+}
+
+func TestGetProductInfo(t *testing.T) {
+	loyalty := (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{DiscountCards: []string{"Магнит"}})
+
+	t.Run(
+		"Price of the amount in tenths as before the quantities",
+		func(t *testing.T) {
+			product := domain.InputProductInfo{Amount: 20}
+			price := domain.MatchPrices{PriceRegular: 10000, PriceDiscount: 9000, ShopName: "Пятёрочка"}
+			info := getProductInfo(price, "", "Пятёрочка", loyalty, getQuantity(product))
+			assert.Equal(t, price.PriceRegular*product.Amount, *info.Price)
+		},
+	)
+
+	t.Run(
+		"Card price of the weighed product",
+		func(t *testing.T) {
+			product := domain.InputProductInfo{Amount: 15, Info: domain.ProductInfo{Weighed: true}}
+			price := domain.MatchPrices{PriceRegular: 30000, PriceDiscount: 20000, ShopName: "Магнит", Pack: domain.Pack{Weighed: true}, Pricing: domain.ChainPricing{WeighedPerKg: true}}
+			info := getProductInfo(price, "", "Магнит", loyalty, getQuantity(product))
+			assert.Equal(t, price.PriceDiscount*product.Amount, *info.Price)
+			assert.Equal(t, (price.PriceRegular-price.PriceDiscount)*product.Amount, info.Saving)
+			assert.Equal(t, "Магнит", info.Program)
+		},
+	)
+}
+
+func TestPricingRules(t *testing.T) {
+	t.Run(
+		"Default pricing of the chains missing in the config",
+		func(t *testing.T) {
+			rules, err := LoadPricingRules("../../configs/pricing.json")
+			assert.NoError(t, err)
+			assert.Equal(t, domain.ChainPricing{Chain: "Лента", WeighedPerKg: true, PackedPerKg: true}, rules.forChain("Лента"))
+			assert.Equal(t, domain.ChainPricing{WeighedPerKg: true}, rules.forChain("Пятёрочка"))
+		},
+	)
+
+	t.Run(
+		"Chain pricing without chain",
+		func(t *testing.T) {
+			_, err := NewPricingRules(domain.PricingConfig{Chains: []domain.ChainPricing{{PackedPerKg: true}}})
+			assert.Error(t, err)
+		},
+	)
+}
+
+func TestSetPacks(t *testing.T) {
+	rules, err := NewPricingRules(domain.PricingConfig{
+		Default: domain.ChainPricing{WeighedPerKg: true},
+		Chains:  []domain.ChainPricing{{Chain: "Лента", WeighedPerKg: true, PackedPerKg: true}},
+	})
+	assert.NoError(t, err)
+
+	products := []domain.InputProductInfo{{Amount: 15}, {Amount: 20}}
+	matchData := []domain.MatchData{
+		{Data: []domain.MasterData{{Key: "Весовой", Value: "Да"}}, Prices: []domain.MatchPrices{{PriceRegular: 30000, ShopName: "Пятёрочка"}}},
+		{Data: []domain.MasterData{{Key: "Вес", Value: "500г"}}, Prices: []domain.MatchPrices{{PriceRegular: 20000, ShopName: "Лента"}}},
+	}
+	setPacks(products, matchData, rules)
+
+	quantities, err := getQuantities(products)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Quantity{grams(1500), pieces(2)}, quantities)
+
+	loyalty := (&LoyaltyEngine{}).forRequest(domain.OptimizerRequest{})
+	weighed := getProductInfo(matchData[0].Prices[0], "", "Пятёрочка", loyalty, quantities[0])
+	assert.Equal(t, int64(45000*priceScale), *weighed.Price)
+	packed := getProductInfo(matchData[1].Prices[0], "", "Лента", loyalty, quantities[1])
+	assert.Equal(t, int64(20000*priceScale), *packed.Price)
+}
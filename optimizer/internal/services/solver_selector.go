@@ -0,0 +1,29 @@
+package services
+
+import (
+	"fmt"
+	"optimizer/internal/domain"
+)
+
+// SolverSelector passes the request to the solver named in it, so the solvers
+// can be compared on the same requests.
+type SolverSelector struct {
+	defaultSolver string
+	solvers       map[string]domain.IOptimizerService
+}
+
+func NewSolverSelector(defaultSolver string, solvers map[string]domain.IOptimizerService) *SolverSelector {
+	return &SolverSelector{defaultSolver: defaultSolver, solvers: solvers}
+}
+
+func (selector *SolverSelector) Get(request domain.OptimizerRequest) (*domain.OptimizerResult, error) {
+	name := request.Solver
+	if name == "" {
+		name = selector.defaultSolver
+	}
+	solver, ok := selector.solvers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown solver %s", domain.ErrInvalidRequest, name)
+	}
+	return solver.Get(request)
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namedSolver string
+
+func (solver namedSolver) Get(domain.OptimizerRequest) (*domain.OptimizerResult, error) {
+	return &domain.OptimizerResult{Solver: string(solver)}, nil
+}
+
+func TestSolverSelector(t *testing.T) {
+	selector := NewSolverSelector(domain.SolverHeuristic, map[string]domain.IOptimizerService{
+		domain.SolverHeuristic: namedSolver(domain.SolverHeuristic),
+		domain.SolverExact:     namedSolver(domain.SolverExact),
+	})
+
+	tests := []struct {
+		name   string
+		solver string
+		result string
+	}{
+		{"Default solver", "", domain.SolverHeuristic},
+		{"Heuristic solver", domain.SolverHeuristic, domain.SolverHeuristic},
+		{"Exact solver", domain.SolverExact, domain.SolverExact},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := selector.Get(domain.OptimizerRequest{Solver: test.solver})
+			assert.NoError(t, err)
+			assert.Equal(t, test.result, result.Solver)
+		})
+	}
+
+	t.Run(
+		"Unknown solver", func(t *testing.T) {
+			result, err := selector.Get(domain.OptimizerRequest{Solver: "annealing"})
+			assert.ErrorIs(t, err, domain.ErrInvalidRequest)
+			assert.Nil(t, result)
+		},
+	)
+}
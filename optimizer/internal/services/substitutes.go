@@ -0,0 +1,132 @@
+package services
+
+import (
+	"fmt"
+	"optimizer/internal/domain"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Master data values are numbers or ranges with units, e.g. "930мл", "1л" or
+// "3.4-4.2%".
+var masterDataValue = regexp.MustCompile(`^\s*(\d+(?:[.,]\d+)?)(?:\s*-\s*(\d+(?:[.,]\d+)?))?\s*(\S*)\s*$`)
+
+// Units converted to millilitres and grams, the other ones are kept.
+var masterDataUnits = map[string]float64{"л": 1000, "кг": 1000}
+
+// parseMasterDataValue returns the bounds of the value, a single number has
+// equal bounds.
+func parseMasterDataValue(value string) (float64, float64, bool) {
+	match := masterDataValue.FindStringSubmatch(value)
+	if match == nil {
+		return 0, 0, false
+	}
+
+	scale, ok := masterDataUnits[strings.ToLower(match[3])]
+	if !ok {
+		scale = 1
+	}
+	low, err := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	high := low
+	if match[2] != "" {
+		if high, err = strconv.ParseFloat(strings.Replace(match[2], ",", ".", 1), 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return low * scale, high * scale, true
+}
+
+func meetsConstraint(data []domain.MasterData, constraint domain.MasterDataConstraint) bool {
+	index := slices.IndexFunc(data, func(data domain.MasterData) bool { return data.Key == constraint.Name })
+	if index < 0 {
+		return false
+	}
+	value := data[index].Value
+
+	if len(constraint.Values) > 0 && !slices.Contains(constraint.Values, value) {
+		return false
+	}
+	if constraint.Min == nil && constraint.Max == nil {
+		return true
+	}
+
+	low, high, ok := parseMasterDataValue(value)
+	if !ok {
+		return false
+	}
+	return (constraint.Min == nil || low >= *constraint.Min) && (constraint.Max == nil || high <= *constraint.Max)
+}
+
+// checkSubstitutes rejects the substitutable products without constraints, as
+// any product of the category, e.g. cheese for milk, would substitute them.
+func checkSubstitutes(products []domain.InputProductInfo) error {
+	for _, product := range products {
+		if product.Substitutable && len(product.Constraints) == 0 {
+			return fmt.Errorf("%w: substitutable %s without constraints", domain.ErrInvalidRequest, product.Info.Name)
+		}
+	}
+	return nil
+}
+
+func isSubstitute(product domain.InputProductInfo, candidate domain.MatchData) bool {
+	if candidate.Title == product.Info.Name {
+		return false
+	}
+	for _, constraint := range product.Constraints {
+		if !meetsConstraint(candidate.Data, constraint) {
+			return false
+		}
+	}
+	return true
+}
+
+// categoryKey returns the category of the product with the values of its
+// constraints, the products service is asked for the candidates once per key.
+func categoryKey(product domain.InputProductInfo) string {
+	key := product.Info.Type
+	for _, constraint := range product.Constraints {
+		if len(constraint.Values) > 0 {
+			key += fmt.Sprintf("|%s=%q", constraint.Name, constraint.Values)
+		}
+	}
+	return key
+}
+
+// addSubstitutes appends the prices of the substitutes to the substitutable
+// products. The lowest price of a chain is chosen later as for the requested
+// product, and the requested product wins the equal prices as it goes first.
+func addSubstitutes(products []domain.InputProductInfo, matchData []domain.MatchData, productsService domain.IProductsService, pricing *PricingRules) error {
+	categories := make(map[string][]domain.MatchData)
+	for i, product := range products {
+		if !product.Substitutable {
+			continue
+		}
+
+		key := categoryKey(product)
+		candidates, ok := categories[key]
+		if !ok {
+			var err error
+			if candidates, err = productsService.GetProductsByCategory(product.Info.Type, product.Constraints); err != nil {
+				return err
+			}
+			categories[key] = candidates
+		}
+
+		matchData[i].Title, matchData[i].Category = product.Info.Name, product.Info.Type
+		for _, candidate := range candidates {
+			if !isSubstitute(product, candidate) {
+				continue
+			}
+			for _, price := range candidate.Prices {
+				price.Title, price.Pack, price.Pricing = candidate.Title, getPack(candidate.Data), pricing.forChain(price.ShopName)
+				matchData[i].Prices = append(matchData[i].Prices, price)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProducts sells the products by the prices of the chains, the products
+// of the categories are returned as they are and the filters are recorded.
+type fakeProducts struct {
+	prices     map[string]map[string]int64
+	categories map[string][]domain.MatchData
+	filters    [][]domain.MasterDataConstraint
+}
+
+func (products *fakeProducts) GetProducts(category string, names []string) ([]domain.MatchData, error) {
+	result := []domain.MatchData{}
+	for _, name := range names {
+		match := domain.MatchData{Title: name, Category: category}
+		for shop, price := range products.prices[name] {
+			match.Prices = append(match.Prices, domain.MatchPrices{PriceRegular: price, PriceDiscount: price, ShopName: shop})
+		}
+		result = append(result, match)
+	}
+	return result, nil
+}
+
+func (products *fakeProducts) GetProductsByCategory(category string, filters []domain.MasterDataConstraint) ([]domain.MatchData, error) {
+	products.filters = append(products.filters, filters)
+	return products.categories[category], nil
+}
+
+func TestParseMasterDataValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		low   float64
+		high  float64
+		ok    bool
+	}{
+		{"Litres", "1л", 1000, 1000, true},
+		{"Millilitres", "930мл", 930, 930, true},
+		{"Kilograms", "0,5 кг", 500, 500, true},
+		{"Range with commas", "3,4-4,2%", 3.4, 4.2, true},
+		{"Range with spaces", "3.4 - 4.2 %", 3.4, 4.2, true},
+		{"Garbage", "без лактозы", 0, 0, false},
+		{"Empty", "", 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			low, high, ok := parseMasterDataValue(test.value)
+			assert.Equal(t, test.ok, ok)
+			assert.InDelta(t, test.low, low, 1e-9)
+			assert.InDelta(t, test.high, high, 1e-9)
+		})
+	}
+}
+
+func TestMeetsConstraint(t *testing.T) {
+	data := []domain.MasterData{{Key: "volume", Value: "1л"}, {Key: "fat", Value: "3,4-4,2%"}, {Key: "brand", Value: "A"}}
+
+	tests := []struct {
+		name       string
+		constraint domain.MasterDataConstraint
+		meets      bool
+	}{
+		{"Missing key", domain.MasterDataConstraint{Name: "taste", Values: []string{"A"}}, false},
+		{"One of the values", domain.MasterDataConstraint{Name: "brand", Values: []string{"A", "B"}}, true},
+		{"None of the values", domain.MasterDataConstraint{Name: "brand", Values: []string{"B"}}, false},
+		{"Within the bounds", domain.MasterDataConstraint{Name: "volume", Min: float(900), Max: float(1000)}, true},
+		{"Below the min", domain.MasterDataConstraint{Name: "volume", Min: float(1500)}, false},
+		{"Range within the bounds", domain.MasterDataConstraint{Name: "fat", Min: float(3), Max: float(5)}, true},
+		{"Range above the max", domain.MasterDataConstraint{Name: "fat", Max: float(4)}, false},
+		{"Bounds of garbage", domain.MasterDataConstraint{Name: "brand", Min: float(0)}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.meets, meetsConstraint(data, test.constraint))
+		})
+	}
+}
+
+func TestCheckSubstitutes(t *testing.T) {
+	product := domain.InputProductInfo{Info: domain.ProductInfo{Type: "milk", Name: "milk"}, Substitutable: true}
+	assert.True(t, errors.Is(checkSubstitutes([]domain.InputProductInfo{product}), domain.ErrInvalidRequest))
+
+	product.Constraints = []domain.MasterDataConstraint{{Name: "volume", Min: float(900)}}
+	assert.NoError(t, checkSubstitutes([]domain.InputProductInfo{product}))
+}
+
+func TestAddSubstitutes(t *testing.T) {
+	pricing, err := NewPricingRules(domain.PricingConfig{Chains: []domain.ChainPricing{{Chain: "B", PackedPerKg: true}}})
+	assert.NoError(t, err)
+	candidate := func(title, volume string, shop string) domain.MatchData {
+		return domain.MatchData{
+			Title:  title,
+			Data:   []domain.MasterData{{Key: "brand", Value: "A"}, {Key: "Объем", Value: volume}},
+			Prices: []domain.MatchPrices{{PriceRegular: 100, PriceDiscount: 100, ShopName: shop}},
+		}
+	}
+	products := &fakeProducts{categories: map[string][]domain.MatchData{"milk": {
+		candidate("milk", "1л", "A"),
+		candidate("milk 930", "930мл", "B"),
+		candidate("milk 2l", "2л", "A"),
+	}}}
+	constraints := []domain.MasterDataConstraint{{Name: "brand", Values: []string{"A"}}, {Name: "Объем", Min: float(900), Max: float(1000)}}
+	request := []domain.InputProductInfo{
+		{Info: domain.ProductInfo{Type: "milk", Name: "milk"}, Substitutable: true, Constraints: constraints},
+		{Info: domain.ProductInfo{Type: "milk", Name: "kefir"}},
+		{Info: domain.ProductInfo{Type: "milk", Name: "milk"}, Substitutable: true, Constraints: constraints},
+	}
+	matchData := make([]domain.MatchData, len(request))
+
+	assert.NoError(t, addSubstitutes(request, matchData, products, pricing))
+
+	t.Run(
+		"Candidates are asked once per category and values", func(t *testing.T) {
+			assert.Equal(t, [][]domain.MasterDataConstraint{constraints}, products.filters)
+		},
+	)
+
+	t.Run(
+		"Substitutes within the constraints", func(t *testing.T) {
+			for _, i := range []int{0, 2} {
+				assert.Equal(t, "milk", matchData[i].Title)
+				if assert.Len(t, matchData[i].Prices, 1) {
+					price := matchData[i].Prices[0]
+					assert.Equal(t, "milk 930", price.Title)
+					assert.Equal(t, domain.Pack{Size: 930, Unit: domain.UnitMillilitres}, price.Pack)
+					assert.Equal(t, pricing.forChain("B"), price.Pricing)
+				}
+			}
+			assert.Empty(t, matchData[1].Prices)
+		},
+	)
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"optimizer/internal/domain"
+	"slices"
+)
+
+// Default trip costs in tenths of a kopeck, like the product prices: fuel for
+// driving and the fare for taxi. Walking is free.
+var defaultTripCosts = map[string]domain.TripCost{
+	domain.TransportDriving: {PerKm: 10000},
+	domain.TransportTaxi:    {Base: 100000, PerKm: 30000},
+}
+
+func getTransports(request domain.OptimizerRequest) ([]string, error) {
+	switch {
+	case request.Transport == "":
+		return []string{domain.TransportWalking}, nil
+	case request.Transport == domain.TransportAny:
+		return domain.TRANSPORTS, nil
+	case slices.Contains(domain.TRANSPORTS, request.Transport):
+		return []string{request.Transport}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown transport %s", domain.ErrInvalidRequest, request.Transport)
+}
+
+func getTripCost(request domain.OptimizerRequest, transport string) domain.TripCost {
+	if cost, ok := request.TripCosts[transport]; ok {
+		return cost
+	}
+	return defaultTripCosts[transport]
+}
+
+// distanceCost returns the per km part of the trip cost, the distance is in
+// metres.
+func distanceCost(cost domain.TripCost, distance int) int64 {
+	return int64(float64(distance) / 1000. * cost.PerKm)
+}
+
+func tripCost(cost domain.TripCost, distance int) int64 {
+	return cost.Base + distanceCost(cost, distance)
+}
+
+// routeDistance sums the distances between the points of the route, the first
+// point is the user point and the other ones are the stores.
+func routeDistance(mtr *domain.MinTimeRoute, stores optimizedStores, distMatrix map[string]map[string]int) int {
+	ids := []string{USER_POINT_ID}
+	for _, store := range stores.Stores {
+		ids = append(ids, store.Id)
+	}
+
+	distance := 0
+	for i := 0; i+1 < len(mtr.Points); i += 1 {
+		distance += distMatrix[ids[mtr.Points[i]]][ids[mtr.Points[i+1]]]
+	}
+	return distance
+}
+
+// addTripCost adds the cost of the route trip to the result cost.
+func addTripCost(result *domain.OptimizerResult, mtr *domain.MinTimeRoute, stores optimizedStores, distMatrix map[string]map[string]int, cost domain.TripCost) {
+	result.Transport = mtr.Transport
+	result.TripCost = tripCost(cost, routeDistance(mtr, stores, distMatrix))
+	result.Cost += result.TripCost
+}
+
+// solveWithTransports runs the solver with each transport of the request and
+// returns the result of the lowest cost. It fails only when every transport
+// fails, with the error of the first one.
+func solveWithTransports(transports []string, solve func(transport string) (*domain.OptimizerResult, error)) (*domain.OptimizerResult, error) {
+	var best *domain.OptimizerResult
+	var firstErr error
+	for _, transport := range transports {
+		result, err := solve(transport)
+		if err != nil {
+			log.Printf("can't solve with %s: %+v", transport, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if best == nil || result.Cost < best.Cost {
+			best = result
+		}
+	}
+	if best == nil {
+		return nil, firstErr
+	}
+	return best, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"optimizer/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTransports(t *testing.T) {
+	tests := []struct {
+		name       string
+		transport  string
+		transports []string
+		valid      bool
+	}{
+		{"Walking by default", "", []string{domain.TransportWalking}, true},
+		{"All transports for any", domain.TransportAny, domain.TRANSPORTS, true},
+		{"One transport", domain.TransportTaxi, []string{domain.TransportTaxi}, true},
+		{"Unknown transport", "bicycle", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				transports, err := getTransports(domain.OptimizerRequest{Transport: test.transport})
+				assert.Equal(t, test.transports, transports)
+				if test.valid {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorIs(t, err, domain.ErrInvalidRequest)
+				}
+			},
+		)
+	}
+}
+
+func TestGetTripCost(t *testing.T) {
+	request := domain.OptimizerRequest{TripCosts: map[string]domain.TripCost{domain.TransportTaxi: {Base: 5000, PerKm: 2000}}}
+
+	tests := []struct {
+		name      string
+		transport string
+		cost      domain.TripCost
+		trip      int64
+	}{
+		{"Walking is free", domain.TransportWalking, domain.TripCost{}, 0},
+		{"Default cost of driving", domain.TransportDriving, defaultTripCosts[domain.TransportDriving], int64(defaultTripCosts[domain.TransportDriving].PerKm * 2.5)},
+		{"Cost of the request", domain.TransportTaxi, domain.TripCost{Base: 5000, PerKm: 2000}, 10000},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				cost := getTripCost(request, test.transport)
+				assert.Equal(t, test.cost, cost)
+				assert.Equal(t, test.trip, tripCost(cost, 2500))
+			},
+		)
+	}
+}
+
+func TestAddTripCost(t *testing.T) {
+	stores := optimizedStores{Stores: []finalStoreInfo{{Id: "near"}, {Id: "far"}}}
+	distMatrix := map[string]map[string]int{
+		USER_POINT_ID: {"near": 1000, "far": 2000},
+		"near":        {"far": 1500, USER_POINT_ID: 1000},
+		"far":         {"near": 1500, USER_POINT_ID: 2500},
+	}
+	mtr := &domain.MinTimeRoute{Points: []int{0, 2, 1, 0}, Transport: domain.TransportDriving}
+
+	assert.Equal(t, 2000+1500+1000, routeDistance(mtr, stores, distMatrix))
+
+	result := &domain.OptimizerResult{TotalPrice: 100000, Cost: 120000}
+	addTripCost(result, mtr, stores, distMatrix, domain.TripCost{Base: 1000, PerKm: 2000})
+	assert.Equal(t, domain.TransportDriving, result.Transport)
+	assert.Equal(t, int64(1000+9000), result.TripCost)
+	assert.Equal(t, int64(130000), result.Cost)
+	assert.Equal(t, int64(100000), result.TotalPrice)
+}
+
+func TestSolveWithTransports(t *testing.T) {
+	errWalking := errors.New("walking failed")
+	errTaxi := errors.New("taxi failed")
+	solvers := map[string]func() (*domain.OptimizerResult, error){
+		domain.TransportWalking: func() (*domain.OptimizerResult, error) { return nil, errWalking },
+		domain.TransportDriving: func() (*domain.OptimizerResult, error) {
+			return &domain.OptimizerResult{Cost: 300, Transport: domain.TransportDriving}, nil
+		},
+		domain.TransportTaxi: func() (*domain.OptimizerResult, error) { return nil, errTaxi },
+	}
+	cheap := func(transport string) (*domain.OptimizerResult, error) {
+		if transport == domain.TransportTaxi {
+			return &domain.OptimizerResult{Cost: 200, Transport: transport}, nil
+		}
+		return solvers[transport]()
+	}
+
+	tests := []struct {
+		name       string
+		transports []string
+		solve      func(transport string) (*domain.OptimizerResult, error)
+		transport  string
+		err        error
+	}{
+		{"Failed transports are skipped", domain.TRANSPORTS, func(transport string) (*domain.OptimizerResult, error) { return solvers[transport]() }, domain.TransportDriving, nil},
+		{"Lowest cost", domain.TRANSPORTS, cheap, domain.TransportTaxi, nil},
+		{"First error when every transport fails", []string{domain.TransportWalking, domain.TransportTaxi}, func(transport string) (*domain.OptimizerResult, error) { return solvers[transport]() }, "", errWalking},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				result, err := solveWithTransports(test.transports, test.solve)
+				if test.err != nil {
+					assert.Equal(t, test.err, err)
+					assert.Nil(t, result)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, test.transport, result.Transport)
+			},
+		)
+	}
+}